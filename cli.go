@@ -0,0 +1,514 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// Exit codes of the subcommands
+const (
+	exitOK         = 0
+	exitFailed     = 1 // A forward failed or did not come up in time, or the API refused
+	exitUsage      = 2
+	exitNotRunning = 3 // No kubefwd is running for the config source
+)
+
+// cliPollInterval is how often start and preset apply look at the forwards they wait for
+var cliPollInterval = 500 * time.Millisecond
+
+// cliCommands are the subcommands that drive the running kubefwd over its control
+// socket. Each gets the arguments after its name and returns the exit code.
+var cliCommands = map[string]func(args []string) int{
+	"status":  cliStatus,
+	"start":   cliStart,
+	"stop":    cliStop,
+	"preset":  cliPreset,
+	"context": cliContext,
+	"ports":   cliPorts,
+	"exec":    cliExec,
+}
+
+// cliOptions are the flags every subcommand has, and its positional arguments
+type cliOptions struct {
+	flags      *flag.FlagSet
+	configFile *string
+	dbPath     *string
+	json       *bool
+	args       []string
+}
+
+func newCLIFlags(name, usage string) *cliOptions {
+	o := newStoreFlags(name, usage)
+	o.json = o.flags.Bool("json", false, "Print JSON instead of a table")
+	return o
+}
+
+// newStoreFlags returns the options with only the flags that select the config source
+func newStoreFlags(name, usage string) *cliOptions {
+	set := flag.NewFlagSet("kubefwd "+name, flag.ContinueOnError)
+	set.Usage = func() {
+		fmt.Fprintf(set.Output(), "Usage: kubefwd %s %s\n", name, usage)
+		set.PrintDefaults()
+	}
+	return &cliOptions{
+		flags:      set,
+		configFile: set.String("config", getDefaultConfigPath(), "Path to the YAML configuration file"),
+		dbPath:     set.String("db", "", "SQLite database for configuration (instead of --config)"),
+	}
+}
+
+// parse parses args. Flags may follow the positional arguments, so that
+// `kubefwd start API --json` works.
+func (o *cliOptions) parse(args []string) error {
+	for {
+		if err := o.flags.Parse(args); err != nil {
+			return err
+		}
+		args = o.flags.Args()
+		if len(args) == 0 {
+			return nil
+		}
+		o.args = append(o.args, args[0])
+		args = args[1:]
+	}
+}
+
+// usageError returns the exit code of a parse error (the flag package reported it)
+func usageError(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
+
+// source returns the config source the way the running kubefwd names it (see
+// ConfigStore.Description)
+func (o *cliOptions) source() string {
+	if *o.dbPath != "" {
+		return "sqlite:" + absPath(*o.dbPath)
+	}
+	return absPath(*o.configFile)
+}
+
+// absPath returns path made absolute, or path itself if that fails
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// client returns a client of the kubefwd running for the config source
+func (o *cliOptions) client() *controlClient {
+	_, socketPath := instancePaths(o.source())
+	return newControlClient(socketPath)
+}
+
+// fail reports err and returns the exit code: exitNotRunning when no kubefwd listens
+// on the control socket, exitFailed otherwise
+func (o *cliOptions) fail(err error) int {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "kubefwd is not running for %s\n", o.source())
+		return exitNotRunning
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	return exitFailed
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// Types of a forwardRow
+const (
+	forwardTypeService = "service"
+	forwardTypeProxy   = "proxy"
+)
+
+// forwardRow is a service or proxy service as the subcommands print it
+type forwardRow struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	LocalPort int    `json:"local_port"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Retrying  bool   `json:"retrying,omitempty"`
+	Waiting   string `json:"waiting,omitempty"`
+}
+
+// up reports whether the forward can be used; an armed one starts on the first connection
+func (r forwardRow) up() bool {
+	return r.Status == string(StatusRunning) || r.Status == string(StatusArmed)
+}
+
+// failed reports whether the forward gave up: an error without a retry to come, or a
+// start plan that did not start it because a dependency failed
+func (r forwardRow) failed() bool {
+	return (r.Status == string(StatusError) && !r.Retrying) || strings.HasPrefix(r.Waiting, "Not started")
+}
+
+func (r forwardRow) detail() string {
+	switch {
+	case r.Error != "" && r.Retrying:
+		return r.Error + " (retrying)"
+	case r.Error != "":
+		return r.Error
+	}
+	return r.Waiting
+}
+
+// fetchForwards returns the state of the running kubefwd and its services and proxy
+// services. GET /api/proxy-services answers the whole state, like the SSE stream.
+func fetchForwards(c *controlClient) (stateJSON, []forwardRow, error) {
+	var st stateJSON
+	if err := c.call("GET", "/api/proxy-services", nil, &st); err != nil {
+		return st, nil, err
+	}
+	var rows []forwardRow
+	for _, s := range st.Services {
+		rows = append(rows, forwardRow{Name: s.Name, Type: forwardTypeService, LocalPort: s.LocalPort,
+			Status: s.Status, Error: s.Error, Retrying: s.Retrying, Waiting: s.Waiting})
+	}
+	for _, g := range st.ProxyGroups {
+		for _, s := range g.Services {
+			rows = append(rows, forwardRow{Name: s.Name, Type: forwardTypeProxy, LocalPort: s.LocalPort,
+				Status: s.Status, Error: s.Error, Retrying: s.Retrying, Waiting: s.Waiting})
+		}
+	}
+	return st, rows, nil
+}
+
+// pickForwards returns the rows of names, in that order
+func pickForwards(rows []forwardRow, names []string) ([]forwardRow, error) {
+	picked := make([]forwardRow, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, r := range rows {
+			if r.Name == name {
+				picked = append(picked, r)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no service or proxy service named %q", name)
+		}
+	}
+	return picked, nil
+}
+
+func printForwards(rows []forwardRow) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tPORT\tSTATUS\tDETAIL")
+	for _, r := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", r.Name, r.Type, r.LocalPort, r.Status, r.detail())
+	}
+	w.Flush()
+}
+
+// forwardPath returns the API path of action (start or stop) on the forward of r
+func forwardPath(r forwardRow, action string) string {
+	if r.Type == forwardTypeProxy {
+		return "/api/proxy-services/" + url.PathEscape(r.Name) + "/" + action
+	}
+	return "/api/services/" + url.PathEscape(r.Name) + "/" + action
+}
+
+// cliStatus prints the forwards. Given names, it exits with 1 unless all of them are up.
+func cliStatus(args []string) int {
+	o := newCLIFlags("status", "[flags] [service...]")
+	if err := o.parse(args); err != nil {
+		return usageError(err)
+	}
+	st, rows, err := fetchForwards(o.client())
+	if err != nil {
+		return o.fail(err)
+	}
+	if len(o.args) > 0 {
+		if rows, err = pickForwards(rows, o.args); err != nil {
+			return o.fail(err)
+		}
+	}
+
+	if *o.json {
+		printJSON(map[string]any{
+			"cluster_context": st.ClusterContext,
+			"cluster_name":    st.ClusterName,
+			"namespace":       st.Namespace,
+			"forwards":        rows,
+		})
+	} else {
+		cluster := st.ClusterContext
+		if st.ClusterName != "" && st.ClusterName != st.ClusterContext {
+			cluster += " (" + st.ClusterName + ")"
+		}
+		fmt.Printf("Context: %s  Namespace: %s\n\n", cluster, st.Namespace)
+		printForwards(rows)
+	}
+
+	if len(o.args) > 0 {
+		for _, r := range rows {
+			if !r.up() {
+				return exitFailed
+			}
+		}
+	}
+	return exitOK
+}
+
+// cliStart starts services and proxy services and waits until they are up
+func cliStart(args []string) int {
+	o := newCLIFlags("start", "[flags] <service...>")
+	timeout := o.flags.Duration("timeout", 60*time.Second, "How long to wait for the forwards to come up")
+	noWait := o.flags.Bool("no-wait", false, "Return once the forwards are started, without waiting until they are up")
+	if err := o.parse(args); err != nil {
+		return usageError(err)
+	}
+	if len(o.args) == 0 {
+		o.flags.Usage()
+		return exitUsage
+	}
+
+	c := o.client()
+	_, rows, err := fetchForwards(c)
+	if err != nil {
+		return o.fail(err)
+	}
+	picked, err := pickForwards(rows, o.args)
+	if err != nil {
+		return o.fail(err)
+	}
+	for _, r := range picked {
+		if r.Status == string(StatusRunning) || r.Status == string(StatusStarting) {
+			continue
+		}
+		if err := c.call("POST", forwardPath(r, "start"), nil, nil); err != nil {
+			return o.fail(fmt.Errorf("starting %s: %w", r.Name, err))
+		}
+	}
+	if *noWait {
+		return exitOK
+	}
+	return o.await(c, o.args, *timeout)
+}
+
+// await polls the running kubefwd until every one of names is up and prints them.
+// It returns exitFailed as soon as one fails, or when timeout passes first.
+func (o *cliOptions) await(c *controlClient, names []string, timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	// A forward only counts as failed once it was seen trying, so that the error of
+	// an earlier attempt is not taken for the outcome of this one
+	trying := make(map[string]bool)
+	for {
+		_, rows, err := fetchForwards(c)
+		if err != nil {
+			return o.fail(err)
+		}
+		picked, err := pickForwards(rows, names)
+		if err != nil {
+			return o.fail(err) // Removed from the config meanwhile
+		}
+		allUp := true
+		var failed []string
+		for _, r := range picked {
+			if !r.up() {
+				allUp = false
+			}
+			if r.failed() && trying[r.Name] {
+				failed = append(failed, r.Name)
+			} else if !r.failed() {
+				trying[r.Name] = true
+			}
+		}
+		timedOut := !allUp && len(failed) == 0 && time.Now().After(deadline)
+		if allUp || len(failed) > 0 || timedOut {
+			if *o.json {
+				printJSON(picked)
+			} else {
+				printForwards(picked)
+			}
+			switch {
+			case len(failed) > 0:
+				fmt.Fprintf(os.Stderr, "Failed: %s\n", strings.Join(failed, ", "))
+				return exitFailed
+			case timedOut:
+				fmt.Fprintf(os.Stderr, "Timed out after %s waiting for the forwards to come up\n", timeout)
+				return exitFailed
+			}
+			return exitOK
+		}
+		time.Sleep(cliPollInterval)
+	}
+}
+
+// cliStop stops services and proxy services, and with --dependents what depends on them
+func cliStop(args []string) int {
+	o := newCLIFlags("stop", "[flags] <service...>")
+	dependents := o.flags.Bool("dependents", false, "Also stop the services and proxy services that depend on them")
+	if err := o.parse(args); err != nil {
+		return usageError(err)
+	}
+	if len(o.args) == 0 {
+		o.flags.Usage()
+		return exitUsage
+	}
+
+	c := o.client()
+	_, rows, err := fetchForwards(c)
+	if err != nil {
+		return o.fail(err)
+	}
+	picked, err := pickForwards(rows, o.args)
+	if err != nil {
+		return o.fail(err)
+	}
+	type stopped struct {
+		Name              string   `json:"name"`
+		StoppedDependents []string `json:"stopped_dependents,omitempty"`
+		RunningDependents []string `json:"running_dependents,omitempty"`
+	}
+	var results []stopped
+	for _, r := range picked {
+		path := forwardPath(r, "stop")
+		if *dependents {
+			path += "?dependents=1"
+		}
+		result := stopped{Name: r.Name}
+		if err := c.call("POST", path, nil, &result); err != nil {
+			return o.fail(fmt.Errorf("stopping %s: %w", r.Name, err))
+		}
+		result.Name = r.Name
+		results = append(results, result)
+	}
+
+	if *o.json {
+		printJSON(results)
+		return exitOK
+	}
+	for _, s := range results {
+		if len(s.StoppedDependents) > 0 {
+			fmt.Printf("Stopped %s and what depends on it: %s\n", s.Name, strings.Join(s.StoppedDependents, ", "))
+		} else {
+			fmt.Printf("Stopped %s\n", s.Name)
+		}
+		if len(s.RunningDependents) > 0 {
+			fmt.Fprintf(os.Stderr, "Still running and depending on %s: %s (use --dependents to stop them too)\n", s.Name, strings.Join(s.RunningDependents, ", "))
+		}
+	}
+	return exitOK
+}
+
+// cliPreset applies a preset and waits until its services are up
+func cliPreset(args []string) int {
+	if len(args) == 0 || args[0] != "apply" {
+		fmt.Fprintln(os.Stderr, "Usage: kubefwd preset apply [flags] <name>")
+		return exitUsage
+	}
+	o := newCLIFlags("preset apply", "[flags] <name>")
+	timeout := o.flags.Duration("timeout", 60*time.Second, "How long to wait for the services of the preset to come up")
+	noWait := o.flags.Bool("no-wait", false, "Return once the preset is applied, without waiting until its services are up")
+	if err := o.parse(args[1:]); err != nil {
+		return usageError(err)
+	}
+	if len(o.args) != 1 {
+		o.flags.Usage()
+		return exitUsage
+	}
+	name := o.args[0]
+
+	c := o.client()
+	if err := c.call("POST", "/api/presets/"+url.PathEscape(name)+"/apply", nil, nil); err != nil {
+		return o.fail(fmt.Errorf("applying preset %s: %w", name, err))
+	}
+	if *noWait {
+		return exitOK
+	}
+	st, _, err := fetchForwards(c)
+	if err != nil {
+		return o.fail(err)
+	}
+	for _, p := range st.Presets {
+		if p.Name == name {
+			return o.await(c, p.Services, *timeout)
+		}
+	}
+	return exitOK
+}
+
+// cliContext switches the running kubefwd to an alternative context, by its name or context
+func cliContext(args []string) int {
+	if len(args) == 0 || args[0] != "switch" {
+		fmt.Fprintln(os.Stderr, "Usage: kubefwd context switch [flags] <name>")
+		return exitUsage
+	}
+	o := newCLIFlags("context switch", "[flags] <name>")
+	if err := o.parse(args[1:]); err != nil {
+		return usageError(err)
+	}
+	if len(o.args) != 1 {
+		o.flags.Usage()
+		return exitUsage
+	}
+
+	var result struct {
+		Status  string `json:"status"`
+		Context string `json:"context"`
+	}
+	body := map[string]string{"context": o.args[0]}
+	if err := o.client().call("POST", "/api/contexts/switch", body, &result); err != nil {
+		return o.fail(fmt.Errorf("switching to %s: %w", o.args[0], err))
+	}
+	if *o.json {
+		printJSON(result)
+	} else {
+		fmt.Printf("Switched to context %s\n", result.Context)
+	}
+	return exitOK
+}
+
+// cliPorts prints who holds the configured local ports. It exits with 1 when another
+// process or the leftover of a killed kubefwd holds one.
+func cliPorts(args []string) int {
+	o := newCLIFlags("ports", "[flags]")
+	if err := o.parse(args); err != nil {
+		return usageError(err)
+	}
+	var ports []portInfo
+	if err := o.client().call("GET", "/api/ports", nil, &ports); err != nil {
+		return o.fail(err)
+	}
+
+	if *o.json {
+		printJSON(ports)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PORT\tADDRESS\tSERVICE\tTYPE\tSTATUS\tPID\tPROCESS")
+		for _, p := range ports {
+			pid := ""
+			if p.PID > 0 {
+				pid = fmt.Sprint(p.PID)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", p.Port, p.Address, p.ServiceName, p.Type, p.Status, pid, p.Process)
+		}
+		w.Flush()
+	}
+
+	for _, p := range ports {
+		if p.Status == string(PortStatusExternal) || p.Status == string(PortStatusOrphan) {
+			return exitFailed
+		}
+	}
+	return exitOK
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKubefwd is the API of a running kubefwd, served on its control socket.
+// Started forwards come up, except Broken, which fails.
+type fakeKubefwd struct {
+	mu    sync.Mutex
+	state stateJSON
+	calls []string
+}
+
+func (f *fakeKubefwd) setStatus(name string, status PortForwardStatus) {
+	for i := range f.state.Services {
+		if f.state.Services[i].Name == name {
+			f.state.Services[i].Status = string(status)
+		}
+	}
+	for i := range f.state.ProxyGroups[0].Services {
+		if f.state.ProxyGroups[0].Services[i].Name == name {
+			f.state.ProxyGroups[0].Services[i].Status = string(status)
+		}
+	}
+}
+
+func (f *fakeKubefwd) start(name string) {
+	f.setStatus(name, StatusStarting)
+	time.AfterFunc(10*time.Millisecond, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if name == "Broken" {
+			f.setStatus(name, StatusError)
+		} else {
+			f.setStatus(name, StatusRunning)
+		}
+	})
+}
+
+func serveFakeKubefwd(t *testing.T, configFile string) *fakeKubefwd {
+	in, err := lockInstance(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(in.Close)
+
+	f := &fakeKubefwd{state: stateJSON{ClusterContext: "prod", Namespace: "default",
+		Services: []serviceStateJSON{
+			{Name: "API", LocalPort: 8080, Status: string(StatusStopped)},
+			{Name: "Broken", LocalPort: 8081, Status: string(StatusStopped)},
+		},
+		ProxyGroups: []proxyGroupStateJSON{{GroupKey: "prod/default", Services: []proxyServiceStateJSON{
+			{Name: "CloudSQL", LocalPort: 5434, Status: string(StatusStopped)},
+		}}},
+		Presets: []Preset{{Name: "Backend", Services: []string{"API", "CloudSQL"}}},
+	}}
+	mux := http.NewServeMux()
+	route := func(pattern string, fn func(w http.ResponseWriter, r *http.Request)) {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if r.Method == "POST" {
+				f.calls = append(f.calls, r.URL.RequestURI())
+			}
+			fn(w, r)
+		})
+	}
+	route("GET /api/proxy-services", func(w http.ResponseWriter, r *http.Request) {
+		jsonOK(w, f.state)
+	})
+	start := func(w http.ResponseWriter, r *http.Request) {
+		f.start(r.PathValue("name"))
+		jsonOK(w, map[string]string{"status": "starting"})
+	}
+	stop := func(w http.ResponseWriter, r *http.Request) {
+		f.setStatus(r.PathValue("name"), StatusStopped)
+		jsonOK(w, map[string]any{"status": "stopped", "running_dependents": []string{"Worker"}})
+	}
+	route("POST /api/services/{name}/start", start)
+	route("POST /api/services/{name}/stop", stop)
+	route("POST /api/proxy-services/{name}/start", start)
+	route("POST /api/proxy-services/{name}/stop", stop)
+	route("POST /api/presets/{name}/apply", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("name") != "Backend" {
+			jsonError(w, "preset not found", http.StatusNotFound)
+			return
+		}
+		f.start("API")
+		f.start("CloudSQL")
+		jsonOK(w, map[string]string{"status": "ok"})
+	})
+	route("POST /api/contexts/switch", func(w http.ResponseWriter, r *http.Request) {
+		jsonError(w, "context not found in alternative_contexts", http.StatusNotFound)
+	})
+	route("GET /api/ports", func(w http.ResponseWriter, r *http.Request) {
+		ports := []portInfo{{Port: 8080, ServiceName: "API", Type: "service", Status: string(PortStatusKubefwd)}}
+		if f.state.ProxyGroups[0].Services[0].Status != string(StatusRunning) {
+			ports = append(ports, portInfo{Port: 5434, ServiceName: "CloudSQL", Type: "proxy", InUse: true, PID: 99, Status: string(PortStatusExternal)})
+		}
+		jsonOK(w, ports)
+	})
+	if err := in.Serve(mux); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *fakeKubefwd) takeCalls() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	calls := strings.Join(f.calls, ", ")
+	f.calls = nil
+	return calls
+}
+
+func TestCLICommands(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	defer func(interval time.Duration) { cliPollInterval = interval }(cliPollInterval)
+	cliPollInterval = 5 * time.Millisecond
+	configFile := filepath.Join(t.TempDir(), "kubefwd.yaml")
+	f := serveFakeKubefwd(t, configFile)
+	run := func(args ...string) int {
+		return cliCommands[args[0]](append(args[1:], "--config", configFile))
+	}
+
+	if code := run("status"); code != exitOK {
+		t.Fatalf("status: exit code %d", code)
+	}
+	if code := run("status", "API"); code != exitFailed {
+		t.Fatalf("status of a stopped service: exit code %d", code)
+	}
+	if code := run("ports"); code != exitFailed {
+		t.Fatalf("ports with an external process: exit code %d", code)
+	}
+
+	if code := run("start", "API", "CloudSQL", "--json"); code != exitOK {
+		t.Fatalf("start: exit code %d", code)
+	}
+	if got := f.takeCalls(); got != "/api/services/API/start, /api/proxy-services/CloudSQL/start" {
+		t.Fatalf("start calls: %s", got)
+	}
+	if code := run("status", "API", "CloudSQL"); code != exitOK {
+		t.Fatalf("status of running forwards: exit code %d", code)
+	}
+	if code := run("ports"); code != exitOK {
+		t.Fatalf("ports: exit code %d", code)
+	}
+	if code := run("start", "API"); code != exitOK || f.takeCalls() != "" {
+		t.Fatalf("starting a running service: exit code %d", code)
+	}
+
+	if code := run("start", "Broken"); code != exitFailed {
+		t.Fatalf("failing start: exit code %d", code)
+	}
+	if code := run("start", "Missing"); code != exitFailed {
+		t.Fatalf("unknown service: exit code %d", code)
+	}
+	if code := run("start"); code != exitUsage {
+		t.Fatalf("start without services: exit code %d", code)
+	}
+	f.takeCalls()
+
+	if code := run("stop", "API", "--dependents"); code != exitOK {
+		t.Fatalf("stop: exit code %d", code)
+	}
+	if got := f.takeCalls(); got != "/api/services/API/stop?dependents=1" {
+		t.Fatalf("stop calls: %s", got)
+	}
+
+	if code := run("preset", "apply", "Backend"); code != exitOK {
+		t.Fatalf("preset apply: exit code %d", code)
+	}
+	if code := run("preset", "apply", "Missing"); code != exitFailed {
+		t.Fatalf("unknown preset: exit code %d", code)
+	}
+	if code := run("preset", "remove", "Backend"); code != exitUsage {
+		t.Fatalf("unknown preset subcommand: exit code %d", code)
+	}
+	if code := run("context", "switch", "Nowhere"); code != exitFailed {
+		t.Fatalf("unknown context: exit code %d", code)
+	}
+
+	other := filepath.Join(t.TempDir(), "other.yaml")
+	if code := cliStatus([]string{"--config", other}); code != exitNotRunning {
+		t.Fatalf("no kubefwd running: exit code %d", code)
+	}
+}
@@ -2,10 +2,15 @@ package main
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"sort"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // AlternativeContext represents an alternative cluster context
@@ -17,7 +22,8 @@ type AlternativeContext struct {
 // Preset represents a preset configuration of services
 type Preset struct {
 	Name     string   `yaml:"name"`
-	Services []string `yaml:"services"` // List of service names to start
+	Services []string `yaml:"services"`       // List of service names to start
+	Lazy     bool     `yaml:"lazy,omitempty"` // Arm the services instead of starting them
 }
 
 // Config represents the complete configuration file structure
@@ -27,6 +33,17 @@ type Config struct {
 	Namespace           string               `yaml:"namespace"`
 	MaxRetries          int                  `yaml:"max_retries,omitempty"` // Global default: -1 for infinite, 0 to disable, N for specific limit
 	WebPort             int                  `yaml:"web_port,omitempty"`    // Port for the web UI (default: 8765)
+	ForwardEngine       string               `yaml:"forward_engine,omitempty"` // Global default: "kubectl" (default) or "native"
+	ReadyTimeout        int                  `yaml:"ready_timeout,omitempty"`  // Seconds to wait for a forward to accept connections (default: 30)
+	IdleTimeout         int                  `yaml:"idle_timeout,omitempty"`   // Minutes without connections after which a forward is stopped (default: 0, never)
+	MaxLifetime         int                  `yaml:"max_lifetime,omitempty"`   // Hours after which a forward is stopped regardless of use (default: 0, never)
+	RetryPolicy         RetryPolicy          `yaml:"retry_policy,omitempty"`   // Backoff between reconnect attempts (default: 1s doubling up to 60s, no jitter)
+	ManageHosts         bool                 `yaml:"manage_hosts,omitempty"`   // Map cluster DNS names of running services to their bind address in hosts_file
+	HostsFile           string               `yaml:"hosts_file,omitempty"`     // Hosts file edited when manage_hosts is on (default: /etc/hosts)
+	DNSAddress          string               `yaml:"dns_address,omitempty"`    // UDP address of the built-in DNS server, e.g. 127.0.0.1:5353 (default: off)
+	DNSUpstream         string               `yaml:"dns_upstream,omitempty"`   // Resolver for names outside the cluster, e.g. 1.1.1.1:53 (default: refuse them)
+	HistoryDays         int                  `yaml:"history_days,omitempty"`   // Days of status history kept in the SQLite store (default: 0, memory only)
+	Orphans             string               `yaml:"orphans,omitempty"`        // What to do with leftovers of a killed kubefwd: ask (default), adopt, cleanup or ignore
 	AlternativeContexts []AlternativeContext `yaml:"alternative_contexts,omitempty"`
 	Presets             []Preset             `yaml:"presets,omitempty"`
 	Services            []Service            `yaml:"services"`
@@ -35,18 +52,236 @@ type Config struct {
 	ProxyPodContext     string               `yaml:"proxy_pod_context,omitempty"`     // Context where proxy pod is created (default: cluster_context)
 	ProxyPodNamespace   string               `yaml:"proxy_pod_namespace,omitempty"` // Namespace where proxy pod is created (default: namespace)
 	ProxyServices       []ProxyService       `yaml:"proxy_services,omitempty"`      // Proxy services for GCP connections
+	Notifiers           []Notifier           `yaml:"notifiers,omitempty"`           // Commands and webhooks told about failures and recoveries
+}
+
+// RetryPolicy controls the delay before each reconnect attempt: initial_delay,
+// multiplied by multiplier after every attempt and capped at max_delay. jitter
+// spreads each delay randomly by up to that fraction in either direction.
+type RetryPolicy struct {
+	InitialDelay float64 `yaml:"initial_delay,omitempty" json:"initial_delay,omitempty"` // Seconds before the first retry
+	MaxDelay     float64 `yaml:"max_delay,omitempty" json:"max_delay,omitempty"`         // Upper bound in seconds
+	Multiplier   float64 `yaml:"multiplier,omitempty" json:"multiplier,omitempty"`       // Growth factor per attempt (>= 1)
+	Jitter       float64 `yaml:"jitter,omitempty" json:"jitter,omitempty"`               // Fraction between 0 and 1
+}
+
+// Default retry policy, matching the previous hard-coded min(2^n, 60) seconds
+const (
+	defaultRetryInitialDelay = 1
+	defaultRetryMaxDelay     = 60
+	defaultRetryMultiplier   = 2
+)
+
+// Merge returns p with the fields override sets (non-zero) replaced
+func (p RetryPolicy) Merge(override *RetryPolicy) RetryPolicy {
+	if override == nil {
+		return p
+	}
+	if override.InitialDelay != 0 {
+		p.InitialDelay = override.InitialDelay
+	}
+	if override.MaxDelay != 0 {
+		p.MaxDelay = override.MaxDelay
+	}
+	if override.Multiplier != 0 {
+		p.Multiplier = override.Multiplier
+	}
+	if override.Jitter != 0 {
+		p.Jitter = override.Jitter
+	}
+	return p
+}
+
+// validate checks the fields that are set; prefix names the policy in errors
+func (p *RetryPolicy) validate(prefix string) error {
+	if p.InitialDelay < 0 {
+		return fmt.Errorf("%sretry_policy.initial_delay must be a positive number of seconds", prefix)
+	}
+	if p.MaxDelay < 0 {
+		return fmt.Errorf("%sretry_policy.max_delay must be a positive number of seconds", prefix)
+	}
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		return fmt.Errorf("%sretry_policy.multiplier must be at least 1", prefix)
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("%sretry_policy.jitter must be between 0 and 1", prefix)
+	}
+	return nil
+}
+
+// Hooks are shell commands run around a forward's lifecycle. Each phase runs its
+// commands in order with sh -c and stops at the first failure.
+type Hooks struct {
+	PreStart        []string `yaml:"pre_start,omitempty" json:"pre_start,omitempty"`                   // Before a forward session starts
+	PostStart       []string `yaml:"post_start,omitempty" json:"post_start,omitempty"`                 // Once the session is first ready
+	PreStop         []string `yaml:"pre_stop,omitempty" json:"pre_stop,omitempty"`                     // Before the forward is stopped
+	PostStop        []string `yaml:"post_stop,omitempty" json:"post_stop,omitempty"`                   // After it was stopped or failed for good
+	Timeout         int      `yaml:"timeout,omitempty" json:"timeout,omitempty"`                       // Seconds per command (default: 30)
+	FailOnPostStart bool     `yaml:"fail_on_post_start,omitempty" json:"fail_on_post_start,omitempty"` // A failing post_start hook marks the forward as errored
+}
+
+// Hook phases
+const (
+	HookPreStart  = "pre_start"
+	HookPostStart = "post_start"
+	HookPreStop   = "pre_stop"
+	HookPostStop  = "post_stop"
+)
+
+const defaultHookTimeout = 30
+
+// Commands returns the commands of phase
+func (h *Hooks) Commands(phase string) []string {
+	switch phase {
+	case HookPreStart:
+		return h.PreStart
+	case HookPostStart:
+		return h.PostStart
+	case HookPreStop:
+		return h.PreStop
+	case HookPostStop:
+		return h.PostStop
+	}
+	return nil
+}
+
+// GetTimeout returns the per-command timeout, defaulting to 30 seconds
+func (h *Hooks) GetTimeout() time.Duration {
+	if h.Timeout > 0 {
+		return time.Duration(h.Timeout) * time.Second
+	}
+	return defaultHookTimeout * time.Second
+}
+
+// validate checks the hooks; prefix names the service in errors
+func (h *Hooks) validate(prefix string) error {
+	if h.Timeout < 0 {
+		return fmt.Errorf("%shooks.timeout must be a positive number of seconds", prefix)
+	}
+	for _, phase := range []string{HookPreStart, HookPostStart, HookPreStop, HookPostStop} {
+		for i, c := range h.Commands(phase) {
+			if strings.TrimSpace(c) == "" {
+				return fmt.Errorf("%shooks.%s[%d] is empty", prefix, phase, i)
+			}
+		}
+	}
+	return nil
+}
+
+// Notifier types
+const (
+	NotifierCommand = "command"
+	NotifierWebhook = "webhook"
+)
+
+// Notification events
+const (
+	EventRetriesExhausted = "retries_exhausted"   // A forward failed and is not retried any more
+	EventProxyPodNotReady = "proxy_pod_not_ready" // A proxy pod could not be created or did not become ready
+	EventSqlTapCrashed    = "sqltap_crashed"      // sql-tapd exited unexpectedly
+	EventRecovered        = "recovered"           // Running again after one of the failures above
+)
+
+// notificationEvents lists every event in the order they are documented
+var notificationEvents = []string{EventRetriesExhausted, EventProxyPodNotReady, EventSqlTapCrashed, EventRecovered}
+
+// defaultNotifierRateLimit is the default number of seconds between notifications
+// for the same service and event
+const defaultNotifierRateLimit = 300
+
+// Notifier tells someone when forwards fail or recover, either by running a local
+// command or by POSTing JSON to a webhook
+type Notifier struct {
+	Name      string   `yaml:"name" json:"name"`
+	Type      string   `yaml:"type" json:"type"`                                 // command or webhook
+	Command   string   `yaml:"command,omitempty" json:"command,omitempty"`       // Run with sh -c (type command)
+	URL       string   `yaml:"url,omitempty" json:"url,omitempty"`               // Receives a JSON POST (type webhook)
+	Events    []string `yaml:"events,omitempty" json:"events,omitempty"`         // Events to notify about (default: all)
+	Services  []string `yaml:"services,omitempty" json:"services,omitempty"`     // Only these services, proxy services or proxy pods (default: all)
+	RateLimit *int     `yaml:"rate_limit,omitempty" json:"rate_limit,omitempty"` // Seconds between notifications for the same service and event (default: 300, 0 = no limit)
+}
+
+// GetRateLimit returns the minimum time between notifications for the same service and event
+func (n *Notifier) GetRateLimit() time.Duration {
+	if n.RateLimit != nil {
+		return time.Duration(*n.RateLimit) * time.Second
+	}
+	return defaultNotifierRateLimit * time.Second
+}
+
+// Wants reports whether the notifier is interested in event for the named entry
+func (n *Notifier) Wants(event, name string) bool {
+	return (len(n.Events) == 0 || containsString(n.Events, event)) &&
+		(len(n.Services) == 0 || containsString(n.Services, name))
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Notifier) validate(i int) error {
+	prefix := fmt.Sprintf("notifier %d (%s): ", i, n.Name)
+	if n.Name == "" {
+		return fmt.Errorf("notifier %d: name is required", i)
+	}
+	switch n.Type {
+	case NotifierCommand:
+		if strings.TrimSpace(n.Command) == "" {
+			return fmt.Errorf("%scommand is required", prefix)
+		}
+	case NotifierWebhook:
+		u, err := url.Parse(n.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%surl must be an http or https URL", prefix)
+		}
+	default:
+		return fmt.Errorf("%stype must be 'command' or 'webhook'", prefix)
+	}
+	for _, e := range n.Events {
+		if !containsString(notificationEvents, e) {
+			return fmt.Errorf("%sunknown event %q (one of %s)", prefix, e, strings.Join(notificationEvents, ", "))
+		}
+	}
+	if n.RateLimit != nil && *n.RateLimit < 0 {
+		return fmt.Errorf("%srate_limit must be a number of seconds (0 = no limit)", prefix)
+	}
+	return nil
+}
+
+// PortMapping maps one remote port of a service to a local port
+type PortMapping struct {
+	Name       string `yaml:"name,omitempty" json:"name,omitempty"` // Optional label shown in the UI (e.g. "http", "metrics")
+	RemotePort int    `yaml:"remote_port" json:"remote_port"`
+	LocalPort  int    `yaml:"local_port" json:"local_port"`
 }
 
 // Service represents a single service configuration
 type Service struct {
 	Name              string `yaml:"name" json:"name"`
-	ServiceName       string `yaml:"service_name" json:"service_name"`
+	ServiceName       string `yaml:"service_name" json:"service_name"` // Name of the target resource (a service unless kind says otherwise)
+	Kind              string `yaml:"kind,omitempty" json:"kind,omitempty"`  // Target kind: service (default), pod, deployment or statefulset
+	Selector          string `yaml:"selector,omitempty" json:"selector,omitempty"` // Label selector; forwards to a Ready matching pod and fails over when it goes away
 	RemotePort        int    `yaml:"remote_port" json:"remote_port"`
 	LocalPort         int    `yaml:"local_port" json:"local_port"`
+	Ports             []PortMapping `yaml:"ports,omitempty" json:"ports,omitempty"` // Several mappings over one forward; the first mirrors remote_port/local_port
+	BindAddress       string `yaml:"bind_address,omitempty" json:"bind_address,omitempty"` // Local address to listen on (default: localhost), e.g. 127.0.0.2 or 0.0.0.0
+	Lazy              bool   `yaml:"lazy,omitempty" json:"lazy,omitempty"` // Defaults and presets arm the service; the forward starts on the first connection
 	SelectedByDefault bool   `yaml:"selected_by_default" json:"selected_by_default"`
 	Context           string `yaml:"context,omitempty" json:"context,omitempty"`
 	Namespace         string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
 	MaxRetries        *int   `yaml:"max_retries,omitempty" json:"max_retries,omitempty"`
+	ForwardEngine     string `yaml:"forward_engine,omitempty" json:"forward_engine,omitempty"`
+	ReadyTimeout      *int   `yaml:"ready_timeout,omitempty" json:"ready_timeout,omitempty"`
+	IdleTimeout       *int   `yaml:"idle_timeout,omitempty" json:"idle_timeout,omitempty"` // Minutes; overrides the global idle_timeout, 0 disables it
+	MaxLifetime       *int   `yaml:"max_lifetime,omitempty" json:"max_lifetime,omitempty"` // Hours; overrides the global max_lifetime, 0 disables it
+	RetryPolicy       *RetryPolicy `yaml:"retry_policy,omitempty" json:"retry_policy,omitempty"` // Overrides the fields it sets of the global retry_policy
+	Hooks             *Hooks `yaml:"hooks,omitempty" json:"hooks,omitempty"` // Shell commands run on start and stop
+	DependsOn         []string `yaml:"depends_on,omitempty" json:"depends_on,omitempty"` // Services or proxy services that must be ready before this one starts
 	SqlTapPort        *int   `yaml:"sql_tap_port,omitempty" json:"sql_tap_port,omitempty"`
 	SqlTapDriver      string `yaml:"sql_tap_driver,omitempty" json:"sql_tap_driver,omitempty"`
 	SqlTapGrpcPort    *int   `yaml:"sql_tap_grpc_port,omitempty" json:"sql_tap_grpc_port,omitempty"`
@@ -59,10 +294,16 @@ type ProxyService struct {
 	TargetHost        string `yaml:"target_host" json:"target_host"`
 	TargetPort        int    `yaml:"target_port" json:"target_port"`
 	LocalPort         int    `yaml:"local_port" json:"local_port"`
+	BindAddress       string `yaml:"bind_address,omitempty" json:"bind_address,omitempty"` // Local address to listen on (default: localhost)
 	SelectedByDefault bool   `yaml:"selected_by_default" json:"selected_by_default"`
 	ProxyPodContext   string `yaml:"proxy_pod_context" json:"proxy_pod_context"`
 	ProxyPodNamespace string `yaml:"proxy_pod_namespace" json:"proxy_pod_namespace"`
 	MaxRetries        *int   `yaml:"max_retries,omitempty" json:"max_retries,omitempty"`
+	ForwardEngine     string `yaml:"forward_engine,omitempty" json:"forward_engine,omitempty"`
+	ReadyTimeout      *int   `yaml:"ready_timeout,omitempty" json:"ready_timeout,omitempty"`
+	RetryPolicy       *RetryPolicy `yaml:"retry_policy,omitempty" json:"retry_policy,omitempty"` // Overrides the fields it sets of the global retry_policy
+	Hooks             *Hooks `yaml:"hooks,omitempty" json:"hooks,omitempty"` // Shell commands run on start and stop
+	DependsOn         []string `yaml:"depends_on,omitempty" json:"depends_on,omitempty"` // Services or proxy services that must be ready before this one starts
 	SqlTapPort        *int   `yaml:"sql_tap_port,omitempty" json:"sql_tap_port,omitempty"`
 	SqlTapDriver      string `yaml:"sql_tap_driver,omitempty" json:"sql_tap_driver,omitempty"`
 	SqlTapGrpcPort    *int   `yaml:"sql_tap_grpc_port,omitempty" json:"sql_tap_grpc_port,omitempty"`
@@ -77,6 +318,27 @@ func (ps *ProxyService) GetMaxRetries(globalMaxRetries int) int {
 	return globalMaxRetries
 }
 
+// GetForwardEngine returns the service-specific forward engine or falls back to the global engine
+func (ps *ProxyService) GetForwardEngine(globalEngine string) string {
+	if ps.ForwardEngine != "" {
+		return ps.ForwardEngine
+	}
+	return globalEngine
+}
+
+// GetReadyTimeout returns the service-specific ready timeout in seconds or falls back to the global timeout
+func (ps *ProxyService) GetReadyTimeout(globalReadyTimeout int) int {
+	if ps.ReadyTimeout != nil {
+		return *ps.ReadyTimeout
+	}
+	return globalReadyTimeout
+}
+
+// GetRetryPolicy returns the global retry policy with the service-specific fields applied
+func (ps *ProxyService) GetRetryPolicy(global RetryPolicy) RetryPolicy {
+	return global.Merge(ps.RetryPolicy)
+}
+
 // ProxyGroupKey returns the unique key for the context+namespace group this service belongs to
 func (ps *ProxyService) ProxyGroupKey() string {
 	return ps.ProxyPodContext + "/" + ps.ProxyPodNamespace
@@ -106,6 +368,69 @@ func (s *Service) GetMaxRetries(globalMaxRetries int) int {
 	return globalMaxRetries
 }
 
+// GetForwardEngine returns the service-specific forward engine or falls back to the global engine
+func (s *Service) GetForwardEngine(globalEngine string) string {
+	if s.ForwardEngine != "" {
+		return s.ForwardEngine
+	}
+	return globalEngine
+}
+
+// GetRetryPolicy returns the global retry policy with the service-specific fields applied
+func (s *Service) GetRetryPolicy(global RetryPolicy) RetryPolicy {
+	return global.Merge(s.RetryPolicy)
+}
+
+// GetKind returns the target kind, defaulting to service
+func (s *Service) GetKind() string {
+	if s.Kind == "" {
+		return TargetKindService
+	}
+	return s.Kind
+}
+
+// Resource returns the kubectl-style target, e.g. "service/api" or "pod/postgres-0".
+// Selector targets are shown as "pod/[app=api]" until a pod is picked.
+func (s *Service) Resource() string {
+	if s.Selector != "" {
+		return TargetKindPod + "/[" + s.Selector + "]"
+	}
+	return s.GetKind() + "/" + s.ServiceName
+}
+
+// PortMappings returns every port mapping of the service. Services without a ports
+// list have a single mapping built from remote_port/local_port.
+func (s *Service) PortMappings() []PortMapping {
+	if len(s.Ports) > 0 {
+		return s.Ports
+	}
+	return []PortMapping{{RemotePort: s.RemotePort, LocalPort: s.LocalPort}}
+}
+
+// GetReadyTimeout returns the service-specific ready timeout in seconds or falls back to the global timeout
+func (s *Service) GetReadyTimeout(globalReadyTimeout int) int {
+	if s.ReadyTimeout != nil {
+		return *s.ReadyTimeout
+	}
+	return globalReadyTimeout
+}
+
+// GetIdleTimeout returns the service-specific idle timeout in minutes or falls back to the global timeout
+func (s *Service) GetIdleTimeout(globalIdleTimeout int) int {
+	if s.IdleTimeout != nil {
+		return *s.IdleTimeout
+	}
+	return globalIdleTimeout
+}
+
+// GetMaxLifetime returns the service-specific maximum lifetime in hours or falls back to the global lifetime
+func (s *Service) GetMaxLifetime(globalMaxLifetime int) int {
+	if s.MaxLifetime != nil {
+		return *s.MaxLifetime
+	}
+	return globalMaxLifetime
+}
+
 // ApplyConfigDefaults sets default values for unset fields (before validation).
 func ApplyConfigDefaults(cfg *Config) {
 	if cfg.MaxRetries == 0 {
@@ -114,6 +439,27 @@ func ApplyConfigDefaults(cfg *Config) {
 	if cfg.WebPort == 0 {
 		cfg.WebPort = 8765
 	}
+	if cfg.ForwardEngine == "" {
+		cfg.ForwardEngine = ForwardEngineKubectl
+	}
+	if cfg.ReadyTimeout == 0 {
+		cfg.ReadyTimeout = 30
+	}
+	if cfg.HostsFile == "" {
+		cfg.HostsFile = "/etc/hosts"
+	}
+	if cfg.Orphans == "" {
+		cfg.Orphans = OrphansAsk
+	}
+	if cfg.RetryPolicy.InitialDelay == 0 {
+		cfg.RetryPolicy.InitialDelay = defaultRetryInitialDelay
+	}
+	if cfg.RetryPolicy.MaxDelay == 0 {
+		cfg.RetryPolicy.MaxDelay = defaultRetryMaxDelay
+	}
+	if cfg.RetryPolicy.Multiplier == 0 {
+		cfg.RetryPolicy.Multiplier = defaultRetryMultiplier
+	}
 	if cfg.ProxyPodName == "" {
 		cfg.ProxyPodName = "kubefwd-proxy"
 	}
@@ -126,6 +472,17 @@ func ApplyConfigDefaults(cfg *Config) {
 	if cfg.ProxyPodNamespace == "" {
 		cfg.ProxyPodNamespace = cfg.Namespace
 	}
+	for i := range cfg.Services {
+		// With a ports list, remote_port/local_port mirror the first mapping
+		if svc := &cfg.Services[i]; len(svc.Ports) > 0 {
+			if svc.RemotePort == 0 {
+				svc.RemotePort = svc.Ports[0].RemotePort
+			}
+			if svc.LocalPort == 0 {
+				svc.LocalPort = svc.Ports[0].LocalPort
+			}
+		}
+	}
 	for i := range cfg.ProxyServices {
 		if cfg.ProxyServices[i].ProxyPodContext == "" {
 			cfg.ProxyServices[i].ProxyPodContext = cfg.ProxyPodContext
@@ -144,6 +501,40 @@ func ValidateConfig(cfg *Config) error {
 	if cfg.Namespace == "" {
 		return fmt.Errorf("namespace is required")
 	}
+	if !isValidForwardEngine(cfg.ForwardEngine) {
+		return fmt.Errorf("forward_engine must be 'kubectl' or 'native'")
+	}
+	if cfg.ReadyTimeout < 0 {
+		return fmt.Errorf("ready_timeout must be a positive number of seconds")
+	}
+	if cfg.IdleTimeout < 0 {
+		return fmt.Errorf("idle_timeout must be a number of minutes (0 = never)")
+	}
+	if cfg.MaxLifetime < 0 {
+		return fmt.Errorf("max_lifetime must be a number of hours (0 = never)")
+	}
+	if cfg.HistoryDays < 0 {
+		return fmt.Errorf("history_days must be a number of days (0 = keep the history in memory only)")
+	}
+	if !isValidOrphanAction(cfg.Orphans) {
+		return fmt.Errorf("orphans must be 'ask', 'adopt', 'cleanup' or 'ignore'")
+	}
+	if err := cfg.RetryPolicy.validate(""); err != nil {
+		return err
+	}
+	if cfg.RetryPolicy.MaxDelay < cfg.RetryPolicy.InitialDelay {
+		return fmt.Errorf("retry_policy.max_delay must not be lower than initial_delay")
+	}
+	if cfg.DNSAddress != "" {
+		if _, _, err := net.SplitHostPort(cfg.DNSAddress); err != nil {
+			return fmt.Errorf("dns_address must be host:port: %v", err)
+		}
+	}
+	if cfg.DNSUpstream != "" {
+		if _, _, err := net.SplitHostPort(cfg.DNSUpstream); err != nil {
+			return fmt.Errorf("dns_upstream must be host:port: %v", err)
+		}
+	}
 	if len(cfg.Services) == 0 && len(cfg.ProxyServices) == 0 {
 		return fmt.Errorf("at least one service or proxy service must be defined")
 	}
@@ -152,8 +543,19 @@ func ValidateConfig(cfg *Config) error {
 		if svc.Name == "" {
 			return fmt.Errorf("service %d: name is required", i)
 		}
-		if svc.ServiceName == "" {
-			return fmt.Errorf("service %d (%s): service_name is required", i, svc.Name)
+		if svc.ServiceName == "" && svc.Selector == "" {
+			return fmt.Errorf("service %d (%s): service_name or selector is required", i, svc.Name)
+		}
+		if svc.Selector != "" {
+			if svc.ServiceName != "" || svc.Kind != "" {
+				return fmt.Errorf("service %d (%s): selector cannot be combined with service_name or kind", i, svc.Name)
+			}
+			if _, err := labels.Parse(svc.Selector); err != nil {
+				return fmt.Errorf("service %d (%s): invalid selector: %v", i, svc.Name, err)
+			}
+		}
+		if svc.Kind != "" && !isValidTargetKind(svc.Kind) {
+			return fmt.Errorf("service %d (%s): kind must be 'service', 'pod', 'deployment' or 'statefulset'", i, svc.Name)
 		}
 		if svc.RemotePort <= 0 || svc.RemotePort > 65535 {
 			return fmt.Errorf("service %d (%s): invalid remote_port", i, svc.Name)
@@ -161,6 +563,52 @@ func ValidateConfig(cfg *Config) error {
 		if svc.LocalPort <= 0 || svc.LocalPort > 65535 {
 			return fmt.Errorf("service %d (%s): invalid local_port", i, svc.Name)
 		}
+		if len(svc.Ports) > 0 {
+			if svc.Ports[0].RemotePort != svc.RemotePort || svc.Ports[0].LocalPort != svc.LocalPort {
+				return fmt.Errorf("service %d (%s): remote_port/local_port must match the first entry of ports", i, svc.Name)
+			}
+			seen := make(map[int]bool)
+			for j, pm := range svc.Ports {
+				if pm.RemotePort <= 0 || pm.RemotePort > 65535 {
+					return fmt.Errorf("service %d (%s): ports[%d]: invalid remote_port", i, svc.Name, j)
+				}
+				if pm.LocalPort <= 0 || pm.LocalPort > 65535 {
+					return fmt.Errorf("service %d (%s): ports[%d]: invalid local_port", i, svc.Name, j)
+				}
+				if seen[pm.LocalPort] {
+					return fmt.Errorf("service %d (%s): ports[%d]: local_port %d is used twice", i, svc.Name, j, pm.LocalPort)
+				}
+				seen[pm.LocalPort] = true
+				if svc.SqlTapPort != nil && *svc.SqlTapPort == pm.LocalPort {
+					return fmt.Errorf("service %d (%s): sql_tap_port cannot be the same as a local port", i, svc.Name)
+				}
+			}
+		}
+		if !isValidBindAddress(svc.BindAddress) {
+			return fmt.Errorf("service %d (%s): bind_address must be an IP address or 'localhost'", i, svc.Name)
+		}
+		if svc.ForwardEngine != "" && !isValidForwardEngine(svc.ForwardEngine) {
+			return fmt.Errorf("service %d (%s): forward_engine must be 'kubectl' or 'native'", i, svc.Name)
+		}
+		if svc.ReadyTimeout != nil && *svc.ReadyTimeout <= 0 {
+			return fmt.Errorf("service %d (%s): ready_timeout must be a positive number of seconds", i, svc.Name)
+		}
+		if svc.IdleTimeout != nil && *svc.IdleTimeout < 0 {
+			return fmt.Errorf("service %d (%s): idle_timeout must be a number of minutes (0 = never)", i, svc.Name)
+		}
+		if svc.MaxLifetime != nil && *svc.MaxLifetime < 0 {
+			return fmt.Errorf("service %d (%s): max_lifetime must be a number of hours (0 = never)", i, svc.Name)
+		}
+		if svc.RetryPolicy != nil {
+			if err := svc.RetryPolicy.validate(fmt.Sprintf("service %d (%s): ", i, svc.Name)); err != nil {
+				return err
+			}
+		}
+		if svc.Hooks != nil {
+			if err := svc.Hooks.validate(fmt.Sprintf("service %d (%s): ", i, svc.Name)); err != nil {
+				return err
+			}
+		}
 		if svc.SqlTapPort != nil {
 			if *svc.SqlTapPort <= 0 || *svc.SqlTapPort > 65535 {
 				return fmt.Errorf("service %d (%s): invalid sql_tap_port", i, svc.Name)
@@ -212,6 +660,25 @@ func ValidateConfig(cfg *Config) error {
 		if pxSvc.ProxyPodNamespace == "" {
 			return fmt.Errorf("proxy_service %d (%s): proxy_pod_namespace is required", i, pxSvc.Name)
 		}
+		if !isValidBindAddress(pxSvc.BindAddress) {
+			return fmt.Errorf("proxy_service %d (%s): bind_address must be an IP address or 'localhost'", i, pxSvc.Name)
+		}
+		if pxSvc.ForwardEngine != "" && !isValidForwardEngine(pxSvc.ForwardEngine) {
+			return fmt.Errorf("proxy_service %d (%s): forward_engine must be 'kubectl' or 'native'", i, pxSvc.Name)
+		}
+		if pxSvc.ReadyTimeout != nil && *pxSvc.ReadyTimeout <= 0 {
+			return fmt.Errorf("proxy_service %d (%s): ready_timeout must be a positive number of seconds", i, pxSvc.Name)
+		}
+		if pxSvc.RetryPolicy != nil {
+			if err := pxSvc.RetryPolicy.validate(fmt.Sprintf("proxy_service %d (%s): ", i, pxSvc.Name)); err != nil {
+				return err
+			}
+		}
+		if pxSvc.Hooks != nil {
+			if err := pxSvc.Hooks.validate(fmt.Sprintf("proxy_service %d (%s): ", i, pxSvc.Name)); err != nil {
+				return err
+			}
+		}
 		if pxSvc.SqlTapPort != nil {
 			if *pxSvc.SqlTapPort <= 0 || *pxSvc.SqlTapPort > 65535 {
 				return fmt.Errorf("proxy_service %d (%s): invalid sql_tap_port", i, pxSvc.Name)
@@ -244,6 +711,33 @@ func ValidateConfig(cfg *Config) error {
 		}
 	}
 
+	if err := validateDependencies(cfg); err != nil {
+		return err
+	}
+
+	notifierNames := make(map[string]bool)
+	for i := range cfg.Notifiers {
+		n := &cfg.Notifiers[i]
+		if err := n.validate(i); err != nil {
+			return err
+		}
+		if notifierNames[n.Name] {
+			return fmt.Errorf("notifier %d (%s): name is used twice", i, n.Name)
+		}
+		notifierNames[n.Name] = true
+	}
+
+	// Two listeners collide when they share a port and their addresses overlap
+	// (e.g. 0.0.0.0:5432 and 127.0.0.2:5432); distinct loopback IPs may share a port
+	ports := GetAllPortsFromConfig(cfg)
+	for i, a := range ports {
+		for _, b := range ports[i+1:] {
+			if a.Port == b.Port && addressesOverlap(a.Address, b.Address) {
+				return fmt.Errorf("local port %d is used by both %s and %s on %s", a.Port, a.ServiceName, b.ServiceName, displayBindAddress(b.Address))
+			}
+		}
+	}
+
 	return nil
 }
 
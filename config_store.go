@@ -2,17 +2,19 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 	_ "modernc.org/sqlite"
 )
 
-const currentSchemaVersion = 1
+const currentSchemaVersion = 19
 
 // ConfigStore loads and persists configuration (YAML file or SQLite).
 type ConfigStore interface {
@@ -42,9 +44,16 @@ func cloneConfig(cfg *Config) *Config {
 	c.Services = append([]Service(nil), cfg.Services...)
 	c.ProxyServices = append([]ProxyService(nil), cfg.ProxyServices...)
 	c.AlternativeContexts = append([]AlternativeContext(nil), cfg.AlternativeContexts...)
+	c.Notifiers = make([]Notifier, len(cfg.Notifiers))
+	for i, n := range cfg.Notifiers {
+		n.Events = append([]string(nil), n.Events...)
+		n.Services = append([]string(nil), n.Services...)
+		c.Notifiers[i] = n
+	}
 	c.Presets = make([]Preset, len(cfg.Presets))
 	for i := range cfg.Presets {
 		c.Presets[i].Name = cfg.Presets[i].Name
+		c.Presets[i].Lazy = cfg.Presets[i].Lazy
 		c.Presets[i].Services = append([]string(nil), cfg.Presets[i].Services...)
 	}
 	return &c
@@ -86,6 +95,56 @@ func (f *FileConfigStore) Save(cfg *Config) error {
 	return nil
 }
 
+// sessionPath is the file the session is saved in: the config path with the
+// extension replaced, e.g. ~/.kubefwd.session.json
+func (f *FileConfigStore) sessionPath() string {
+	return strings.TrimSuffix(f.Path, filepath.Ext(f.Path)) + ".session.json"
+}
+
+// SaveSession writes the session as JSON next to the config file
+func (f *FileConfigStore) SaveSession(s session) error {
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := f.sessionPath()
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".kubefwd-session-*.json")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("replace session file: %w", err)
+	}
+	return nil
+}
+
+// LoadSession reads the session file; it returns nil if there is none yet
+func (f *FileConfigStore) LoadSession() (*session, error) {
+	data, err := os.ReadFile(f.sessionPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", f.sessionPath(), err)
+	}
+	return &s, nil
+}
+
 // --- SQLite ---
 
 // SQLiteConfigStore persists config in normalized tables.
@@ -127,6 +186,96 @@ func migrateSQLite(db *sql.DB) error {
 			return err
 		}
 	}
+	if int(v.Int64) < 2 {
+		if err := migrateSchemaV2(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 3 {
+		if err := migrateSchemaV3(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 4 {
+		if err := migrateSchemaV4(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 5 {
+		if err := migrateSchemaV5(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 6 {
+		if err := migrateSchemaV6(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 7 {
+		if err := migrateSchemaV7(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 8 {
+		if err := migrateSchemaV8(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 9 {
+		if err := migrateSchemaV9(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 10 {
+		if err := migrateSchemaV10(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 11 {
+		if err := migrateSchemaV11(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 12 {
+		if err := migrateSchemaV12(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 13 {
+		if err := migrateSchemaV13(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 14 {
+		if err := migrateSchemaV14(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 15 {
+		if err := migrateSchemaV15(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 16 {
+		if err := migrateSchemaV16(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 17 {
+		if err := migrateSchemaV17(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 18 {
+		if err := migrateSchemaV18(db); err != nil {
+			return err
+		}
+	}
+	if int(v.Int64) < 19 {
+		if err := migrateSchemaV19(db); err != nil {
+			return err
+		}
+	}
 	if _, err := db.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, currentSchemaVersion)); err != nil {
 		return err
 	}
@@ -204,6 +353,328 @@ func createSchemaV1(db *sql.DB) error {
 	return nil
 }
 
+// migrateSchemaV2 adds the forward_engine columns.
+func migrateSchemaV2(db *sql.DB) error {
+	stmts := []string{
+		`ALTER TABLE settings ADD COLUMN forward_engine TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE services ADD COLUMN forward_engine TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE proxy_services ADD COLUMN forward_engine TEXT NOT NULL DEFAULT ''`,
+	}
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			return fmt.Errorf("schema v2: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV3 adds the ready_timeout columns.
+func migrateSchemaV3(db *sql.DB) error {
+	stmts := []string{
+		`ALTER TABLE settings ADD COLUMN ready_timeout INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE services ADD COLUMN ready_timeout INTEGER`,
+		`ALTER TABLE proxy_services ADD COLUMN ready_timeout INTEGER`,
+	}
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			return fmt.Errorf("schema v3: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV4 adds service_ports for services with several port mappings.
+// Single-port services keep using remote_port/local_port on services and have no rows.
+func migrateSchemaV4(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS service_ports (
+		service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+		sort_order INTEGER NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		remote_port INTEGER NOT NULL,
+		local_port INTEGER NOT NULL,
+		PRIMARY KEY (service_id, sort_order)
+	)`)
+	if err != nil {
+		return fmt.Errorf("schema v4: %w", err)
+	}
+	return nil
+}
+
+// migrateSchemaV5 adds the target kind of services.
+func migrateSchemaV5(db *sql.DB) error {
+	if _, err := db.Exec(`ALTER TABLE services ADD COLUMN kind TEXT NOT NULL DEFAULT ''`); err != nil {
+		return fmt.Errorf("schema v5: %w", err)
+	}
+	return nil
+}
+
+// migrateSchemaV6 adds label-selector targets of services.
+func migrateSchemaV6(db *sql.DB) error {
+	if _, err := db.Exec(`ALTER TABLE services ADD COLUMN selector TEXT NOT NULL DEFAULT ''`); err != nil {
+		return fmt.Errorf("schema v6: %w", err)
+	}
+	return nil
+}
+
+// migrateSchemaV7 adds the bind_address columns.
+func migrateSchemaV7(db *sql.DB) error {
+	stmts := []string{
+		`ALTER TABLE services ADD COLUMN bind_address TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE proxy_services ADD COLUMN bind_address TEXT NOT NULL DEFAULT ''`,
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("schema v7: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV8 adds the hosts file settings.
+func migrateSchemaV8(db *sql.DB) error {
+	stmts := []string{
+		`ALTER TABLE settings ADD COLUMN manage_hosts INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE settings ADD COLUMN hosts_file TEXT NOT NULL DEFAULT ''`,
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("schema v8: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV9 adds the DNS server settings.
+func migrateSchemaV9(db *sql.DB) error {
+	stmts := []string{
+		`ALTER TABLE settings ADD COLUMN dns_address TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE settings ADD COLUMN dns_upstream TEXT NOT NULL DEFAULT ''`,
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("schema v9: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV10 adds lazy (armed) services and presets.
+func migrateSchemaV10(db *sql.DB) error {
+	stmts := []string{
+		`ALTER TABLE services ADD COLUMN lazy INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE presets ADD COLUMN lazy INTEGER NOT NULL DEFAULT 0`,
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("schema v10: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV11 adds idle_timeout and max_lifetime.
+func migrateSchemaV11(db *sql.DB) error {
+	stmts := []string{
+		`ALTER TABLE settings ADD COLUMN idle_timeout INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE settings ADD COLUMN max_lifetime INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE services ADD COLUMN idle_timeout INTEGER`,
+		`ALTER TABLE services ADD COLUMN max_lifetime INTEGER`,
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("schema v11: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV12 adds the retry policy. Per-service columns are NULL when the
+// service inherits that field from the global policy.
+func migrateSchemaV12(db *sql.DB) error {
+	stmts := []string{
+		`ALTER TABLE settings ADD COLUMN retry_initial_delay REAL NOT NULL DEFAULT 0`,
+		`ALTER TABLE settings ADD COLUMN retry_max_delay REAL NOT NULL DEFAULT 0`,
+		`ALTER TABLE settings ADD COLUMN retry_multiplier REAL NOT NULL DEFAULT 0`,
+		`ALTER TABLE settings ADD COLUMN retry_jitter REAL NOT NULL DEFAULT 0`,
+		`ALTER TABLE services ADD COLUMN retry_initial_delay REAL`,
+		`ALTER TABLE services ADD COLUMN retry_max_delay REAL`,
+		`ALTER TABLE services ADD COLUMN retry_multiplier REAL`,
+		`ALTER TABLE services ADD COLUMN retry_jitter REAL`,
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("schema v12: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV13 adds the per-service retry policy to proxy services.
+func migrateSchemaV13(db *sql.DB) error {
+	stmts := []string{
+		`ALTER TABLE proxy_services ADD COLUMN retry_initial_delay REAL`,
+		`ALTER TABLE proxy_services ADD COLUMN retry_max_delay REAL`,
+		`ALTER TABLE proxy_services ADD COLUMN retry_multiplier REAL`,
+		`ALTER TABLE proxy_services ADD COLUMN retry_jitter REAL`,
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("schema v13: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV14 adds lifecycle hooks: one row per command, in order within a phase.
+func migrateSchemaV14(db *sql.DB) error {
+	stmts := []string{
+		`ALTER TABLE services ADD COLUMN hook_timeout INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE services ADD COLUMN hook_fail_on_post_start INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE proxy_services ADD COLUMN hook_timeout INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE proxy_services ADD COLUMN hook_fail_on_post_start INTEGER NOT NULL DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS service_hooks (
+			service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+			phase TEXT NOT NULL,
+			sort_order INTEGER NOT NULL,
+			command TEXT NOT NULL,
+			PRIMARY KEY (service_id, phase, sort_order)
+		)`,
+		`CREATE TABLE IF NOT EXISTS proxy_service_hooks (
+			proxy_service_id INTEGER NOT NULL REFERENCES proxy_services(id) ON DELETE CASCADE,
+			phase TEXT NOT NULL,
+			sort_order INTEGER NOT NULL,
+			command TEXT NOT NULL,
+			PRIMARY KEY (proxy_service_id, phase, sort_order)
+		)`,
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("schema v14: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV15 adds the status history. It is runtime data, not config, so Save
+// leaves it alone.
+func migrateSchemaV15(db *sql.DB) error {
+	stmts := []string{
+		`ALTER TABLE settings ADD COLUMN history_days INTEGER NOT NULL DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS status_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			time_ms INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			name TEXT NOT NULL,
+			from_status TEXT NOT NULL,
+			to_status TEXT NOT NULL,
+			reason TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS status_history_time ON status_history(time_ms)`,
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("schema v15: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV16 adds notifiers with their event and service filters.
+func migrateSchemaV16(db *sql.DB) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS notifiers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			sort_order INTEGER NOT NULL,
+			name TEXT NOT NULL UNIQUE,
+			type TEXT NOT NULL,
+			command TEXT NOT NULL DEFAULT '',
+			url TEXT NOT NULL DEFAULT '',
+			rate_limit INTEGER
+		)`,
+		`CREATE TABLE IF NOT EXISTS notifier_events (
+			notifier_id INTEGER NOT NULL REFERENCES notifiers(id) ON DELETE CASCADE,
+			sort_order INTEGER NOT NULL,
+			event TEXT NOT NULL,
+			PRIMARY KEY (notifier_id, sort_order)
+		)`,
+		`CREATE TABLE IF NOT EXISTS notifier_services (
+			notifier_id INTEGER NOT NULL REFERENCES notifiers(id) ON DELETE CASCADE,
+			sort_order INTEGER NOT NULL,
+			service_name TEXT NOT NULL,
+			PRIMARY KEY (notifier_id, sort_order)
+		)`,
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("schema v16: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV17 adds depends_on of services and proxy services. Entries are names,
+// as the other side may be a service or a proxy service.
+func migrateSchemaV17(db *sql.DB) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS service_dependencies (
+			service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+			sort_order INTEGER NOT NULL,
+			depends_on TEXT NOT NULL,
+			PRIMARY KEY (service_id, sort_order)
+		)`,
+		`CREATE TABLE IF NOT EXISTS proxy_service_dependencies (
+			proxy_service_id INTEGER NOT NULL REFERENCES proxy_services(id) ON DELETE CASCADE,
+			sort_order INTEGER NOT NULL,
+			depends_on TEXT NOT NULL,
+			PRIMARY KEY (proxy_service_id, sort_order)
+		)`,
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("schema v17: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV18 adds the saved session. Like the status history it is runtime
+// data, so Save leaves it alone.
+func migrateSchemaV18(db *sql.DB) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS session (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			cluster_context TEXT NOT NULL DEFAULT '',
+			cluster_name TEXT NOT NULL DEFAULT '',
+			restore_on_start INTEGER NOT NULL DEFAULT 0,
+			saved_ms INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS session_forwards (
+			sort_order INTEGER PRIMARY KEY,
+			kind TEXT NOT NULL,
+			name TEXT NOT NULL
+		)`,
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("schema v18: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaV19 adds what to do with the leftovers of a killed kubefwd
+func migrateSchemaV19(db *sql.DB) error {
+	stmts := []string{
+		`ALTER TABLE settings ADD COLUMN orphans TEXT NOT NULL DEFAULT ''`,
+	}
+	for _, q := range stmts {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("schema v19: %w", err)
+		}
+	}
+	return nil
+}
+
 // NewSQLiteConfigStore opens (and creates) a SQLite database at Path.
 func NewSQLiteConfigStore(path string) (*SQLiteConfigStore, error) {
 	db, err := openSQLite(path)
@@ -246,6 +717,181 @@ func optionalIntPtr(v *int) interface{} {
 	return *v
 }
 
+// sqlRetryPolicy builds a per-service retry policy from its nullable columns (nil when
+// the service inherits the whole global policy)
+func sqlRetryPolicy(initial, max, mult, jitter sql.NullFloat64) *RetryPolicy {
+	if !initial.Valid && !max.Valid && !mult.Valid && !jitter.Valid {
+		return nil
+	}
+	return &RetryPolicy{InitialDelay: initial.Float64, MaxDelay: max.Float64, Multiplier: mult.Float64, Jitter: jitter.Float64}
+}
+
+// retryPolicyColumns returns the nullable column values of a per-service retry policy;
+// unset fields are stored as NULL
+func retryPolicyColumns(p *RetryPolicy) []interface{} {
+	if p == nil {
+		return []interface{}{nil, nil, nil, nil}
+	}
+	cols := []interface{}{}
+	for _, v := range []float64{p.InitialDelay, p.MaxDelay, p.Multiplier, p.Jitter} {
+		if v == 0 {
+			cols = append(cols, nil)
+		} else {
+			cols = append(cols, v)
+		}
+	}
+	return cols
+}
+
+// hookPhases lists the hook phases in the order they are stored
+var hookPhases = []string{HookPreStart, HookPostStart, HookPreStop, HookPostStop}
+
+// loadHooks reads the hook commands of the row id from table (service_hooks or
+// proxy_service_hooks). timeout and failOnPostStart come from the service row. It
+// returns nil when the service has no hooks.
+func (s *SQLiteConfigStore) loadHooks(table, idColumn string, id int64, timeout, failOnPostStart int) (*Hooks, error) {
+	rows, err := s.db.Query(fmt.Sprintf(`SELECT phase, command FROM %s WHERE %s = ? ORDER BY phase, sort_order`, table, idColumn), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	h := &Hooks{Timeout: timeout, FailOnPostStart: intToBool(failOnPostStart)}
+	for rows.Next() {
+		var phase, command string
+		if err := rows.Scan(&phase, &command); err != nil {
+			return nil, err
+		}
+		switch phase {
+		case HookPreStart:
+			h.PreStart = append(h.PreStart, command)
+		case HookPostStart:
+			h.PostStart = append(h.PostStart, command)
+		case HookPreStop:
+			h.PreStop = append(h.PreStop, command)
+		case HookPostStop:
+			h.PostStop = append(h.PostStop, command)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if h.Timeout == 0 && !h.FailOnPostStart && len(h.PreStart)+len(h.PostStart)+len(h.PreStop)+len(h.PostStop) == 0 {
+		return nil, nil
+	}
+	return h, nil
+}
+
+// hookColumns returns the hook_timeout and hook_fail_on_post_start values of h
+func hookColumns(h *Hooks) []interface{} {
+	if h == nil {
+		return []interface{}{0, 0}
+	}
+	return []interface{}{h.Timeout, boolToInt(h.FailOnPostStart)}
+}
+
+// saveHooks writes the hook commands of the row id to table
+func saveHooks(tx *sql.Tx, table, idColumn string, id int64, h *Hooks) error {
+	if h == nil {
+		return nil
+	}
+	for _, phase := range hookPhases {
+		for i, command := range h.Commands(phase) {
+			_, err := tx.Exec(fmt.Sprintf(`INSERT INTO %s (%s, phase, sort_order, command) VALUES (?, ?, ?, ?)`, table, idColumn),
+				id, phase, i, command)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadNotifiers reads the notifiers in their configured order
+func (s *SQLiteConfigStore) loadNotifiers() ([]Notifier, error) {
+	rows, err := s.db.Query(`SELECT id, name, type, command, url, rate_limit FROM notifiers ORDER BY sort_order, id`)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	var out []Notifier
+	for rows.Next() {
+		var id int64
+		var n Notifier
+		var rateLimit sql.NullInt64
+		if err := rows.Scan(&id, &n.Name, &n.Type, &n.Command, &n.URL, &rateLimit); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		n.RateLimit = sqlIntPtr(rateLimit)
+		ids = append(ids, id)
+		out = append(out, n)
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		if out[i].Events, err = s.loadStrings(`SELECT event FROM notifier_events WHERE notifier_id = ? ORDER BY sort_order`, id); err != nil {
+			return nil, err
+		}
+		if out[i].Services, err = s.loadStrings(`SELECT service_name FROM notifier_services WHERE notifier_id = ? ORDER BY sort_order`, id); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// loadStrings returns the single text column of query's rows
+func (s *SQLiteConfigStore) loadStrings(query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// saveNotifier inserts notifier n at position i with its filters
+func saveNotifier(tx *sql.Tx, i int, n Notifier) error {
+	res, err := tx.Exec(`INSERT INTO notifiers (sort_order, name, type, command, url, rate_limit) VALUES (?, ?, ?, ?, ?, ?)`,
+		i, n.Name, n.Type, n.Command, n.URL, optionalIntPtr(n.RateLimit))
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for j, e := range n.Events {
+		if _, err := tx.Exec(`INSERT INTO notifier_events (notifier_id, sort_order, event) VALUES (?, ?, ?)`, id, j, e); err != nil {
+			return err
+		}
+	}
+	for j, sn := range n.Services {
+		if _, err := tx.Exec(`INSERT INTO notifier_services (notifier_id, sort_order, service_name) VALUES (?, ?, ?)`, id, j, sn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveDependencies inserts the depends_on names of the row id into table
+// (service_dependencies or proxy_service_dependencies)
+func saveDependencies(tx *sql.Tx, table, idColumn string, id int64, deps []string) error {
+	for i, name := range deps {
+		if _, err := tx.Exec(`INSERT INTO `+table+` (`+idColumn+`, sort_order, depends_on) VALUES (?, ?, ?)`, id, i, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Load reads all tables and returns a validated Config.
 func (s *SQLiteConfigStore) Load() (*Config, error) {
 	var count int
@@ -258,14 +904,20 @@ func (s *SQLiteConfigStore) Load() (*Config, error) {
 
 	cfg := &Config{}
 
+	var manageHosts int
 	row := s.db.QueryRow(`SELECT cluster_context, cluster_name, namespace, max_retries, web_port,
-		proxy_pod_name, proxy_pod_image, proxy_pod_context, proxy_pod_namespace FROM settings WHERE id = 1`)
+		proxy_pod_name, proxy_pod_image, proxy_pod_context, proxy_pod_namespace, forward_engine, ready_timeout,
+		manage_hosts, hosts_file, dns_address, dns_upstream, idle_timeout, max_lifetime,
+		retry_initial_delay, retry_max_delay, retry_multiplier, retry_jitter, history_days, orphans FROM settings WHERE id = 1`)
 	if err := row.Scan(
 		&cfg.ClusterContext, &cfg.ClusterName, &cfg.Namespace, &cfg.MaxRetries, &cfg.WebPort,
-		&cfg.ProxyPodName, &cfg.ProxyPodImage, &cfg.ProxyPodContext, &cfg.ProxyPodNamespace,
+		&cfg.ProxyPodName, &cfg.ProxyPodImage, &cfg.ProxyPodContext, &cfg.ProxyPodNamespace, &cfg.ForwardEngine, &cfg.ReadyTimeout,
+		&manageHosts, &cfg.HostsFile, &cfg.DNSAddress, &cfg.DNSUpstream, &cfg.IdleTimeout, &cfg.MaxLifetime,
+		&cfg.RetryPolicy.InitialDelay, &cfg.RetryPolicy.MaxDelay, &cfg.RetryPolicy.Multiplier, &cfg.RetryPolicy.Jitter, &cfg.HistoryDays, &cfg.Orphans,
 	); err != nil {
 		return nil, err
 	}
+	cfg.ManageHosts = intToBool(manageHosts)
 
 	acRows, err := s.db.Query(`SELECT name, context FROM alternative_contexts ORDER BY sort_order, id`)
 	if err != nil {
@@ -281,18 +933,19 @@ func (s *SQLiteConfigStore) Load() (*Config, error) {
 	}
 	acRows.Close()
 
-	presetRows, err := s.db.Query(`SELECT id, name FROM presets ORDER BY sort_order, id`)
+	presetRows, err := s.db.Query(`SELECT id, name, lazy FROM presets ORDER BY sort_order, id`)
 	if err != nil {
 		return nil, err
 	}
 	type presetRow struct {
 		id   int64
 		name string
+		lazy int
 	}
 	var presetList []presetRow
 	for presetRows.Next() {
 		var pr presetRow
-		if err := presetRows.Scan(&pr.id, &pr.name); err != nil {
+		if err := presetRows.Scan(&pr.id, &pr.name, &pr.lazy); err != nil {
 			presetRows.Close()
 			return nil, err
 		}
@@ -315,65 +968,137 @@ func (s *SQLiteConfigStore) Load() (*Config, error) {
 			names = append(names, n)
 		}
 		svRows.Close()
-		cfg.Presets = append(cfg.Presets, Preset{Name: pr.name, Services: names})
+		cfg.Presets = append(cfg.Presets, Preset{Name: pr.name, Services: names, Lazy: intToBool(pr.lazy)})
 	}
 
-	svcRows, err := s.db.Query(`SELECT name, service_name, remote_port, local_port, selected_by_default,
-		context, namespace, max_retries, sql_tap_port, sql_tap_driver, sql_tap_grpc_port, sql_tap_http_port
+	notifiers, err := s.loadNotifiers()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Notifiers = notifiers
+
+	svcRows, err := s.db.Query(`SELECT id, name, service_name, kind, selector, remote_port, local_port, bind_address, lazy, selected_by_default,
+		context, namespace, max_retries, forward_engine, ready_timeout, idle_timeout, max_lifetime, sql_tap_port, sql_tap_driver, sql_tap_grpc_port, sql_tap_http_port,
+		retry_initial_delay, retry_max_delay, retry_multiplier, retry_jitter, hook_timeout, hook_fail_on_post_start
 		FROM services ORDER BY name`)
 	if err != nil {
 		return nil, err
 	}
+	var svcIDs []int64
+	var svcHookCols [][2]int
 	for svcRows.Next() {
 		var sv Service
-		var maxR, stp, stg, sth sql.NullInt64
+		var id int64
+		var maxR, rt, idle, life, stp, stg, sth sql.NullInt64
+		var rInit, rMax, rMult, rJitter sql.NullFloat64
 		var drv string
-		var sel int
-		if err := svcRows.Scan(&sv.Name, &sv.ServiceName, &sv.RemotePort, &sv.LocalPort, &sel,
-			&sv.Context, &sv.Namespace, &maxR, &stp, &drv, &stg, &sth); err != nil {
+		var sel, lazy, hookTimeout, hookFail int
+		if err := svcRows.Scan(&id, &sv.Name, &sv.ServiceName, &sv.Kind, &sv.Selector, &sv.RemotePort, &sv.LocalPort, &sv.BindAddress, &lazy, &sel,
+			&sv.Context, &sv.Namespace, &maxR, &sv.ForwardEngine, &rt, &idle, &life, &stp, &drv, &stg, &sth,
+			&rInit, &rMax, &rMult, &rJitter, &hookTimeout, &hookFail); err != nil {
 			svcRows.Close()
 			return nil, err
 		}
 		sv.SelectedByDefault = intToBool(sel)
+		sv.Lazy = intToBool(lazy)
 		sv.MaxRetries = sqlIntPtr(maxR)
+		sv.ReadyTimeout = sqlIntPtr(rt)
+		sv.IdleTimeout = sqlIntPtr(idle)
+		sv.MaxLifetime = sqlIntPtr(life)
 		sv.SqlTapPort = sqlIntPtr(stp)
 		sv.SqlTapGrpcPort = sqlIntPtr(stg)
 		sv.SqlTapHttpPort = sqlIntPtr(sth)
+		sv.RetryPolicy = sqlRetryPolicy(rInit, rMax, rMult, rJitter)
 		if drv != "" {
 			sv.SqlTapDriver = drv
 		}
 		cfg.Services = append(cfg.Services, sv)
+		svcIDs = append(svcIDs, id)
+		svcHookCols = append(svcHookCols, [2]int{hookTimeout, hookFail})
 	}
 	svcRows.Close()
 
-	pxRows, err := s.db.Query(`SELECT name, target_host, target_port, local_port, selected_by_default,
-		proxy_pod_context, proxy_pod_namespace, max_retries, sql_tap_port, sql_tap_driver, sql_tap_grpc_port, sql_tap_http_port
+	for i, id := range svcIDs {
+		portRows, err := s.db.Query(`SELECT name, remote_port, local_port FROM service_ports
+			WHERE service_id = ? ORDER BY sort_order`, id)
+		if err != nil {
+			return nil, err
+		}
+		for portRows.Next() {
+			var pm PortMapping
+			if err := portRows.Scan(&pm.Name, &pm.RemotePort, &pm.LocalPort); err != nil {
+				portRows.Close()
+				return nil, err
+			}
+			cfg.Services[i].Ports = append(cfg.Services[i].Ports, pm)
+		}
+		portRows.Close()
+
+		hooks, err := s.loadHooks("service_hooks", "service_id", id, svcHookCols[i][0], svcHookCols[i][1])
+		if err != nil {
+			return nil, err
+		}
+		cfg.Services[i].Hooks = hooks
+
+		deps, err := s.loadStrings(`SELECT depends_on FROM service_dependencies WHERE service_id = ? ORDER BY sort_order`, id)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Services[i].DependsOn = deps
+	}
+
+	pxRows, err := s.db.Query(`SELECT id, name, target_host, target_port, local_port, bind_address, selected_by_default,
+		proxy_pod_context, proxy_pod_namespace, max_retries, forward_engine, ready_timeout, sql_tap_port, sql_tap_driver, sql_tap_grpc_port, sql_tap_http_port,
+		retry_initial_delay, retry_max_delay, retry_multiplier, retry_jitter, hook_timeout, hook_fail_on_post_start
 		FROM proxy_services ORDER BY proxy_pod_context, proxy_pod_namespace, name`)
 	if err != nil {
 		return nil, err
 	}
+	var pxIDs []int64
+	var pxHookCols [][2]int
 	for pxRows.Next() {
 		var ps ProxyService
-		var maxR, stp, stg, sth sql.NullInt64
+		var id int64
+		var maxR, rt, stp, stg, sth sql.NullInt64
+		var rInit, rMax, rMult, rJitter sql.NullFloat64
 		var drv string
-		var sel int
-		if err := pxRows.Scan(&ps.Name, &ps.TargetHost, &ps.TargetPort, &ps.LocalPort, &sel,
-			&ps.ProxyPodContext, &ps.ProxyPodNamespace, &maxR, &stp, &drv, &stg, &sth); err != nil {
+		var sel, hookTimeout, hookFail int
+		if err := pxRows.Scan(&id, &ps.Name, &ps.TargetHost, &ps.TargetPort, &ps.LocalPort, &ps.BindAddress, &sel,
+			&ps.ProxyPodContext, &ps.ProxyPodNamespace, &maxR, &ps.ForwardEngine, &rt, &stp, &drv, &stg, &sth,
+			&rInit, &rMax, &rMult, &rJitter, &hookTimeout, &hookFail); err != nil {
 			pxRows.Close()
 			return nil, err
 		}
 		ps.SelectedByDefault = intToBool(sel)
 		ps.MaxRetries = sqlIntPtr(maxR)
+		ps.ReadyTimeout = sqlIntPtr(rt)
 		ps.SqlTapPort = sqlIntPtr(stp)
 		ps.SqlTapGrpcPort = sqlIntPtr(stg)
 		ps.SqlTapHttpPort = sqlIntPtr(sth)
+		ps.RetryPolicy = sqlRetryPolicy(rInit, rMax, rMult, rJitter)
 		if drv != "" {
 			ps.SqlTapDriver = drv
 		}
 		cfg.ProxyServices = append(cfg.ProxyServices, ps)
+		pxIDs = append(pxIDs, id)
+		pxHookCols = append(pxHookCols, [2]int{hookTimeout, hookFail})
 	}
 	pxRows.Close()
 
+	for i, id := range pxIDs {
+		hooks, err := s.loadHooks("proxy_service_hooks", "proxy_service_id", id, pxHookCols[i][0], pxHookCols[i][1])
+		if err != nil {
+			return nil, err
+		}
+		cfg.ProxyServices[i].Hooks = hooks
+
+		deps, err := s.loadStrings(`SELECT depends_on FROM proxy_service_dependencies WHERE proxy_service_id = ? ORDER BY sort_order`, id)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ProxyServices[i].DependsOn = deps
+	}
+
 	ApplyConfigDefaults(cfg)
 	if err := ValidateConfig(cfg); err != nil {
 		return nil, err
@@ -403,6 +1128,11 @@ func (s *SQLiteConfigStore) Save(cfg *Config) error {
 	}
 	defer tx.Rollback()
 
+	for _, table := range []string{"notifier_events", "notifier_services", "notifiers"} {
+		if _, err := tx.Exec(`DELETE FROM ` + table); err != nil {
+			return err
+		}
+	}
 	if _, err := tx.Exec(`DELETE FROM preset_services`); err != nil {
 		return err
 	}
@@ -412,6 +1142,21 @@ func (s *SQLiteConfigStore) Save(cfg *Config) error {
 	if _, err := tx.Exec(`DELETE FROM alternative_contexts`); err != nil {
 		return err
 	}
+	if _, err := tx.Exec(`DELETE FROM service_ports`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM service_hooks`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM service_dependencies`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM proxy_service_dependencies`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM proxy_service_hooks`); err != nil {
+		return err
+	}
 	if _, err := tx.Exec(`DELETE FROM services`); err != nil {
 		return err
 	}
@@ -420,9 +1165,13 @@ func (s *SQLiteConfigStore) Save(cfg *Config) error {
 	}
 
 	_, err = tx.Exec(`INSERT OR REPLACE INTO settings (id, cluster_context, cluster_name, namespace, max_retries, web_port,
-		proxy_pod_name, proxy_pod_image, proxy_pod_context, proxy_pod_namespace) VALUES (1, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		proxy_pod_name, proxy_pod_image, proxy_pod_context, proxy_pod_namespace, forward_engine, ready_timeout,
+		manage_hosts, hosts_file, dns_address, dns_upstream, idle_timeout, max_lifetime,
+		retry_initial_delay, retry_max_delay, retry_multiplier, retry_jitter, history_days, orphans) VALUES (1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		c.ClusterContext, c.ClusterName, c.Namespace, c.MaxRetries, c.WebPort,
-		c.ProxyPodName, c.ProxyPodImage, c.ProxyPodContext, c.ProxyPodNamespace)
+		c.ProxyPodName, c.ProxyPodImage, c.ProxyPodContext, c.ProxyPodNamespace, c.ForwardEngine, c.ReadyTimeout,
+		boolToInt(c.ManageHosts), c.HostsFile, c.DNSAddress, c.DNSUpstream, c.IdleTimeout, c.MaxLifetime,
+		c.RetryPolicy.InitialDelay, c.RetryPolicy.MaxDelay, c.RetryPolicy.Multiplier, c.RetryPolicy.Jitter, c.HistoryDays, c.Orphans)
 	if err != nil {
 		return err
 	}
@@ -436,7 +1185,7 @@ func (s *SQLiteConfigStore) Save(cfg *Config) error {
 	}
 
 	for i, pr := range c.Presets {
-		res, err := tx.Exec(`INSERT INTO presets (sort_order, name) VALUES (?, ?)`, i, pr.Name)
+		res, err := tx.Exec(`INSERT INTO presets (sort_order, name, lazy) VALUES (?, ?, ?)`, i, pr.Name, boolToInt(pr.Lazy))
 		if err != nil {
 			return err
 		}
@@ -453,29 +1202,192 @@ func (s *SQLiteConfigStore) Save(cfg *Config) error {
 		}
 	}
 
+	for i, n := range c.Notifiers {
+		if err := saveNotifier(tx, i, n); err != nil {
+			return err
+		}
+	}
+
 	for _, sv := range c.Services {
-		_, err = tx.Exec(`INSERT INTO services (name, service_name, remote_port, local_port, selected_by_default,
-			context, namespace, max_retries, sql_tap_port, sql_tap_driver, sql_tap_grpc_port, sql_tap_http_port)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-			sv.Name, sv.ServiceName, sv.RemotePort, sv.LocalPort, boolToInt(sv.SelectedByDefault),
-			sv.Context, sv.Namespace, optionalIntPtr(sv.MaxRetries), optionalIntPtr(sv.SqlTapPort),
-			strings.TrimSpace(sv.SqlTapDriver), optionalIntPtr(sv.SqlTapGrpcPort), optionalIntPtr(sv.SqlTapHttpPort))
+		args := []interface{}{
+			sv.Name, sv.ServiceName, sv.Kind, sv.Selector, sv.RemotePort, sv.LocalPort, sv.BindAddress, boolToInt(sv.Lazy), boolToInt(sv.SelectedByDefault),
+			sv.Context, sv.Namespace, optionalIntPtr(sv.MaxRetries), sv.ForwardEngine, optionalIntPtr(sv.ReadyTimeout),
+			optionalIntPtr(sv.IdleTimeout), optionalIntPtr(sv.MaxLifetime), optionalIntPtr(sv.SqlTapPort),
+			strings.TrimSpace(sv.SqlTapDriver), optionalIntPtr(sv.SqlTapGrpcPort), optionalIntPtr(sv.SqlTapHttpPort),
+		}
+		args = append(args, retryPolicyColumns(sv.RetryPolicy)...)
+		args = append(args, hookColumns(sv.Hooks)...)
+		res, err := tx.Exec(`INSERT INTO services (name, service_name, kind, selector, remote_port, local_port, bind_address, lazy, selected_by_default,
+			context, namespace, max_retries, forward_engine, ready_timeout, idle_timeout, max_lifetime, sql_tap_port, sql_tap_driver, sql_tap_grpc_port, sql_tap_http_port,
+			retry_initial_delay, retry_max_delay, retry_multiplier, retry_jitter, hook_timeout, hook_fail_on_post_start)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, args...)
 		if err != nil {
 			return err
 		}
+		sid, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for j, pm := range sv.Ports {
+			_, err = tx.Exec(`INSERT INTO service_ports (service_id, sort_order, name, remote_port, local_port) VALUES (?, ?, ?, ?, ?)`,
+				sid, j, pm.Name, pm.RemotePort, pm.LocalPort)
+			if err != nil {
+				return err
+			}
+		}
+		if err := saveHooks(tx, "service_hooks", "service_id", sid, sv.Hooks); err != nil {
+			return err
+		}
+		if err := saveDependencies(tx, "service_dependencies", "service_id", sid, sv.DependsOn); err != nil {
+			return err
+		}
 	}
 
 	for _, ps := range c.ProxyServices {
-		_, err = tx.Exec(`INSERT INTO proxy_services (name, target_host, target_port, local_port, selected_by_default,
-			proxy_pod_context, proxy_pod_namespace, max_retries, sql_tap_port, sql_tap_driver, sql_tap_grpc_port, sql_tap_http_port)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-			ps.Name, ps.TargetHost, ps.TargetPort, ps.LocalPort, boolToInt(ps.SelectedByDefault),
-			ps.ProxyPodContext, ps.ProxyPodNamespace, optionalIntPtr(ps.MaxRetries), optionalIntPtr(ps.SqlTapPort),
-			strings.TrimSpace(ps.SqlTapDriver), optionalIntPtr(ps.SqlTapGrpcPort), optionalIntPtr(ps.SqlTapHttpPort))
+		args := []interface{}{
+			ps.Name, ps.TargetHost, ps.TargetPort, ps.LocalPort, ps.BindAddress, boolToInt(ps.SelectedByDefault),
+			ps.ProxyPodContext, ps.ProxyPodNamespace, optionalIntPtr(ps.MaxRetries), ps.ForwardEngine, optionalIntPtr(ps.ReadyTimeout), optionalIntPtr(ps.SqlTapPort),
+			strings.TrimSpace(ps.SqlTapDriver), optionalIntPtr(ps.SqlTapGrpcPort), optionalIntPtr(ps.SqlTapHttpPort),
+		}
+		args = append(args, retryPolicyColumns(ps.RetryPolicy)...)
+		args = append(args, hookColumns(ps.Hooks)...)
+		res, err := tx.Exec(`INSERT INTO proxy_services (name, target_host, target_port, local_port, bind_address, selected_by_default,
+			proxy_pod_context, proxy_pod_namespace, max_retries, forward_engine, ready_timeout, sql_tap_port, sql_tap_driver, sql_tap_grpc_port, sql_tap_http_port,
+			retry_initial_delay, retry_max_delay, retry_multiplier, retry_jitter, hook_timeout, hook_fail_on_post_start)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, args...)
 		if err != nil {
 			return err
 		}
+		pid, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if err := saveHooks(tx, "proxy_service_hooks", "proxy_service_id", pid, ps.Hooks); err != nil {
+			return err
+		}
+		if err := saveDependencies(tx, "proxy_service_dependencies", "proxy_service_id", pid, ps.DependsOn); err != nil {
+			return err
+		}
 	}
 
 	return tx.Commit()
 }
+
+// SaveTransitions appends status history entries
+func (s *SQLiteConfigStore) SaveTransitions(ts []transition) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, t := range ts {
+		_, err := tx.Exec(`INSERT INTO status_history (time_ms, kind, name, from_status, to_status, reason) VALUES (?, ?, ?, ?, ?, ?)`,
+			t.Time.UnixMilli(), t.Kind, t.Name, t.From, t.To, t.Reason)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// LoadTransitions returns the status history entries since the given time, oldest first
+func (s *SQLiteConfigStore) LoadTransitions(since time.Time) ([]transition, error) {
+	rows, err := s.db.Query(`SELECT time_ms, kind, name, from_status, to_status, reason FROM status_history
+		WHERE time_ms >= ? ORDER BY time_ms, id`, since.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []transition
+	for rows.Next() {
+		var t transition
+		var ms int64
+		if err := rows.Scan(&ms, &t.Kind, &t.Name, &t.From, &t.To, &t.Reason); err != nil {
+			return nil, err
+		}
+		t.Time = time.UnixMilli(ms)
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// PruneTransitions deletes status history entries older than before
+func (s *SQLiteConfigStore) PruneTransitions(before time.Time) error {
+	_, err := s.db.Exec(`DELETE FROM status_history WHERE time_ms < ?`, before.UnixMilli())
+	return err
+}
+
+// Kinds of session_forwards rows
+const (
+	sessionKindService = "service"
+	sessionKindArmed   = "armed"
+	sessionKindProxy   = "proxy_service"
+)
+
+// SaveSession replaces the saved session
+func (s *SQLiteConfigStore) SaveSession(sess session) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	_, err = tx.Exec(`INSERT OR REPLACE INTO session (id, cluster_context, cluster_name, restore_on_start, saved_ms) VALUES (1, ?, ?, ?, ?)`,
+		sess.ClusterContext, sess.ClusterName, boolToInt(sess.RestoreOnStart), sess.SavedAt.UnixMilli())
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM session_forwards`); err != nil {
+		return err
+	}
+	i := 0
+	for _, set := range []struct {
+		kind  string
+		names []string
+	}{{sessionKindService, sess.Services}, {sessionKindArmed, sess.Armed}, {sessionKindProxy, sess.ProxyServices}} {
+		for _, name := range set.names {
+			if _, err := tx.Exec(`INSERT INTO session_forwards (sort_order, kind, name) VALUES (?, ?, ?)`, i, set.kind, name); err != nil {
+				return err
+			}
+			i++
+		}
+	}
+	return tx.Commit()
+}
+
+// LoadSession returns the saved session, or nil if none was saved yet
+func (s *SQLiteConfigStore) LoadSession() (*session, error) {
+	var sess session
+	var restore int
+	var savedMs int64
+	err := s.db.QueryRow(`SELECT cluster_context, cluster_name, restore_on_start, saved_ms FROM session WHERE id = 1`).
+		Scan(&sess.ClusterContext, &sess.ClusterName, &restore, &savedMs)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sess.RestoreOnStart = intToBool(restore)
+	sess.SavedAt = time.UnixMilli(savedMs)
+
+	rows, err := s.db.Query(`SELECT kind, name FROM session_forwards ORDER BY sort_order`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var kind, name string
+		if err := rows.Scan(&kind, &name); err != nil {
+			return nil, err
+		}
+		switch kind {
+		case sessionKindService:
+			sess.Services = append(sess.Services, name)
+		case sessionKindArmed:
+			sess.Armed = append(sess.Armed, name)
+		case sessionKindProxy:
+			sess.ProxyServices = append(sess.ProxyServices, name)
+		}
+	}
+	return &sess, rows.Err()
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSQLiteConfigStoreRoundTrip(t *testing.T) {
+	store, err := NewSQLiteConfigStore(filepath.Join(t.TempDir(), "kubefwd.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	readyTimeout := 5
+	maxLifetime := 8
+	rateLimit := 0
+	cfg := &Config{
+		ClusterContext: "ctx1",
+		Namespace:      "default",
+		ForwardEngine:  ForwardEngineNative,
+		ReadyTimeout:   45,
+		ManageHosts:    true,
+		HostsFile:      "/tmp/hosts",
+		DNSAddress:     "127.0.0.1:5353",
+		IdleTimeout:    30,
+		HistoryDays:    7,
+		Orphans:        OrphansCleanup,
+		RetryPolicy:    RetryPolicy{InitialDelay: 0.5, Jitter: 0.2},
+		Services: []Service{
+			{Name: "A", ServiceName: "svc-a", RemotePort: 80, LocalPort: 8080, ForwardEngine: ForwardEngineKubectl, ReadyTimeout: &readyTimeout, MaxLifetime: &maxLifetime,
+				Hooks: &Hooks{PreStart: []string{"echo one", "echo two"}, PostStop: []string{"echo bye"}, Timeout: 5}},
+			{Name: "C", Selector: "app=c,tier in (web)", RemotePort: 80, LocalPort: 8090, RetryPolicy: &RetryPolicy{MaxDelay: 10}},
+			{Name: "D", ServiceName: "svc-d", RemotePort: 80, LocalPort: 8080, BindAddress: "127.0.0.2", Lazy: true, DependsOn: []string{"A", "db"}},
+			{Name: "B", ServiceName: "svc-b", Kind: TargetKindStatefulSet, Ports: []PortMapping{
+				{Name: "http", RemotePort: 80, LocalPort: 8081},
+				{Name: "grpc", RemotePort: 9000, LocalPort: 9000},
+			}},
+		},
+		ProxyServices: []ProxyService{
+			{Name: "db", TargetHost: "10.0.0.5", TargetPort: 5432, LocalPort: 5432, ProxyPodContext: "ctx1", ProxyPodNamespace: "default",
+				RetryPolicy: &RetryPolicy{InitialDelay: 2, Multiplier: 3}, Hooks: &Hooks{PostStart: []string{"pg_isready"}, FailOnPostStart: true}},
+		},
+		Presets: []Preset{{Name: "on demand", Services: []string{"A", "D"}, Lazy: true}},
+		Notifiers: []Notifier{
+			{Name: "desktop", Type: NotifierCommand, Command: "notify-send kubefwd \"$KUBEFWD_MESSAGE\"", Events: []string{EventRetriesExhausted, EventRecovered}},
+			{Name: "chat", Type: NotifierWebhook, URL: "https://hooks.example.com/x", Services: []string{"db"}, RateLimit: &rateLimit},
+		},
+	}
+	if err := store.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ForwardEngine != ForwardEngineNative {
+		t.Fatalf("global engine: %q", loaded.ForwardEngine)
+	}
+	if len(loaded.Services) != 4 || loaded.Services[0].ForwardEngine != ForwardEngineKubectl {
+		t.Fatalf("services: %+v", loaded.Services)
+	}
+	if loaded.ReadyTimeout != 45 {
+		t.Fatalf("global ready_timeout: %d", loaded.ReadyTimeout)
+	}
+	if !loaded.ManageHosts || loaded.HostsFile != "/tmp/hosts" {
+		t.Fatalf("hosts settings: %v %q", loaded.ManageHosts, loaded.HostsFile)
+	}
+	if loaded.DNSAddress != "127.0.0.1:5353" || loaded.DNSUpstream != "" {
+		t.Fatalf("dns settings: %q %q", loaded.DNSAddress, loaded.DNSUpstream)
+	}
+	if rt := loaded.Services[0].ReadyTimeout; rt == nil || *rt != 5 {
+		t.Fatalf("service ready_timeout: %v", rt)
+	}
+	if loaded.IdleTimeout != 30 || loaded.MaxLifetime != 0 {
+		t.Fatalf("global limits: %d %d", loaded.IdleTimeout, loaded.MaxLifetime)
+	}
+	if loaded.HistoryDays != 7 {
+		t.Fatalf("history_days: %d", loaded.HistoryDays)
+	}
+	if loaded.Orphans != OrphansCleanup {
+		t.Fatalf("orphans: %s", loaded.Orphans)
+	}
+	if ml := loaded.Services[0].MaxLifetime; ml == nil || *ml != 8 || loaded.Services[0].IdleTimeout != nil {
+		t.Fatalf("service limits: %v %v", ml, loaded.Services[0].IdleTimeout)
+	}
+	if len(loaded.Services[0].Ports) != 0 {
+		t.Fatalf("single-port service gained ports: %+v", loaded.Services[0].Ports)
+	}
+	b := loaded.Services[1]
+	if len(b.Ports) != 2 || b.Kind != TargetKindStatefulSet || b.Ports[1].Name != "grpc" || b.Ports[1].LocalPort != 9000 || b.LocalPort != 8081 {
+		t.Fatalf("multi-port service: %+v", b)
+	}
+	if c := loaded.Services[2]; c.Selector != "app=c,tier in (web)" {
+		t.Fatalf("selector service: %+v", c)
+	}
+	if rp := loaded.RetryPolicy; rp != (RetryPolicy{InitialDelay: 0.5, MaxDelay: 60, Multiplier: 2, Jitter: 0.2}) {
+		t.Fatalf("global retry_policy: %+v", rp)
+	}
+	if rp := loaded.Services[2].RetryPolicy; rp == nil || *rp != (RetryPolicy{MaxDelay: 10}) || loaded.Services[0].RetryPolicy != nil {
+		t.Fatalf("service retry_policy: %+v %+v", rp, loaded.Services[0].RetryPolicy)
+	}
+	if len(loaded.ProxyServices) != 1 || loaded.ProxyServices[0].RetryPolicy == nil ||
+		*loaded.ProxyServices[0].RetryPolicy != (RetryPolicy{InitialDelay: 2, Multiplier: 3}) {
+		t.Fatalf("proxy service retry_policy: %+v", loaded.ProxyServices)
+	}
+	if h := loaded.Services[0].Hooks; h == nil || !reflect.DeepEqual(*h, Hooks{PreStart: []string{"echo one", "echo two"}, PostStop: []string{"echo bye"}, Timeout: 5}) {
+		t.Fatalf("service hooks: %+v", h)
+	}
+	if d := loaded.Services[3].DependsOn; !reflect.DeepEqual(d, []string{"A", "db"}) || loaded.Services[0].DependsOn != nil {
+		t.Fatalf("depends_on: %v %v", d, loaded.Services[0].DependsOn)
+	}
+	if !reflect.DeepEqual(loaded.Notifiers, cfg.Notifiers) {
+		t.Fatalf("notifiers: %+v", loaded.Notifiers)
+	}
+	if loaded.Services[1].Hooks != nil {
+		t.Fatalf("service without hooks gained some: %+v", loaded.Services[1].Hooks)
+	}
+	if h := loaded.ProxyServices[0].Hooks; h == nil || !h.FailOnPostStart || len(h.PostStart) != 1 || h.PostStart[0] != "pg_isready" {
+		t.Fatalf("proxy service hooks: %+v", h)
+	}
+	if d := loaded.Services[3]; d.BindAddress != "127.0.0.2" || !d.Lazy {
+		t.Fatalf("bind_address service: %+v", d)
+	}
+	if loaded.Services[0].Lazy {
+		t.Fatalf("service A became lazy")
+	}
+	if len(loaded.Presets) != 1 || !loaded.Presets[0].Lazy || len(loaded.Presets[0].Services) != 2 {
+		t.Fatalf("presets: %+v", loaded.Presets)
+	}
+}
+
+func TestSQLiteStatusHistory(t *testing.T) {
+	store, err := NewSQLiteConfigStore(filepath.Join(t.TempDir(), "kubefwd.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	t0 := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	err = store.SaveTransitions([]transition{
+		{Time: t0, Kind: historyKindService, Name: "A", From: "stopped", To: "starting", Reason: "started"},
+		{Time: t0.Add(time.Hour), Kind: historyKindService, Name: "A", From: "starting", To: "running", Reason: "ready"},
+		{Time: t0.Add(2 * time.Hour), Kind: historyKindProxyPod, Name: "ctx/ns", From: "ready", To: "error"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Saving the config must not touch the history
+	cfg := &Config{ClusterContext: "ctx1", Namespace: "default", Services: []Service{{Name: "A", ServiceName: "svc-a", RemotePort: 80, LocalPort: 8080}}}
+	if err := store.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PruneTransitions(t0.Add(30 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.LoadTransitions(t0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].To != "running" || got[0].Reason != "ready" || !got[0].Time.Equal(t0.Add(time.Hour)) || got[1].Name != "ctx/ns" {
+		t.Fatalf("loaded transitions: %+v", got)
+	}
+}
+
+func TestSQLiteSession(t *testing.T) {
+	store, err := NewSQLiteConfigStore(filepath.Join(t.TempDir(), "kubefwd.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if s, err := store.LoadSession(); s != nil || err != nil {
+		t.Fatalf("no session yet: %+v %v", s, err)
+	}
+	want := session{ClusterContext: "staging", ClusterName: "Staging", Services: []string{"API", "Database"},
+		Armed: []string{"Metrics"}, ProxyServices: []string{"CloudSQL"}, RestoreOnStart: true,
+		SavedAt: time.UnixMilli(time.Now().UnixMilli())}
+	if err := store.SaveSession(want); err != nil {
+		t.Fatal(err)
+	}
+	// Saving the config must not touch the session
+	cfg := &Config{ClusterContext: "ctx1", Namespace: "default", Services: []Service{{Name: "A", ServiceName: "svc-a", RemotePort: 80, LocalPort: 8080}}}
+	if err := store.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.LoadSession()
+	if err != nil || got == nil {
+		t.Fatalf("load: %+v %v", got, err)
+	}
+	if !got.SavedAt.Equal(want.SavedAt) {
+		t.Fatalf("saved_at: %s", got.SavedAt)
+	}
+	got.SavedAt = want.SavedAt
+	if !reflect.DeepEqual(*got, want) {
+		t.Fatalf("loaded session: %+v", *got)
+	}
+
+	if err := store.SaveSession(session{ClusterContext: "ctx1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.LoadSession(); got.Services != nil || got.RestoreOnStart {
+		t.Fatalf("session not replaced: %+v", *got)
+	}
+}
+
+func TestSQLiteMigratesFromV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubefwd.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := createSchemaV1(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`PRAGMA user_version = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO settings (id, cluster_context, namespace) VALUES (1, 'ctx1', 'default')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO services (name, service_name, remote_port, local_port, selected_by_default)
+		VALUES ('A', 'svc-a', 80, 8080, 0)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	store, err := NewSQLiteConfigStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	cfg, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Services) != 1 || cfg.Services[0].LocalPort != 8080 {
+		t.Fatalf("services: %+v", cfg.Services)
+	}
+	if cfg.ForwardEngine != ForwardEngineKubectl {
+		t.Fatalf("engine default after migration: %q", cfg.ForwardEngine)
+	}
+	if cfg.ReadyTimeout != 30 {
+		t.Fatalf("ready_timeout default after migration: %d", cfg.ReadyTimeout)
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestParseConfigYAMLMinimal(t *testing.T) {
 	y := `
@@ -32,3 +35,165 @@ func TestValidateConfigRequiresService(t *testing.T) {
 		t.Fatal("expected error with no services")
 	}
 }
+
+func TestForwardEngineDefaultAndValidation(t *testing.T) {
+	y := `
+cluster_context: ctx1
+namespace: default
+services:
+  - name: A
+    service_name: svc-a
+    remote_port: 80
+    local_port: 8080
+    forward_engine: native
+`
+	cfg, err := ParseConfigYAML([]byte(y))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ForwardEngine != ForwardEngineKubectl {
+		t.Fatalf("default engine: %q", cfg.ForwardEngine)
+	}
+	if got := cfg.Services[0].GetForwardEngine(cfg.ForwardEngine); got != ForwardEngineNative {
+		t.Fatalf("service engine: %q", got)
+	}
+
+	cfg.Services[0].ForwardEngine = "ssh"
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatal("expected error for unknown forward_engine")
+	}
+}
+
+func TestServicePortsList(t *testing.T) {
+	y := `
+cluster_context: ctx1
+namespace: default
+services:
+  - name: API
+    service_name: api
+    ports:
+      - name: http
+        remote_port: 80
+        local_port: 8080
+      - name: metrics
+        remote_port: 9090
+        local_port: 9090
+  - name: DB
+    service_name: db
+    remote_port: 5432
+    local_port: 5432
+`
+	cfg, err := ParseConfigYAML([]byte(y))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := cfg.Services[0]
+	if api.LocalPort != 8080 || api.RemotePort != 80 {
+		t.Fatalf("primary port not mirrored: %d -> %d", api.LocalPort, api.RemotePort)
+	}
+	if got := len(api.PortMappings()); got != 2 {
+		t.Fatalf("api mappings: %d", got)
+	}
+	db := cfg.Services[1]
+	if m := db.PortMappings(); len(m) != 1 || m[0].LocalPort != 5432 || m[0].RemotePort != 5432 {
+		t.Fatalf("single-port mappings: %+v", m)
+	}
+
+	var names []string
+	for _, cp := range GetAllPortsFromConfig(cfg) {
+		names = append(names, cp.ServiceName)
+	}
+	if strings.Join(names, ",") != "API (http),API (metrics),DB" {
+		t.Fatalf("config ports: %v", names)
+	}
+
+	cfg.Services[0].Ports[1].LocalPort = 8080
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatal("expected error for duplicate local port")
+	}
+	cfg.Services[0].Ports[1].LocalPort = 9090
+	cfg.Services[0].LocalPort = 1234
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatal("expected error when local_port does not match ports[0]")
+	}
+}
+
+func TestServiceKind(t *testing.T) {
+	svc := Service{Name: "PG", ServiceName: "postgres-0", Kind: TargetKindPod}
+	if got := svc.Resource(); got != "pod/postgres-0" {
+		t.Fatalf("resource: %s", got)
+	}
+	svc.Kind = ""
+	if got := svc.Resource(); got != "service/postgres-0" {
+		t.Fatalf("default resource: %s", got)
+	}
+
+	cfg := &Config{ClusterContext: "ctx1", Namespace: "default", Services: []Service{
+		{Name: "A", ServiceName: "a", Kind: "job", RemotePort: 80, LocalPort: 8080},
+	}}
+	ApplyConfigDefaults(cfg)
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatal("expected error for unknown kind")
+	}
+	cfg.Services[0].Kind = TargetKindDeployment
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServiceSelectorValidation(t *testing.T) {
+	cfg := &Config{ClusterContext: "ctx1", Namespace: "default", Services: []Service{
+		{Name: "A", Selector: "app=api", RemotePort: 80, LocalPort: 8080},
+	}}
+	ApplyConfigDefaults(cfg)
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Services[0].Resource(); got != "pod/[app=api]" {
+		t.Fatalf("resource: %s", got)
+	}
+
+	cfg.Services[0].ServiceName = "api"
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatal("expected error for selector combined with service_name")
+	}
+	cfg.Services[0].ServiceName = ""
+	cfg.Services[0].Selector = "app in (("
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatal("expected error for invalid selector")
+	}
+}
+
+func TestBindAddressValidation(t *testing.T) {
+	cfg := &Config{ClusterContext: "ctx1", Namespace: "default",
+		Services: []Service{
+			{Name: "Orders DB", ServiceName: "orders-db", RemotePort: 5432, LocalPort: 5432, BindAddress: "127.0.0.2"},
+			{Name: "Users DB", ServiceName: "users-db", RemotePort: 5432, LocalPort: 5432, BindAddress: "127.0.0.3"},
+		},
+		ProxyServices: []ProxyService{
+			{Name: "CloudSQL", TargetHost: "10.0.0.1", TargetPort: 5432, LocalPort: 5432,
+				ProxyPodContext: "ctx1", ProxyPodNamespace: "default"},
+		},
+	}
+	ApplyConfigDefaults(cfg)
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Services[1].BindAddress = "127.0.0.2"
+	if err := ValidateConfig(cfg); err == nil || !strings.Contains(err.Error(), "local port 5432") {
+		t.Fatalf("expected collision on 127.0.0.2:5432, got %v", err)
+	}
+	cfg.Services[1].BindAddress = "0.0.0.0"
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatal("expected wildcard bind to collide with every address")
+	}
+	cfg.Services[1].BindAddress = "127.0.0.1"
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatal("expected 127.0.0.1 to collide with the proxy service on localhost")
+	}
+	cfg.Services[1].BindAddress = "db.local"
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatal("expected error for non-IP bind_address")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -38,6 +40,7 @@ func debugLog(format string, args ...interface{}) {
 func debugRunCmd(cmd *exec.Cmd) ([]byte, error) {
 	debugLog("CMD: %s", strings.Join(cmd.Args, " "))
 	out, err := cmd.CombinedOutput()
+	recordKubectlRun(cmd.Args, err)
 	outStr := strings.TrimSpace(string(out))
 	if err != nil {
 		if outStr != "" {
@@ -60,3 +63,63 @@ func getDebugLines() []string {
 	copy(cp, debugLines)
 	return cp
 }
+
+// kubectlRunStats counts the short-lived kubectl commands run through debugRunCmd
+// by subcommand, for /metrics
+type kubectlRunStats struct {
+	Runs   int64
+	Errors int64
+}
+
+var (
+	kubectlRuns   = make(map[string]*kubectlRunStats)
+	kubectlRunsMu sync.Mutex
+)
+
+func recordKubectlRun(args []string, err error) {
+	if len(args) == 0 || filepath.Base(args[0]) != "kubectl" {
+		return
+	}
+	verb := kubectlVerb(args[1:])
+	kubectlRunsMu.Lock()
+	defer kubectlRunsMu.Unlock()
+	st, ok := kubectlRuns[verb]
+	if !ok {
+		st = &kubectlRunStats{}
+		kubectlRuns[verb] = st
+	}
+	st.Runs++
+	if err != nil {
+		st.Errors++
+	}
+}
+
+// kubectlVerb returns the subcommand of a kubectl invocation, skipping global flags
+// and their values (e.g. "--context=x -n ns get pods" -> "get")
+func kubectlVerb(args []string) string {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if !strings.HasPrefix(a, "-") {
+			return a
+		}
+		switch a {
+		case "-n", "--namespace", "--context", "--kubeconfig", "--cluster", "--user", "-s", "--server":
+			i++ // value in the next argument
+		}
+	}
+	return "unknown"
+}
+
+// getKubectlRunStats returns a copy of the counters, sorted by subcommand
+func getKubectlRunStats() (verbs []string, stats []kubectlRunStats) {
+	kubectlRunsMu.Lock()
+	defer kubectlRunsMu.Unlock()
+	for verb := range kubectlRuns {
+		verbs = append(verbs, verb)
+	}
+	sort.Strings(verbs)
+	for _, verb := range verbs {
+		stats = append(stats, *kubectlRuns[verb])
+	}
+	return verbs, stats
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dependencyPollInterval is how often a start plan checks whether a dependency is ready
+var dependencyPollInterval = 250 * time.Millisecond
+
+// dependencyGraph holds the depends_on edges between services and proxy services
+type dependencyGraph struct {
+	names []string            // Config order: services, then proxy services
+	deps  map[string][]string // Names each entry depends on
+	proxy map[string]bool     // Names of proxy services
+}
+
+func newDependencyGraph(cfg *Config) *dependencyGraph {
+	g := &dependencyGraph{deps: make(map[string][]string), proxy: make(map[string]bool)}
+	for _, svc := range cfg.Services {
+		g.names = append(g.names, svc.Name)
+		g.deps[svc.Name] = svc.DependsOn
+	}
+	for _, ps := range cfg.ProxyServices {
+		g.names = append(g.names, ps.Name)
+		g.deps[ps.Name] = ps.DependsOn
+		g.proxy[ps.Name] = true
+	}
+	return g
+}
+
+// validateDependencies rejects depends_on entries naming unknown or ambiguous
+// services, and dependency cycles
+func validateDependencies(cfg *Config) error {
+	services := make(map[string]bool)
+	for _, svc := range cfg.Services {
+		services[svc.Name] = true
+	}
+	proxies := make(map[string]bool)
+	for _, ps := range cfg.ProxyServices {
+		proxies[ps.Name] = true
+	}
+	check := func(prefix, name string, deps []string) error {
+		seen := make(map[string]bool)
+		for _, dep := range deps {
+			switch {
+			case dep == name:
+				return fmt.Errorf("%sdepends_on: cannot depend on itself", prefix)
+			case !services[dep] && !proxies[dep]:
+				return fmt.Errorf("%sdepends_on: unknown service %q", prefix, dep)
+			case services[dep] && proxies[dep]:
+				return fmt.Errorf("%sdepends_on: %q is both a service and a proxy service", prefix, dep)
+			case seen[dep]:
+				return fmt.Errorf("%sdepends_on: %q is listed twice", prefix, dep)
+			}
+			seen[dep] = true
+		}
+		return nil
+	}
+	for i, svc := range cfg.Services {
+		if err := check(fmt.Sprintf("service %d (%s): ", i, svc.Name), svc.Name, svc.DependsOn); err != nil {
+			return err
+		}
+	}
+	for i, ps := range cfg.ProxyServices {
+		if err := check(fmt.Sprintf("proxy_service %d (%s): ", i, ps.Name), ps.Name, ps.DependsOn); err != nil {
+			return err
+		}
+	}
+	if cycle := newDependencyGraph(cfg).cycle(); cycle != nil {
+		return fmt.Errorf("depends_on: dependency cycle %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// cycle returns the names along a dependency cycle, first name repeated at the end,
+// or nil if there is none
+func (g *dependencyGraph) cycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range g.deps[name] {
+			switch state[dep] {
+			case visiting:
+				for i, n := range path {
+					if n == dep {
+						return append(append([]string(nil), path[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if c := visit(dep); c != nil {
+					return c
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+	for _, name := range g.names {
+		if state[name] == unvisited {
+			if c := visit(name); c != nil {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// startOrder returns names and everything they depend on, each after the names it
+// depends on. Unknown names are kept; the graph must not have cycles.
+func (g *dependencyGraph) startOrder(names []string) []string {
+	added := make(map[string]bool)
+	var order []string
+	var visit func(name string)
+	visit = func(name string) {
+		if added[name] {
+			return
+		}
+		added[name] = true
+		for _, dep := range g.deps[name] {
+			visit(dep)
+		}
+		order = append(order, name)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return order
+}
+
+// dependents returns everything that depends on name directly or indirectly, each
+// before the names it depends on, i.e. in the order they should be stopped
+func (g *dependencyGraph) dependents(name string) []string {
+	affected := map[string]bool{name: true}
+	for changed := true; changed; {
+		changed = false
+		for _, n := range g.names {
+			if affected[n] {
+				continue
+			}
+			for _, dep := range g.deps[n] {
+				if affected[dep] {
+					affected[n], changed = true, true
+					break
+				}
+			}
+		}
+	}
+	var names []string
+	for _, n := range g.names {
+		if affected[n] && n != name {
+			names = append(names, n)
+		}
+	}
+	order := g.startOrder(names)
+	out := make([]string, 0, len(names))
+	for i := len(order) - 1; i >= 0; i-- {
+		if affected[order[i]] && order[i] != name {
+			out = append(out, order[i])
+		}
+	}
+	return out
+}
+
+// runStartPlan starts every name of order, which startOrder produced, once the names
+// it depends on are ready. Independent names start in parallel. start starts one name
+// and reports whether it became ready; waiting is told which dependency a name waits
+// for ("" once it no longer waits) and skipped which one failed, in which case the
+// name is not started. runStartPlan returns when every name is ready or skipped.
+func runStartPlan(order []string, deps func(name string) []string, start func(name string) bool, waiting, skipped func(name, dep string)) {
+	type result struct {
+		done chan struct{}
+		ok   bool
+	}
+	results := make(map[string]*result, len(order))
+	for _, name := range order {
+		results[name] = &result{done: make(chan struct{})}
+	}
+
+	var wg sync.WaitGroup
+	for _, name := range order {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			res := results[name]
+			defer close(res.done)
+			for _, dep := range deps(name) {
+				d, ok := results[dep]
+				if !ok {
+					continue
+				}
+				waiting(name, dep)
+				<-d.done
+				if !d.ok {
+					skipped(name, dep)
+					return
+				}
+			}
+			waiting(name, "")
+			res.ok = start(name)
+		}(name)
+	}
+	wg.Wait()
+}
+
+// awaitForwardReady polls status until the forward is running or armed, and reports
+// false once it is stopped or failed without a pending retry
+func awaitForwardReady(status func() (PortForwardStatus, bool)) bool {
+	for {
+		st, retrying := status()
+		switch {
+		case st == StatusRunning || st == StatusArmed:
+			return true
+		case (st == StatusStopped || st == StatusError) && !retrying:
+			return false
+		}
+		time.Sleep(dependencyPollInterval)
+	}
+}
+
+// startWithDependencies starts names (services or proxy services) and everything
+// they depend on, each once its dependencies are ready, and returns when all of them
+// are ready or were given up on. lazy arms services instead of starting them.
+func (wa *WebApp) startWithDependencies(names []string, lazy bool) {
+	wa.mu.RLock()
+	cfg := wa.config
+	g := newDependencyGraph(cfg)
+	wa.mu.RUnlock()
+
+	order := g.startOrder(names)
+	debugLog("starting in dependency order: %s", strings.Join(order, ", "))
+	runStartPlan(order,
+		func(name string) []string { return g.deps[name] },
+		func(name string) bool {
+			wa.mu.RLock()
+			reloaded, closing := wa.config != cfg, wa.closing
+			wa.mu.RUnlock()
+			if reloaded || closing {
+				return false // The config was reloaded meanwhile, or kubefwd is shutting down
+			}
+			if g.proxy[name] {
+				return wa.startProxyDependency(name)
+			}
+			return wa.startServiceDependency(name, lazy)
+		},
+		func(name, dep string) {
+			if dep == "" {
+				wa.setWaiting(name, "")
+			} else {
+				wa.setWaiting(name, fmt.Sprintf("Waiting for %s", dep))
+			}
+		},
+		func(name, dep string) {
+			debugLog("%s: not started, dependency %s did not become ready", name, dep)
+			wa.setWaiting(name, fmt.Sprintf("Not started: %s did not become ready", dep))
+		})
+}
+
+// setWaiting sets the dependency note shown for name; "" removes it
+func (wa *WebApp) setWaiting(name, note string) {
+	wa.mu.Lock()
+	defer wa.mu.Unlock()
+	if note == "" {
+		delete(wa.waiting, name)
+	} else {
+		wa.waiting[name] = note
+	}
+}
+
+// startServiceDependency starts or arms a service of a start plan and waits until it
+// is ready. A service that is already up counts as ready.
+func (wa *WebApp) startServiceDependency(name string, lazy bool) bool {
+	wa.mu.RLock()
+	var pf *PortForward
+	for _, p := range wa.portForwards {
+		if p.Service.Name == name {
+			pf = p
+		}
+	}
+	wa.mu.RUnlock()
+	if pf == nil {
+		return false
+	}
+	if lazy {
+		_ = pf.Arm()
+	} else {
+		_ = pf.StartOrArm()
+	}
+	return awaitForwardReady(func() (PortForwardStatus, bool) {
+		st, _ := pf.GetStatus()
+		retrying, _, _ := pf.GetRetryInfo()
+		return st, retrying
+	})
+}
+
+// startProxyDependency starts the forward of a proxy service of a start plan, creating
+// its proxy pod if needed, and waits until it is ready
+func (wa *WebApp) startProxyDependency(name string) bool {
+	wa.mu.RLock()
+	var ps *ProxyService
+	for i := range wa.config.ProxyServices {
+		if wa.config.ProxyServices[i].Name == name {
+			p := wa.config.ProxyServices[i]
+			ps = &p
+		}
+	}
+	wa.mu.RUnlock()
+	if ps == nil {
+		return false
+	}
+	mgr, err := wa.ensureProxyPod(ps.ProxyGroupKey(), name)
+	if err != nil {
+		debugLog("proxy %s: proxy pod not ready: %v", name, err)
+		return false
+	}
+
+	wa.mu.Lock()
+	pxf, active := wa.proxyForwards[name]
+	if !active {
+		pxf = wa.newProxyForwardLocked(*ps, mgr)
+		wa.proxyForwards[name] = pxf
+	}
+	wa.mu.Unlock()
+	if !active {
+		_ = pxf.Start()
+	}
+	return awaitForwardReady(func() (PortForwardStatus, bool) {
+		st, _ := pxf.GetStatus()
+		retrying, _, _ := pxf.GetRetryInfo()
+		return st, retrying
+	})
+}
+
+// ensureProxyPod makes sure the proxy pod of group key is ready and relays name. A
+// pod without it is (re)created with every proxy service of the group, and the
+// forwards active on it are reconnected.
+func (wa *WebApp) ensureProxyPod(key, name string) (*ProxyPodManager, error) {
+	wa.podMu.Lock()
+	defer wa.podMu.Unlock()
+	wa.mu.RLock()
+	mgr, ok := wa.proxyPodManagers[key]
+	wa.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no proxy pod for %s", key)
+	}
+	if st, _, _ := mgr.GetStatus(); st == ProxyPodStatusReady && mgr.IsServiceActive(name) {
+		return mgr, nil
+	}
+	wa.mu.RLock()
+	svcs := wa.allServicesForGroup(key)
+	wa.mu.RUnlock()
+	if err := mgr.CreatePodWithServices(svcs); err != nil {
+		return nil, err
+	}
+	wa.reconnectProxyGroup(key)
+	return mgr, nil
+}
+
+// runningDependents returns the services (running, starting or armed) and active
+// proxy services depending on name, in the order they should be stopped
+func (wa *WebApp) runningDependents(name string) []string {
+	wa.mu.RLock()
+	defer wa.mu.RUnlock()
+	var out []string
+	for _, n := range newDependencyGraph(wa.config).dependents(name) {
+		if _, active := wa.proxyForwards[n]; active {
+			out = append(out, n)
+			continue
+		}
+		for _, pf := range wa.portForwards {
+			if pf.Service.Name == n && pf.IsRunning() {
+				out = append(out, n)
+			}
+		}
+	}
+	return out
+}
+
+// stopDependents stops the running dependents of name and returns their names
+func (wa *WebApp) stopDependents(name string) []string {
+	names := wa.runningDependents(name)
+	for _, n := range names {
+		wa.mu.Lock()
+		pxf, isProxy := wa.proxyForwards[n]
+		if isProxy {
+			delete(wa.proxyForwards, n)
+		}
+		var pf *PortForward
+		for _, p := range wa.portForwards {
+			if p.Service.Name == n {
+				pf = p
+			}
+		}
+		wa.mu.Unlock()
+		if isProxy {
+			_ = pxf.Stop()
+		} else if pf != nil {
+			_ = pf.Stop()
+		}
+		wa.setWaiting(n, "")
+	}
+	return names
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// dependencyConfig returns a config where API depends on Database and Cache, Worker
+// on API, and Database on the proxy service Proxy
+func dependencyConfig() *Config {
+	return &Config{ClusterContext: "ctx1", Namespace: "default",
+		Services: []Service{
+			{Name: "API", ServiceName: "api", RemotePort: 80, LocalPort: 8080, DependsOn: []string{"Database", "Cache"}},
+			{Name: "Worker", ServiceName: "worker", RemotePort: 80, LocalPort: 8081, DependsOn: []string{"API"}},
+			{Name: "Database", ServiceName: "postgres", RemotePort: 5432, LocalPort: 5432, DependsOn: []string{"Proxy"}},
+			{Name: "Cache", ServiceName: "redis", RemotePort: 6379, LocalPort: 6379},
+			{Name: "Other", ServiceName: "other", RemotePort: 80, LocalPort: 8082},
+		},
+		ProxyServices: []ProxyService{
+			{Name: "Proxy", TargetHost: "10.0.0.5", TargetPort: 5432, LocalPort: 5433, ProxyPodContext: "ctx1", ProxyPodNamespace: "default"},
+		},
+	}
+}
+
+func TestDependencyValidation(t *testing.T) {
+	cfg := dependencyConfig()
+	ApplyConfigDefaults(cfg)
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		mutate func(*Config)
+		want   string
+	}{
+		{func(c *Config) { c.Services[0].DependsOn = []string{"Missing"} }, `unknown service "Missing"`},
+		{func(c *Config) { c.Services[3].DependsOn = []string{"Cache"} }, "itself"},
+		{func(c *Config) { c.Services[0].DependsOn = []string{"Cache", "Cache"} }, "listed twice"},
+		{func(c *Config) { c.ProxyServices[0].DependsOn = []string{"Worker"} }, "dependency cycle API -> Database -> Proxy -> Worker -> API"},
+		{func(c *Config) { c.ProxyServices[0].Name = "Cache"; c.Services[2].DependsOn = []string{"Cache"} }, "both a service and a proxy service"},
+	}
+	for _, c := range cases {
+		cfg := dependencyConfig()
+		c.mutate(cfg)
+		ApplyConfigDefaults(cfg)
+		err := ValidateConfig(cfg)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("got %v, want %q", err, c.want)
+		}
+	}
+}
+
+func TestDependencyStartOrderAndDependents(t *testing.T) {
+	g := newDependencyGraph(dependencyConfig())
+	if got := fmt.Sprint(g.startOrder([]string{"Worker", "Other"})); got != "[Proxy Database Cache API Worker Other]" {
+		t.Fatalf("start order: %s", got)
+	}
+	if got := fmt.Sprint(g.startOrder([]string{"Cache", "API"})); got != "[Cache Proxy Database API]" {
+		t.Fatalf("start order with dependency listed first: %s", got)
+	}
+	if got := fmt.Sprint(g.dependents("Proxy")); got != "[Worker API Database]" {
+		t.Fatalf("dependents of Proxy: %s", got)
+	}
+	if got := g.dependents("Other"); len(got) != 0 {
+		t.Fatalf("dependents of Other: %v", got)
+	}
+}
+
+func TestRunStartPlanWaitsForDependencies(t *testing.T) {
+	g := newDependencyGraph(dependencyConfig())
+	var mu sync.Mutex
+	var started []string
+	ready := make(map[string]bool)
+	start := func(name string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, dep := range g.deps[name] {
+			if !ready[dep] {
+				t.Errorf("%s started before %s was ready", name, dep)
+			}
+		}
+		started = append(started, name)
+		if name == "Cache" {
+			return false // Never becomes ready
+		}
+		ready[name] = true
+		return true
+	}
+	var waited, skipped []string
+	runStartPlan(g.startOrder([]string{"Worker", "Other"}),
+		func(name string) []string { return g.deps[name] },
+		start,
+		func(name, dep string) {
+			if dep != "" {
+				mu.Lock()
+				waited = append(waited, name+"<"+dep)
+				mu.Unlock()
+			}
+		},
+		func(name, dep string) {
+			mu.Lock()
+			skipped = append(skipped, name+"<"+dep)
+			mu.Unlock()
+		})
+
+	got := make(map[string]bool)
+	for _, n := range started {
+		got[n] = true
+	}
+	if len(started) != 4 || !got["Proxy"] || !got["Database"] || !got["Cache"] || !got["Other"] {
+		t.Fatalf("started: %v", started)
+	}
+	if fmt.Sprint(skipped) != "[API<Cache Worker<API]" {
+		t.Fatalf("skipped: %v", skipped)
+	}
+	if !strings.Contains(fmt.Sprint(waited), "Database<Proxy") {
+		t.Fatalf("waited: %v", waited)
+	}
+}
+
+func TestAwaitForwardReady(t *testing.T) {
+	defer func(d time.Duration) { dependencyPollInterval = d }(dependencyPollInterval)
+	dependencyPollInterval = time.Millisecond
+
+	steps := []PortForwardStatus{StatusStarting, StatusError, StatusStarting, StatusRunning}
+	i := 0
+	ok := awaitForwardReady(func() (PortForwardStatus, bool) {
+		st := steps[i]
+		i++
+		return st, st == StatusError // The error is followed by a retry
+	})
+	if !ok || i != len(steps) {
+		t.Fatalf("ready after a retry: ok=%v after %d polls", ok, i)
+	}
+	if awaitForwardReady(func() (PortForwardStatus, bool) { return StatusError, false }) {
+		t.Fatal("a failed forward counted as ready")
+	}
+	if !awaitForwardReady(func() (PortForwardStatus, bool) { return StatusArmed, false }) {
+		t.Fatal("an armed forward should count as ready")
+	}
+}
+
+func TestStartPlanAfterShutdown(t *testing.T) {
+	cfg := &Config{Services: []Service{{Name: "API", ServiceName: "api", RemotePort: 80, LocalPort: 18080}}}
+	ApplyConfigDefaults(cfg)
+	wa := NewWebApp(cfg, &FileConfigStore{Path: filepath.Join(t.TempDir(), "kubefwd.yaml")})
+	wa.Shutdown()
+	wa.startWithDependencies([]string{"API"}, false)
+	if st, _ := wa.portForwards[0].GetStatus(); st != StatusStopped {
+		t.Fatalf("started after shutdown: %s", st)
+	}
+}
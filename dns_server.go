@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsTTL is short because answers follow forwards starting and stopping
+const dnsTTL = 5
+
+// clusterServiceSuffix is the zone answered authoritatively
+const clusterServiceSuffix = ".svc." + clusterDomain
+
+// DNSServer answers cluster service names of running forwards over UDP. Other
+// names are relayed to an upstream resolver or refused.
+type DNSServer struct {
+	addr     string
+	upstream string // "" = refuse names outside the cluster
+	lookup   func() []forwardedService
+	conn     net.PacketConn
+}
+
+// NewDNSServer creates a DNS server on addr (e.g. "127.0.0.1:5353") that resolves
+// against the services returned by lookup
+func NewDNSServer(addr, upstream string, lookup func() []forwardedService) *DNSServer {
+	return &DNSServer{addr: addr, upstream: upstream, lookup: lookup}
+}
+
+// Start binds the listener and serves until ctx is cancelled
+func (ds *DNSServer) Start(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", ds.addr)
+	if err != nil {
+		return err
+	}
+	ds.conn = conn
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go ds.serve()
+	return nil
+}
+
+// Addr returns the address the server listens on
+func (ds *DNSServer) Addr() net.Addr {
+	return ds.conn.LocalAddr()
+}
+
+func (ds *DNSServer) serve() {
+	buf := make([]byte, 4096)
+	for {
+		n, from, err := ds.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			debugLog("dns: read: %v", err)
+			continue
+		}
+		query := append([]byte(nil), buf[:n]...)
+		go func() {
+			resp, err := ds.handle(query)
+			if err != nil {
+				debugLog("dns: %v", err)
+				return
+			}
+			if resp != nil {
+				_, _ = ds.conn.WriteTo(resp, from)
+			}
+		}()
+	}
+}
+
+// handle builds the response to one query (nil = drop it)
+func (ds *DNSServer) handle(query []byte) ([]byte, error) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil {
+		return nil, err
+	}
+	if msg.Header.Response || len(msg.Questions) != 1 {
+		return reply(msg, dnsmessage.RCodeFormatError, nil)
+	}
+	q := msg.Questions[0]
+	name := strings.ToLower(strings.TrimSuffix(q.Name.String(), "."))
+
+	answers, found := resolveForwardedName(ds.lookup(), name, q)
+	if found {
+		debugLog("dns: %s %s -> %d answers", q.Type, name, len(answers))
+		return reply(msg, dnsmessage.RCodeSuccess, answers)
+	}
+	if strings.HasSuffix(name, clusterServiceSuffix) {
+		return reply(msg, dnsmessage.RCodeNameError, nil)
+	}
+	if ds.upstream == "" {
+		return reply(msg, dnsmessage.RCodeRefused, nil)
+	}
+	return ds.relay(query)
+}
+
+// relay sends the query unchanged to the upstream resolver and returns its response
+func (ds *DNSServer) relay(query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", ds.upstream, 3*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(3 * time.Second))
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func reply(query dnsmessage.Message, rcode dnsmessage.RCode, answers []dnsmessage.Resource) ([]byte, error) {
+	resp := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 query.Header.ID,
+			Response:           true,
+			Authoritative:      rcode == dnsmessage.RCodeSuccess || rcode == dnsmessage.RCodeNameError,
+			RecursionDesired:   query.Header.RecursionDesired,
+			RecursionAvailable: false,
+			RCode:              rcode,
+		},
+		Questions: query.Questions,
+		Answers:   answers,
+	}
+	return resp.Pack()
+}
+
+// resolveForwardedName answers q for a forwarded service. name is lower-case without
+// the trailing dot. Accepted forms are <svc>.<ns>, <svc>.<ns>.svc and
+// <svc>.<ns>.svc.cluster.local; SRV queries may add a _<port>._tcp prefix like
+// in-cluster DNS. found is false when no running service has the name.
+func resolveForwardedName(services []forwardedService, name string, q dnsmessage.Question) (answers []dnsmessage.Resource, found bool) {
+	portName := ""
+	if strings.HasPrefix(name, "_") {
+		parts := strings.SplitN(name, ".", 3)
+		if len(parts) != 3 || parts[1] != "_tcp" {
+			return nil, false
+		}
+		portName = strings.TrimPrefix(parts[0], "_")
+		name = parts[2]
+	}
+	base := strings.TrimSuffix(strings.TrimSuffix(name, "."+clusterDomain), ".svc")
+	labels := strings.Split(base, ".")
+	if len(labels) != 2 {
+		return nil, false
+	}
+
+	for _, fs := range services {
+		if !strings.EqualFold(fs.ServiceName, labels[0]) || !strings.EqualFold(fs.Namespace, labels[1]) {
+			continue
+		}
+		ip := net.ParseIP(fs.Address)
+		hdr := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: dnsTTL}
+		switch {
+		case portName != "":
+			if q.Type != dnsmessage.TypeSRV {
+				return nil, true
+			}
+			for _, pm := range fs.Ports {
+				if strings.EqualFold(pm.Name, portName) {
+					answers = append(answers, srvAnswer(hdr, fs, pm))
+				}
+			}
+			if len(answers) == 0 {
+				return nil, false
+			}
+		case q.Type == dnsmessage.TypeA && ip.To4() != nil:
+			hdr.Type = dnsmessage.TypeA
+			var a [4]byte
+			copy(a[:], ip.To4())
+			answers = append(answers, dnsmessage.Resource{Header: hdr, Body: &dnsmessage.AResource{A: a}})
+		case q.Type == dnsmessage.TypeAAAA && ip != nil && ip.To4() == nil:
+			hdr.Type = dnsmessage.TypeAAAA
+			var aaaa [16]byte
+			copy(aaaa[:], ip.To16())
+			answers = append(answers, dnsmessage.Resource{Header: hdr, Body: &dnsmessage.AAAAResource{AAAA: aaaa}})
+		case q.Type == dnsmessage.TypeSRV:
+			// One record per mapping, pointing at the local port
+			for _, pm := range fs.Ports {
+				answers = append(answers, srvAnswer(hdr, fs, pm))
+			}
+		}
+		return answers, true
+	}
+	return nil, false
+}
+
+// srvAnswer returns an SRV record for one port mapping of a forwarded service. The
+// target is the service FQDN, which this server resolves to the bind address.
+func srvAnswer(hdr dnsmessage.ResourceHeader, fs forwardedService, pm PortMapping) dnsmessage.Resource {
+	hdr.Type = dnsmessage.TypeSRV
+	target := dnsmessage.MustNewName(fs.ServiceName + "." + fs.Namespace + clusterServiceSuffix + ".")
+	return dnsmessage.Resource{Header: hdr, Body: &dnsmessage.SRVResource{
+		Priority: 0,
+		Weight:   100,
+		Port:     uint16(pm.LocalPort),
+		Target:   target,
+	}}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func dnsQuery(t *testing.T, addr net.Addr, name string, qtype dnsmessage.Type) dnsmessage.Message {
+	t.Helper()
+	q := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 42, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName(name), Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := q.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write(packed); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf[:n]); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.ID != 42 {
+		t.Fatalf("response id %d", resp.Header.ID)
+	}
+	return resp
+}
+
+func TestDNSServer(t *testing.T) {
+	services := []forwardedService{{
+		ServiceName: "orders",
+		Namespace:   "default",
+		Address:     "127.0.0.2",
+		Ports:       []PortMapping{{Name: "http", RemotePort: 80, LocalPort: 8082}, {Name: "metrics", RemotePort: 9090, LocalPort: 9190}},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ds := NewDNSServer("127.0.0.1:0", "", func() []forwardedService { return services })
+	if err := ds.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"orders.default.svc.cluster.local.", "orders.default.", "Orders.Default.svc."} {
+		resp := dnsQuery(t, ds.Addr(), name, dnsmessage.TypeA)
+		if resp.Header.RCode != dnsmessage.RCodeSuccess || len(resp.Answers) != 1 {
+			t.Fatalf("%s: rcode %v, %d answers", name, resp.Header.RCode, len(resp.Answers))
+		}
+		if a := resp.Answers[0].Body.(*dnsmessage.AResource).A; net.IP(a[:]).String() != "127.0.0.2" {
+			t.Fatalf("%s: A %v", name, a)
+		}
+	}
+
+	resp := dnsQuery(t, ds.Addr(), "orders.default.svc.cluster.local.", dnsmessage.TypeSRV)
+	if len(resp.Answers) != 2 || resp.Answers[1].Body.(*dnsmessage.SRVResource).Port != 9190 {
+		t.Fatalf("SRV answers: %+v", resp.Answers)
+	}
+	resp = dnsQuery(t, ds.Addr(), "_http._tcp.orders.default.svc.cluster.local.", dnsmessage.TypeSRV)
+	if len(resp.Answers) != 1 || resp.Answers[0].Body.(*dnsmessage.SRVResource).Port != 8082 {
+		t.Fatalf("named SRV answers: %+v", resp.Answers)
+	}
+
+	if resp := dnsQuery(t, ds.Addr(), "billing.default.svc.cluster.local.", dnsmessage.TypeA); resp.Header.RCode != dnsmessage.RCodeNameError {
+		t.Fatalf("unknown cluster name: rcode %v", resp.Header.RCode)
+	}
+	if resp := dnsQuery(t, ds.Addr(), "example.org.", dnsmessage.TypeA); resp.Header.RCode != dnsmessage.RCodeRefused {
+		t.Fatalf("external name without upstream: rcode %v", resp.Header.RCode)
+	}
+}
+
+func TestDNSServerRelaysToUpstream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	upstream := NewDNSServer("127.0.0.1:0", "", func() []forwardedService {
+		return []forwardedService{{ServiceName: "example", Namespace: "org", Address: "127.0.0.9"}}
+	})
+	if err := upstream.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ds := NewDNSServer("127.0.0.1:0", upstream.Addr().String(), func() []forwardedService { return nil })
+	if err := ds.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	resp := dnsQuery(t, ds.Addr(), "example.org.", dnsmessage.TypeA)
+	if len(resp.Answers) != 1 || resp.Answers[0].Body.(*dnsmessage.AResource).A != [4]byte{127, 0, 0, 9} {
+		t.Fatalf("relayed answers: %+v", resp.Answers)
+	}
+}
+
+func TestDNSServerResolvesArmedAndRetryingServices(t *testing.T) {
+	cfg := &Config{Namespace: "default", Services: []Service{
+		{Name: "orders", ServiceName: "orders", RemotePort: 80, LocalPort: 8082},
+		{Name: "billing", ServiceName: "billing", RemotePort: 80, LocalPort: 8083},
+		{Name: "users", ServiceName: "users", RemotePort: 80, LocalPort: 8084},
+	}}
+	ApplyConfigDefaults(cfg)
+	wa := NewWebApp(cfg, &FileConfigStore{Path: filepath.Join(t.TempDir(), "kubefwd.yaml")})
+	wa.portForwards[0].Status = StatusArmed
+	wa.portForwards[1].Status = StatusError
+	wa.portForwards[1].retrying = true
+	wa.portForwards[2].Status = StatusError
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ds := NewDNSServer("127.0.0.1:0", "", wa.forwardedServices)
+	if err := ds.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"orders.default.svc.cluster.local.", "billing.default.svc.cluster.local."} {
+		if resp := dnsQuery(t, ds.Addr(), name, dnsmessage.TypeA); len(resp.Answers) != 1 {
+			t.Fatalf("%s: rcode %v, %d answers", name, resp.Header.RCode, len(resp.Answers))
+		}
+	}
+	// A failed forward without a retry releases its port
+	if resp := dnsQuery(t, ds.Addr(), "users.default.svc.cluster.local.", dnsmessage.TypeA); resp.Header.RCode != dnsmessage.RCodeNameError {
+		t.Fatalf("failed service: rcode %v", resp.Header.RCode)
+	}
+}
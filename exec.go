@@ -0,0 +1,317 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Exit codes of kubefwd exec when the command did not run, as env(1) has them
+const (
+	exitExecFailed = 125 // Wrong usage, or the forwards did not come up
+	exitCannotRun  = 126
+	exitNotFound   = 127
+)
+
+// cliExec starts services and proxy services (or a preset) without the web server,
+// runs a command once they are ready and stops everything again, deleting the proxy
+// pods. It returns the exit code of the command.
+func cliExec(args []string) int {
+	// Flags and services come before --, the command after it
+	own, command := args, []string(nil)
+	for i, arg := range args {
+		if arg == "--" {
+			own, command = args[:i], args[i+1:]
+			break
+		}
+	}
+	o := newStoreFlags("exec", "[flags] [service...] -- command [args...]")
+	preset := o.flags.String("preset", "", "Start the services of this preset")
+	timeout := o.flags.Duration("timeout", 2*time.Minute, "How long to wait for the forwards to come up")
+	if err := o.parse(own); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitExecFailed
+	}
+	if len(command) == 0 || (*preset == "" && len(o.args) == 0) {
+		o.flags.Usage()
+		return exitExecFailed
+	}
+
+	var store ConfigStore = &FileConfigStore{Path: absPath(*o.configFile)}
+	if *o.dbPath != "" {
+		db, err := NewSQLiteConfigStore(absPath(*o.dbPath))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening SQLite store: %v\n", err)
+			return exitExecFailed
+		}
+		defer func() { _ = db.Close() }()
+		store = db
+	}
+
+	// The forwards would collide with those of a kubefwd running on the same config
+	inst, err := lockInstance(store.Description())
+	if errors.Is(err, errAlreadyRunning) {
+		fmt.Fprintf(os.Stderr, "Error: %v; use `kubefwd start` with it instead\n", err)
+		return exitExecFailed
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot lock %s: %v\n", store.Description(), err)
+	}
+	if inst != nil {
+		defer inst.Close()
+	}
+
+	config, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration from %s: %v\n", store.Description(), err)
+		return exitExecFailed
+	}
+	names, lazy, err := execTargets(config, *preset, o.args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitExecFailed
+	}
+	if err := ValidateContextForEngine(config.ForwardEngine, config.ClusterContext); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitExecFailed
+	}
+
+	app := NewWebApp(config, store)
+	app.closeSession() // A run of exec is not a session to restore
+	if inst != nil {
+		// kubefwd status and friends work while the command runs
+		if err := inst.Serve(app.routes()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot serve the control socket %s: %v\n", inst.socketPath, err)
+		}
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	order := newDependencyGraph(config).startOrder(names)
+	fmt.Fprintf(os.Stderr, "Starting %s…\n", strings.Join(order, ", "))
+	ready := make(chan struct{})
+	go func() {
+		app.startWithDependencies(names, lazy)
+		close(ready)
+	}()
+	defer func() {
+		select {
+		case <-ready:
+			app.Shutdown()
+		default:
+			// Shutdown makes the start plan give up; what it brought up meanwhile (e.g. a
+			// proxy pod that was being created) is stopped once it has returned
+			app.Shutdown()
+			<-ready
+			app.StopAll()
+		}
+	}()
+	select {
+	case <-ready:
+	case <-time.After(*timeout):
+		fmt.Fprintf(os.Stderr, "Timed out after %s waiting for the forwards to come up\n", *timeout)
+		app.reportDown(order)
+		return exitExecFailed
+	case sig := <-sigChan:
+		fmt.Fprintf(os.Stderr, "\nInterrupted, stopping…\n")
+		return 128 + int(sig.(syscall.Signal))
+	}
+	if app.reportDown(order) {
+		return exitExecFailed
+	}
+	if armed := app.armedForwards(order); len(armed) > 0 {
+		fmt.Fprintf(os.Stderr, "Armed, starting on the first connection: %s\n", strings.Join(armed, ", "))
+	}
+	app.syncHosts()
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), forwardEnv(config, order)...)
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+			return exitNotFound
+		}
+		return exitCannotRun
+	}
+	done := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case sig := <-sigChan:
+			// Ctrl+C reaches the command through the terminal; a SIGTERM is passed on
+			if sig == syscall.SIGTERM {
+				_ = cmd.Process.Signal(sig)
+			}
+		case <-done:
+			fmt.Fprintf(os.Stderr, "Stopping %s…\n", strings.Join(order, ", "))
+			return commandExitCode(cmd.ProcessState)
+		}
+	}
+}
+
+// execTargets returns the services and proxy services exec starts: those of preset
+// (if set) and names. lazy arms the services of a lazy preset.
+func execTargets(cfg *Config, preset string, names []string) (targets []string, lazy bool, err error) {
+	if preset != "" {
+		found := false
+		for _, p := range cfg.Presets {
+			if p.Name == preset {
+				targets, lazy, found = append(targets, p.Services...), p.Lazy, true
+				break
+			}
+		}
+		if !found {
+			return nil, false, fmt.Errorf("preset %q not found", preset)
+		}
+	}
+	known := make(map[string]bool)
+	for _, s := range cfg.Services {
+		known[s.Name] = true
+	}
+	for _, ps := range cfg.ProxyServices {
+		known[ps.Name] = true
+	}
+	for _, name := range names {
+		if !known[name] {
+			return nil, false, fmt.Errorf("no service or proxy service named %q", name)
+		}
+		targets = append(targets, name)
+	}
+	return targets, lazy, nil
+}
+
+// reportDown prints the forwards of names that are not running or armed, and
+// reports whether there were any. An armed forward counts as up without a readiness
+// check, like in start plans: it starts on the first connection.
+func (wa *WebApp) reportDown(names []string) bool {
+	down := false
+	for _, name := range names {
+		st, errMsg := wa.forwardStatus(name)
+		if st == StatusRunning || st == StatusArmed {
+			continue
+		}
+		down = true
+		wa.mu.RLock()
+		note := wa.waiting[name]
+		wa.mu.RUnlock()
+		switch {
+		case errMsg != "":
+			fmt.Fprintf(os.Stderr, "  %s: %s: %s\n", name, st, errMsg)
+		case note != "":
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", name, note)
+		default:
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", name, st)
+		}
+	}
+	return down
+}
+
+// armedForwards returns the forwards of names that are armed: kubefwd holds their
+// local port, but the forward itself only starts (and may fail) on the first connection
+func (wa *WebApp) armedForwards(names []string) []string {
+	var armed []string
+	for _, name := range names {
+		if st, _ := wa.forwardStatus(name); st == StatusArmed {
+			armed = append(armed, name)
+		}
+	}
+	return armed
+}
+
+// forwardStatus returns the status of the service or proxy service name
+func (wa *WebApp) forwardStatus(name string) (PortForwardStatus, string) {
+	wa.mu.RLock()
+	defer wa.mu.RUnlock()
+	for _, pf := range wa.portForwards {
+		if pf.Service.Name == name {
+			return pf.GetStatus()
+		}
+	}
+	if pxf, ok := wa.proxyForwards[name]; ok {
+		return pxf.GetStatus()
+	}
+	return StatusStopped, ""
+}
+
+// forwardEnv returns the environment variables describing the forwards of names:
+// KUBEFWD_<NAME>_HOST, _PORT and _ADDR for the (first) local port, _PORT_<PORT NAME>
+// for each named port and _SQL_TAP_PORT when sql-tap is in front of it. KUBEFWD_CONTEXT
+// and KUBEFWD_NAMESPACE name the cluster.
+func forwardEnv(cfg *Config, names []string) []string {
+	env := []string{"KUBEFWD_CONTEXT=" + cfg.ClusterContext, "KUBEFWD_NAMESPACE=" + cfg.Namespace}
+	add := func(name, bindAddress string, ports []PortMapping, sqlTapPort *int) {
+		prefix := "KUBEFWD_" + envName(name) + "_"
+		host := hostsAddress(bindAddress)
+		port := strconv.Itoa(ports[0].LocalPort)
+		env = append(env, prefix+"HOST="+host, prefix+"PORT="+port, prefix+"ADDR="+net.JoinHostPort(host, port))
+		for _, pm := range ports {
+			if pm.Name != "" {
+				env = append(env, prefix+"PORT_"+envName(pm.Name)+"="+strconv.Itoa(pm.LocalPort))
+			}
+		}
+		if sqlTapPort != nil {
+			env = append(env, prefix+"SQL_TAP_PORT="+strconv.Itoa(*sqlTapPort))
+		}
+	}
+	for _, name := range names {
+		for i := range cfg.Services {
+			if s := &cfg.Services[i]; s.Name == name {
+				add(s.Name, s.BindAddress, s.PortMappings(), s.SqlTapPort)
+			}
+		}
+		for _, ps := range cfg.ProxyServices {
+			if ps.Name == name {
+				add(ps.Name, ps.BindAddress, []PortMapping{{LocalPort: ps.LocalPort}}, ps.SqlTapPort)
+			}
+		}
+	}
+	return env
+}
+
+// envName turns a service or port name into an environment variable name part:
+// "Backend API" becomes BACKEND_API
+func envName(name string) string {
+	var b strings.Builder
+	separate := false
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			if separate {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+			separate = false
+		} else if b.Len() > 0 {
+			separate = true
+		}
+	}
+	return b.String()
+}
+
+// commandExitCode returns the exit code of a finished command; one killed by a
+// signal gets 128 plus the signal number, like in a shell
+func commandExitCode(state *os.ProcessState) int {
+	if state == nil {
+		return exitExecFailed
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return state.ExitCode()
+}
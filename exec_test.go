@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestForwardEnv(t *testing.T) {
+	tapPort := 5433
+	cfg := &Config{ClusterContext: "prod", Namespace: "default",
+		Services: []Service{
+			{Name: "Backend API", ServiceName: "api", Ports: []PortMapping{
+				{Name: "http", RemotePort: 80, LocalPort: 8080},
+				{Name: "grpc-web", RemotePort: 9090, LocalPort: 9090},
+			}},
+			{Name: "Database", ServiceName: "postgres", BindAddress: "127.0.0.2", RemotePort: 5432, LocalPort: 5432, SqlTapPort: &tapPort},
+			{Name: "Idle", ServiceName: "idle", RemotePort: 80, LocalPort: 8081},
+		},
+		ProxyServices: []ProxyService{{Name: "cloud-sql", TargetHost: "10.0.0.5", TargetPort: 5432, LocalPort: 5434}},
+	}
+	got := forwardEnv(cfg, []string{"Backend API", "Database", "cloud-sql"})
+	want := []string{
+		"KUBEFWD_CONTEXT=prod",
+		"KUBEFWD_NAMESPACE=default",
+		"KUBEFWD_BACKEND_API_HOST=127.0.0.1",
+		"KUBEFWD_BACKEND_API_PORT=8080",
+		"KUBEFWD_BACKEND_API_ADDR=127.0.0.1:8080",
+		"KUBEFWD_BACKEND_API_PORT_HTTP=8080",
+		"KUBEFWD_BACKEND_API_PORT_GRPC_WEB=9090",
+		"KUBEFWD_DATABASE_HOST=127.0.0.2",
+		"KUBEFWD_DATABASE_PORT=5432",
+		"KUBEFWD_DATABASE_ADDR=127.0.0.2:5432",
+		"KUBEFWD_DATABASE_SQL_TAP_PORT=5433",
+		"KUBEFWD_CLOUD_SQL_HOST=127.0.0.1",
+		"KUBEFWD_CLOUD_SQL_PORT=5434",
+		"KUBEFWD_CLOUD_SQL_ADDR=127.0.0.1:5434",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("env:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestExecTargets(t *testing.T) {
+	cfg := &Config{
+		Services:      []Service{{Name: "API"}, {Name: "Metrics"}},
+		ProxyServices: []ProxyService{{Name: "CloudSQL"}},
+		Presets:       []Preset{{Name: "Backend", Services: []string{"API", "CloudSQL"}, Lazy: true}},
+	}
+	names, lazy, err := execTargets(cfg, "Backend", []string{"Metrics"})
+	if err != nil || !lazy || !reflect.DeepEqual(names, []string{"API", "CloudSQL", "Metrics"}) {
+		t.Fatalf("targets: %v %v %v", names, lazy, err)
+	}
+	if _, _, err := execTargets(cfg, "Frontend", nil); err == nil {
+		t.Fatal("unknown preset accepted")
+	}
+	if _, _, err := execTargets(cfg, "", []string{"Missing"}); err == nil {
+		t.Fatal("unknown service accepted")
+	}
+}
+
+func TestExecExitCodes(t *testing.T) {
+	if code := cliExec([]string{"API"}); code != exitExecFailed {
+		t.Fatalf("without a command: exit code %d", code)
+	}
+	if code := cliExec([]string{"--", "true"}); code != exitExecFailed {
+		t.Fatalf("without services: exit code %d", code)
+	}
+	for script, want := range map[string]int{"exit 0": 0, "exit 3": 3, "kill -TERM $$": 143} {
+		cmd := exec.Command("sh", "-c", script)
+		_ = cmd.Run()
+		if code := commandExitCode(cmd.ProcessState); code != want {
+			t.Fatalf("%s: exit code %d, want %d", script, code, want)
+		}
+	}
+}
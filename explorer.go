@@ -22,6 +22,7 @@ type K8sServicePort struct {
 }
 
 type K8sServiceInfo struct {
+	Kind      string           `json:"kind"` // service, pod, deployment or statefulset
 	Name      string           `json:"name"`
 	Namespace string           `json:"namespace"`
 	Type      string           `json:"type"`
@@ -116,7 +117,26 @@ func (e *Explorer) DiscoverNamespaces(kubeCtx string) ([]string, error) {
 	return namespaces, nil
 }
 
-func (e *Explorer) DiscoverServices(kubeCtx, namespace string, config *Config) ([]K8sServiceInfo, error) {
+// DiscoverServices lists forwardable targets of the given kind (service by default).
+// Pods and workloads are listed with their container ports.
+func (e *Explorer) DiscoverServices(kubeCtx, namespace, kind string, config *Config) ([]K8sServiceInfo, error) {
+	if kind == "" {
+		kind = TargetKindService
+	}
+	if !isValidTargetKind(kind) {
+		return nil, fmt.Errorf("unknown kind %q", kind)
+	}
+
+	configTargets := make(map[string]bool)
+	if config != nil {
+		for _, s := range config.Services {
+			configTargets[s.Resource()] = true
+		}
+	}
+	if kind != TargetKindService {
+		return e.discoverPodsAndWorkloads(kubeCtx, namespace, kind, configTargets)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), explorerTimeout)
 	defer cancel()
 
@@ -151,13 +171,6 @@ func (e *Explorer) DiscoverServices(kubeCtx, namespace string, config *Config) (
 		return nil, fmt.Errorf("failed to parse services JSON: %w", err)
 	}
 
-	configSvcNames := make(map[string]bool)
-	if config != nil {
-		for _, s := range config.Services {
-			configSvcNames[s.ServiceName] = true
-		}
-	}
-
 	var services []K8sServiceInfo
 	for _, item := range result.Items {
 		if item.Spec.Type == "ExternalName" {
@@ -175,17 +188,105 @@ func (e *Explorer) DiscoverServices(kubeCtx, namespace string, config *Config) (
 		}
 
 		services = append(services, K8sServiceInfo{
+			Kind:      TargetKindService,
 			Name:      item.Metadata.Name,
 			Namespace: item.Metadata.Namespace,
 			Type:      item.Spec.Type,
 			ClusterIP: item.Spec.ClusterIP,
 			Ports:     ports,
-			InConfig:  configSvcNames[item.Metadata.Name],
+			InConfig:  configTargets[TargetKindService+"/"+item.Metadata.Name],
 		})
 	}
 	return services, nil
 }
 
+type k8sContainerJSON struct {
+	Ports []struct {
+		Name          string `json:"name"`
+		ContainerPort int    `json:"containerPort"`
+		Protocol      string `json:"protocol"`
+	} `json:"ports"`
+}
+
+// discoverPodsAndWorkloads lists pods, deployments or statefulsets. Type holds the pod
+// phase or the ready replica count, ClusterIP the pod IP.
+func (e *Explorer) discoverPodsAndWorkloads(kubeCtx, namespace, kind string, configTargets map[string]bool) ([]K8sServiceInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), explorerTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "kubectl", "get", kind+"s",
+		"--context", kubeCtx,
+		"-n", namespace,
+		"-o", "json")
+	out, err := debugRunCmd(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %ss: %w", kind, err)
+	}
+
+	var result struct {
+		Items []struct {
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			Spec struct {
+				Replicas   *int               `json:"replicas"`
+				Containers []k8sContainerJSON `json:"containers"` // pods
+				Template   struct {
+					Spec struct {
+						Containers []k8sContainerJSON `json:"containers"`
+					} `json:"spec"`
+				} `json:"template"` // workloads
+			} `json:"spec"`
+			Status struct {
+				Phase         string `json:"phase"`
+				PodIP         string `json:"podIP"`
+				ReadyReplicas int    `json:"readyReplicas"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse %ss JSON: %w", kind, err)
+	}
+
+	var targets []K8sServiceInfo
+	for _, item := range result.Items {
+		containers := item.Spec.Containers
+		typ := item.Status.Phase
+		if kind != TargetKindPod {
+			containers = item.Spec.Template.Spec.Containers
+			replicas := 1
+			if item.Spec.Replicas != nil {
+				replicas = *item.Spec.Replicas
+			}
+			typ = fmt.Sprintf("%d/%d ready", item.Status.ReadyReplicas, replicas)
+		}
+
+		var ports []K8sServicePort
+		for _, c := range containers {
+			for _, p := range c.Ports {
+				ports = append(ports, K8sServicePort{
+					Name:       p.Name,
+					Port:       p.ContainerPort,
+					TargetPort: p.ContainerPort,
+					Protocol:   p.Protocol,
+				})
+			}
+		}
+
+		targets = append(targets, K8sServiceInfo{
+			Kind:      kind,
+			Name:      item.Metadata.Name,
+			Namespace: item.Metadata.Namespace,
+			Type:      typ,
+			ClusterIP: item.Status.PodIP,
+			Ports:     ports,
+			InConfig:  configTargets[kind+"/"+item.Metadata.Name],
+		})
+	}
+	return targets, nil
+}
+
 func (e *Explorer) isGcloudAvailable() bool {
 	e.gcloudOnce.Do(func() {
 		cmd := exec.Command("gcloud", "version")
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Forward engines selectable through forward_engine
+const (
+	ForwardEngineKubectl = "kubectl" // spawn `kubectl port-forward` (default)
+	ForwardEngineNative  = "native"  // speak the port-forward protocol in-process via client-go
+)
+
+func isValidForwardEngine(engine string) bool {
+	return engine == ForwardEngineKubectl || engine == ForwardEngineNative
+}
+
+// Target kinds selectable through a service's kind field
+const (
+	TargetKindService     = "service" // default
+	TargetKindPod         = "pod"
+	TargetKindDeployment  = "deployment"
+	TargetKindStatefulSet = "statefulset"
+)
+
+func isValidTargetKind(kind string) bool {
+	switch kind {
+	case TargetKindService, TargetKindPod, TargetKindDeployment, TargetKindStatefulSet:
+		return true
+	}
+	return false
+}
+
+// ValidateContextForEngine checks that a context exists. The native engine reads the
+// kubeconfig directly so it keeps working when kubectl is not on PATH.
+func ValidateContextForEngine(engine, context string) error {
+	if engine == ForwardEngineNative {
+		return ValidateKubeconfigContext(context)
+	}
+	return ValidateContext(context)
+}
+
+// forwardSpec describes a single port-forward session independent of the engine running it
+type forwardSpec struct {
+	Context   string
+	Namespace string
+	Resource  string   // kubectl-style target, e.g. "service/api", "statefulset/postgres" or "pod/kubefwd-proxy"
+	Ports     []string // "local:remote" pairs
+	Address   string   // Local bind address; "" keeps kubectl's default (localhost)
+	Selector  string   // Label selector; when set the target pod is resolved at start and Resource is only for display
+}
+
+// kubectlArgs returns the kubectl arguments equivalent to this spec
+func (fs forwardSpec) kubectlArgs() []string {
+	args := []string{
+		"--context=" + fs.Context,
+		"-n", fs.Namespace,
+		"port-forward",
+	}
+	if fs.Address != "" {
+		args = append(args, "--address="+fs.Address)
+	}
+	args = append(args, fs.Resource)
+	return append(args, fs.Ports...)
+}
+
+// commandString renders the spec for display and debug output. Native forwards are
+// shown with the kubectl arguments they emulate so both engines read the same way.
+func (fs forwardSpec) commandString(engine string) string {
+	args := strings.Join(fs.kubectlArgs(), " ")
+	if engine == ForwardEngineNative {
+		return "native " + args
+	}
+	return "kubectl " + args
+}
+
+// forwarder is a running port-forward session. PortForward and ProxyForward only
+// talk to this interface, so retry and status handling is shared by every engine.
+type forwarder interface {
+	// Wait blocks until the session ends. A nil error means it was stopped cleanly.
+	Wait() error
+	// PID returns the process that listens on the internal upstream port the relay
+	// connects to (the local port itself is held by the relay in kubefwd).
+	PID() int
+}
+
+// podNamer is implemented by forwarders that pick their pod at runtime
+type podNamer interface {
+	PodName() string
+}
+
+// startForwarder launches spec using the given engine. Output that kubectl would
+// print on stdout/stderr ("Forwarding from ...", errors) is written to stdout/stderr.
+// Cancelling ctx stops the session.
+func startForwarder(ctx context.Context, engine string, spec forwardSpec, stdout, stderr io.Writer) (forwarder, error) {
+	if spec.Selector != "" {
+		return startSelectorForwarder(ctx, engine, spec, stdout, stderr)
+	}
+	switch engine {
+	case ForwardEngineNative:
+		return startNativeForwarder(ctx, spec, stdout, stderr)
+	case ForwardEngineKubectl, "":
+		return startKubectlForwarder(ctx, spec, stdout, stderr)
+	default:
+		return nil, fmt.Errorf("unknown forward engine %q", engine)
+	}
+}
+
+// kubectlForwarder runs `kubectl port-forward` as a child process
+type kubectlForwarder struct {
+	cmd *exec.Cmd
+}
+
+func startKubectlForwarder(ctx context.Context, spec forwardSpec, stdout, stderr io.Writer) (forwarder, error) {
+	cmd := exec.CommandContext(ctx, "kubectl", spec.kubectlArgs()...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &kubectlForwarder{cmd: cmd}, nil
+}
+
+func (kf *kubectlForwarder) Wait() error {
+	return kf.cmd.Wait()
+}
+
+func (kf *kubectlForwarder) PID() int {
+	if kf.cmd.Process != nil {
+		return kf.cmd.Process.Pid
+	}
+	return 0
+}
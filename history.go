@@ -0,0 +1,277 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Kinds of status history entries
+const (
+	historyKindService      = "service"
+	historyKindProxyService = "proxy_service"
+	historyKindProxyPod     = "proxy_pod" // Named by "context/namespace" of the pod
+	historyKindSqlTap       = "sql_tap"   // Named by the service running sql-tapd
+)
+
+// historyLimit is how many transitions are kept in memory
+const historyLimit = 10000
+
+// historyFlushInterval is how often recorded transitions are written to the store
+var historyFlushInterval = 2 * time.Second
+
+// transition is one status change of a forward, proxy pod or sql-tapd
+type transition struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Name   string    `json:"name"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// historySink persists transitions. The SQLite store implements it.
+type historySink interface {
+	SaveTransitions([]transition) error
+	LoadTransitions(since time.Time) ([]transition, error)
+	PruneTransitions(before time.Time) error
+}
+
+// statusHistory records status transitions in memory, oldest first, and writes them
+// to a sink while persistence is on
+type statusHistory struct {
+	mu        sync.Mutex
+	limit     int
+	entries   []transition
+	sink      historySink
+	loaded    historySink // Sink whose earlier transitions were already loaded
+	retention time.Duration
+	pending   []transition // Recorded but not yet written to the sink
+	lastPrune time.Time
+	flushing  bool // The flush loop is running
+}
+
+// history is the status history of this kubefwd process
+var history = newStatusHistory(historyLimit)
+
+func newStatusHistory(limit int) *statusHistory {
+	return &statusHistory{limit: limit}
+}
+
+// record adds a transition that happened now
+func (h *statusHistory) record(kind, name, from, to, reason string) {
+	t := transition{Time: time.Now(), Kind: kind, Name: name, From: from, To: to, Reason: reason}
+	debugLog("status %s %s: %s -> %s (%s)", kind, name, from, to, reason)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.addLocked(t)
+	if h.sink != nil {
+		h.pending = append(h.pending, t)
+	}
+}
+
+// addLocked appends t and drops the oldest entries beyond the limit, in chunks so
+// the slice is not copied on every transition
+func (h *statusHistory) addLocked(t transition) {
+	h.entries = append(h.entries, t)
+	if len(h.entries) > h.limit+h.limit/10 {
+		h.entries = append([]transition(nil), h.entries[len(h.entries)-h.limit:]...)
+	}
+}
+
+// persist writes transitions to sink and keeps them for retention. The first time a
+// sink is used, its transitions within the retention are loaded in front of the
+// ones recorded so far. A nil sink turns persistence off.
+func (h *statusHistory) persist(sink historySink, retention time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sink = sink
+	h.retention = retention
+	if sink == nil {
+		h.pending = nil
+		return nil
+	}
+	if h.loaded != sink {
+		h.loaded = sink
+		earlier, err := sink.LoadTransitions(time.Now().Add(-retention))
+		if err != nil {
+			return err
+		}
+		current := h.entries
+		h.entries = nil
+		for _, t := range earlier {
+			h.addLocked(t)
+		}
+		for _, t := range current {
+			h.addLocked(t)
+		}
+		// Transitions recorded before persistence was turned on are written too
+		h.pending = append(append([]transition(nil), current...), h.pending...)
+	}
+	if !h.flushing {
+		h.flushing = true
+		go h.flushLoop()
+	}
+	return nil
+}
+
+// flushLoop writes pending transitions until persistence is turned off
+func (h *statusHistory) flushLoop() {
+	for {
+		time.Sleep(historyFlushInterval)
+		h.mu.Lock()
+		if h.sink == nil {
+			h.flushing = false
+			h.mu.Unlock()
+			return
+		}
+		h.mu.Unlock()
+		h.flush()
+	}
+}
+
+// flush writes pending transitions to the sink and prunes ones older than the
+// retention once an hour. Failed writes are retried with the next flush.
+func (h *statusHistory) flush() {
+	h.mu.Lock()
+	sink, batch, retention := h.sink, h.pending, h.retention
+	h.pending = nil
+	prune := sink != nil && time.Since(h.lastPrune) >= time.Hour
+	if prune {
+		h.lastPrune = time.Now()
+	}
+	h.mu.Unlock()
+	if sink == nil {
+		return
+	}
+	if len(batch) > 0 {
+		if err := sink.SaveTransitions(batch); err != nil {
+			debugLog("status history: cannot save %d transitions: %v", len(batch), err)
+			h.mu.Lock()
+			if h.sink == sink {
+				h.pending = append(batch, h.pending...)
+			}
+			h.mu.Unlock()
+		}
+	}
+	if prune {
+		if err := sink.PruneTransitions(time.Now().Add(-retention)); err != nil {
+			debugLog("status history: cannot prune: %v", err)
+		}
+	}
+}
+
+// historyFilter selects transitions; empty fields match everything
+type historyFilter struct {
+	Kind string
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+func (f historyFilter) matchesKey(t transition) bool {
+	return (f.Kind == "" || t.Kind == f.Kind) && (f.Name == "" || t.Name == f.Name)
+}
+
+// Transitions returns the transitions matching f, oldest first
+func (h *statusHistory) Transitions(f historyFilter) []transition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := []transition{}
+	for _, t := range h.entries {
+		if f.matchesKey(t) && !t.Time.Before(f.From) && !t.Time.After(f.To) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// uptime summarizes the history of one forward, proxy pod or sql-tapd over a time
+// range. Only the time it was wanted counts: stopped periods are left out.
+type uptime struct {
+	Kind        string   `json:"kind"`
+	Name        string   `json:"name"`
+	UpSeconds   float64  `json:"up_seconds"`     // Running, armed or ready
+	DownSeconds float64  `json:"down_seconds"`   // Starting, creating, error or retrying
+	Percent     *float64 `json:"uptime_percent"` // Up share of up + down; null if it never ran in the range
+	Drops       int      `json:"drops"`          // Changes from up to error
+}
+
+// historyStatusUp reports whether status means the forward or pod is usable
+func historyStatusUp(status string) bool {
+	return status == string(StatusRunning) || status == string(StatusArmed) || status == string(ProxyPodStatusReady)
+}
+
+// historyStatusIdle reports whether status means it was not meant to run
+func historyStatusIdle(status string) bool {
+	return status == "" || status == string(StatusStopped) || status == string(ProxyPodStatusNotCreated)
+}
+
+// Uptime returns the uptime of everything matching f's kind and name over f's range,
+// in the order it first appears in the history
+func (h *statusHistory) Uptime(f historyFilter) []uptime {
+	type key struct{ kind, name string }
+	h.mu.Lock()
+	byKey := make(map[key][]transition)
+	var keys []key
+	for _, t := range h.entries {
+		if !f.matchesKey(t) || t.Time.After(f.To) {
+			continue
+		}
+		k := key{t.Kind, t.Name}
+		if _, ok := byKey[k]; !ok {
+			keys = append(keys, k)
+		}
+		byKey[k] = append(byKey[k], t)
+	}
+	h.mu.Unlock()
+
+	out := []uptime{}
+	for _, k := range keys {
+		u := computeUptime(byKey[k], f.From, f.To)
+		u.Kind, u.Name = k.kind, k.name
+		out = append(out, u)
+	}
+	return out
+}
+
+// computeUptime sums up and down time between from and to for the transitions of one
+// entry, oldest first. Before its first transition it was in that transition's
+// From status.
+func computeUptime(entries []transition, from, to time.Time) uptime {
+	var u uptime
+	if len(entries) == 0 || !to.After(from) {
+		return u
+	}
+	status := entries[0].From
+	cursor := from
+	account := func(until time.Time) {
+		d := until.Sub(cursor).Seconds()
+		switch {
+		case d <= 0 || historyStatusIdle(status):
+		case historyStatusUp(status):
+			u.UpSeconds += d
+		default:
+			u.DownSeconds += d
+		}
+	}
+	for _, t := range entries {
+		if !t.Time.After(from) {
+			status = t.To
+			continue
+		}
+		if t.Time.After(to) {
+			break
+		}
+		account(t.Time)
+		if historyStatusUp(t.From) && t.To == string(StatusError) {
+			u.Drops++
+		}
+		status, cursor = t.To, t.Time
+	}
+	account(to)
+	if total := u.UpSeconds + u.DownSeconds; total > 0 {
+		p := 100 * u.UpSeconds / total
+		u.Percent = &p
+	}
+	return u
+}
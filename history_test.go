@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeUptime(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time { return t0.Add(time.Duration(m) * time.Minute) }
+	entries := []transition{
+		{Time: at(-30), From: "stopped", To: "starting"},
+		{Time: at(-29), From: "starting", To: "running"},
+		{Time: at(10), From: "running", To: "error"},    // drop
+		{Time: at(15), From: "error", To: "starting"},   // reconnecting
+		{Time: at(20), From: "starting", To: "running"}, // back up
+		{Time: at(50), From: "running", To: "stopped"},  // stopped by hand: not counted
+		{Time: at(70), From: "stopped", To: "starting"}, // after the range
+	}
+	u := computeUptime(entries, at(0), at(60))
+	if u.UpSeconds != 40*60 || u.DownSeconds != 10*60 || u.Drops != 1 {
+		t.Fatalf("uptime: %+v", u)
+	}
+	if u.Percent == nil || *u.Percent != 80 {
+		t.Fatalf("percent: %v", u.Percent)
+	}
+
+	// Never started within the range: no percentage
+	u = computeUptime(entries, at(55), at(65))
+	if u.Percent != nil || u.UpSeconds != 0 || u.DownSeconds != 0 {
+		t.Fatalf("idle range: %+v", u)
+	}
+
+	// Before its first transition it was in that transition's From status
+	u = computeUptime([]transition{{Time: at(30), From: "ready", To: "error"}}, at(0), at(60))
+	if u.UpSeconds != 30*60 || u.DownSeconds != 30*60 || u.Drops != 1 {
+		t.Fatalf("proxy pod uptime: %+v", u)
+	}
+}
+
+type memoryHistorySink struct {
+	saved []transition
+}
+
+func (m *memoryHistorySink) SaveTransitions(ts []transition) error {
+	m.saved = append(m.saved, ts...)
+	return nil
+}
+
+func (m *memoryHistorySink) LoadTransitions(since time.Time) ([]transition, error) {
+	var out []transition
+	for _, t := range m.saved {
+		if !t.Time.Before(since) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryHistorySink) PruneTransitions(before time.Time) error { return nil }
+
+func TestStatusHistoryPersistAndFilter(t *testing.T) {
+	sink := &memoryHistorySink{saved: []transition{
+		{Time: time.Now().Add(-48 * time.Hour), Kind: historyKindService, Name: "A", From: "stopped", To: "running"},
+		{Time: time.Now().Add(-time.Hour), Kind: historyKindService, Name: "A", From: "running", To: "error"},
+	}}
+	h := newStatusHistory(100)
+	h.record(historyKindSqlTap, "A", "stopped", "running", "running")
+	if err := h.persist(sink, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	h.record(historyKindProxyPod, "ctx/ns", "not_created", "creating", "creating")
+	h.flush()
+	defer h.persist(nil, 0)
+
+	all := h.Transitions(historyFilter{From: time.Now().Add(-24 * time.Hour), To: time.Now()})
+	if len(all) != 3 || all[0].To != "error" || all[1].Kind != historyKindSqlTap {
+		t.Fatalf("history after loading: %+v", all)
+	}
+	if len(sink.saved) != 4 {
+		t.Fatalf("saved transitions: %+v", sink.saved)
+	}
+	onlyA := h.Transitions(historyFilter{Name: "A", Kind: historyKindService, From: time.Now().Add(-24 * time.Hour), To: time.Now()})
+	if len(onlyA) != 1 {
+		t.Fatalf("filtered: %+v", onlyA)
+	}
+	if up := h.Uptime(historyFilter{Name: "A", From: time.Now().Add(-2 * time.Hour), To: time.Now()}); len(up) != 2 || up[0].Kind != historyKindService || up[0].Drops != 1 {
+		t.Fatalf("uptime per entry: %+v", up)
+	}
+}
+
+func TestStatusHistoryLimit(t *testing.T) {
+	h := newStatusHistory(10)
+	for i := 0; i < 50; i++ {
+		h.record(historyKindService, "A", "running", "error", "")
+	}
+	if n := len(h.Transitions(historyFilter{To: time.Now()})); n < 10 || n > 11 {
+		t.Fatalf("kept %d transitions", n)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hookRunner runs the lifecycle hooks of one forward
+type hookRunner struct {
+	name  string // Service name, used in debug log lines
+	hooks *Hooks
+	env   []string // KUBEFWD_* variables describing the forward
+}
+
+// newHookRunner returns a runner for hooks, or nil when the service has none
+func newHookRunner(name string, hooks *Hooks, env []string) *hookRunner {
+	if hooks == nil {
+		return nil
+	}
+	return &hookRunner{name: name, hooks: hooks, env: env}
+}
+
+// hookEnv returns the environment that tells a hook which forward it runs for.
+// KUBEFWD_LOCAL_PORT is the first local port; KUBEFWD_LOCAL_PORTS lists all of them.
+func hookEnv(name, bindAddress string, localPorts []int, kubeContext, namespace string) []string {
+	ports := make([]string, len(localPorts))
+	for i, p := range localPorts {
+		ports[i] = strconv.Itoa(p)
+	}
+	first := ""
+	if len(ports) > 0 {
+		first = ports[0]
+	}
+	return []string{
+		"KUBEFWD_SERVICE=" + name,
+		"KUBEFWD_HOST=" + dialHost(bindAddress),
+		"KUBEFWD_LOCAL_PORT=" + first,
+		"KUBEFWD_LOCAL_PORTS=" + strings.Join(ports, ","),
+		"KUBEFWD_CONTEXT=" + kubeContext,
+		"KUBEFWD_NAMESPACE=" + namespace,
+	}
+}
+
+// has reports whether phase has any commands (false for a nil runner)
+func (h *hookRunner) has(phase string) bool {
+	return h != nil && len(h.hooks.Commands(phase)) > 0
+}
+
+// failOnPostStart reports whether a failing post_start hook fails the forward
+func (h *hookRunner) failOnPostStart() bool {
+	return h != nil && h.hooks.FailOnPostStart
+}
+
+// run runs the commands of phase in order and stops at the first failure. A nil
+// runner does nothing.
+func (h *hookRunner) run(phase string) error {
+	if h == nil {
+		return nil
+	}
+	for _, command := range h.hooks.Commands(phase) {
+		if err := h.runCommand(phase, command); err != nil {
+			return fmt.Errorf("%s hook %q: %w", phase, command, err)
+		}
+	}
+	return nil
+}
+
+// runCommand runs one hook command with sh -c, streaming its output into the debug log
+func (h *hookRunner) runCommand(phase, command string) error {
+	timeout := h.hooks.GetTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), h.env...)
+	cmd.Env = append(cmd.Env, "KUBEFWD_HOOK="+phase)
+	prefix := fmt.Sprintf("hook %s %s", h.name, phase)
+	out := &lineWriter{emit: func(line string) { debugLog("%s: %s", prefix, line) }}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.WaitDelay = 2 * time.Second // Don't wait for background children holding the output open
+
+	debugLog("hook %s %s: %s", h.name, phase, command)
+	start := time.Now()
+	err := cmd.Run()
+	out.flush()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		debugLog("hook %s %s: failed: %v", h.name, phase, err)
+		return err
+	}
+	debugLog("hook %s %s: ok (%s)", h.name, phase, time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHookRunnerEnvAndOrder(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	h := newHookRunner("Postgres", &Hooks{PreStart: []string{
+		`echo "$KUBEFWD_SERVICE $KUBEFWD_HOST:$KUBEFWD_LOCAL_PORT $KUBEFWD_LOCAL_PORTS $KUBEFWD_CONTEXT/$KUBEFWD_NAMESPACE $KUBEFWD_HOOK" > ` + out,
+		"exit 3",
+		"touch " + filepath.Join(dir, "never"),
+	}}, hookEnv("Postgres", "", []int{5432, 5433}, "dev", "db"))
+
+	err := h.run(HookPreStart)
+	if err == nil || !strings.Contains(err.Error(), `pre_start hook "exit 3"`) {
+		t.Fatalf("expected the failing command in the error, got %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(b)); got != "Postgres localhost:5432 5432,5433 dev/db pre_start" {
+		t.Fatalf("env: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "never")); err == nil {
+		t.Fatal("commands after a failure must not run")
+	}
+	if err := h.run(HookPostStop); err != nil {
+		t.Fatalf("phase without commands: %v", err)
+	}
+}
+
+func TestHookRunnerTimeoutAndLog(t *testing.T) {
+	h := newHookRunner("Redis", &Hooks{PostStart: []string{"echo warming cache; sleep 5"}, Timeout: 1}, nil)
+	start := time.Now()
+	err := h.run(HookPostStart)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 1s") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if time.Since(start) > 4*time.Second {
+		t.Fatalf("hook ran for %s despite the timeout", time.Since(start))
+	}
+	found := false
+	for _, line := range getDebugLines() {
+		if strings.Contains(line, "hook Redis post_start: warming cache") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("hook output not streamed into the debug log")
+	}
+}
+
+func TestHooksValidation(t *testing.T) {
+	cfg := &Config{ClusterContext: "ctx1", Namespace: "default", Services: []Service{
+		{Name: "A", ServiceName: "a", RemotePort: 80, LocalPort: 8080, Hooks: &Hooks{PostStart: []string{"true"}}},
+	}}
+	ApplyConfigDefaults(cfg)
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Services[0].Hooks.PreStop = []string{" "}
+	if err := ValidateConfig(cfg); err == nil || !strings.Contains(err.Error(), "hooks.pre_stop[0]") {
+		t.Fatalf("expected empty command error, got %v", err)
+	}
+	cfg.Services[0].Hooks.PreStop = nil
+	cfg.Services[0].Hooks.Timeout = -1
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatal("expected error for negative timeout")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Markers delimiting the block kubefwd owns in the hosts file. Everything between
+// them is rewritten on every change; the rest of the file is left untouched.
+const (
+	hostsBlockBegin = "# BEGIN kubefwd (managed automatically, changes will be overwritten)"
+	hostsBlockEnd   = "# END kubefwd"
+)
+
+// clusterDomain is the DNS suffix of in-cluster service names
+const clusterDomain = "cluster.local"
+
+// hostsEntry maps the cluster DNS names of one service to a local address
+type hostsEntry struct {
+	Address string
+	Names   []string
+}
+
+// HostsManager keeps the kubefwd block of a hosts file in sync with the running forwards
+type HostsManager struct {
+	path    string
+	mu      sync.Mutex
+	current string // rendered block last written ("" = no block)
+	lastErr string
+	closed  bool
+}
+
+// NewHostsManager creates a manager for the hosts file at path
+func NewHostsManager(path string) *HostsManager {
+	return &HostsManager{path: path}
+}
+
+// serviceHostNames returns the names a service is reachable under inside the cluster:
+// short name, name.namespace, name.namespace.svc and the FQDN
+func serviceHostNames(serviceName, namespace string) []string {
+	return []string{
+		serviceName,
+		serviceName + "." + namespace,
+		serviceName + "." + namespace + ".svc",
+		serviceName + "." + namespace + ".svc." + clusterDomain,
+	}
+}
+
+// hostsAddress returns the IP a hosts entry should point at for a bind address
+func hostsAddress(bindAddress string) string {
+	if bindAddress == "" || bindAddress == "localhost" || isWildcardAddress(bindAddress) {
+		return "127.0.0.1"
+	}
+	return bindAddress
+}
+
+// Sync rewrites the kubefwd block so it contains exactly entries. The file is only
+// touched when the block changes.
+func (hm *HostsManager) Sync(entries []hostsEntry) error {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	block := renderHostsBlock(entries)
+	if hm.closed || block == hm.current {
+		return nil
+	}
+	if err := hm.write(block); err != nil {
+		if msg := err.Error(); msg != hm.lastErr {
+			hm.lastErr = msg
+			fmt.Fprintf(os.Stderr, "Warning: updating %s: %v\n", hm.path, err)
+		}
+		return err
+	}
+	hm.current = block
+	hm.lastErr = ""
+	return nil
+}
+
+// Clear removes the kubefwd block, including one left behind by a previous run
+func (hm *HostsManager) Clear() error {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	if err := hm.write(""); err != nil {
+		return err
+	}
+	hm.current = ""
+	return nil
+}
+
+// Close removes the kubefwd block for good; later Syncs are ignored
+func (hm *HostsManager) Close() error {
+	hm.mu.Lock()
+	hm.closed = true
+	hm.mu.Unlock()
+	return hm.Clear()
+}
+
+// write replaces the kubefwd block of the hosts file with block. The file is
+// rewritten in place (not renamed) so bind-mounted hosts files keep working.
+func (hm *HostsManager) write(block string) error {
+	data, err := os.ReadFile(hm.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content := stripHostsBlock(string(data))
+	if block == "" && content == string(data) {
+		return nil
+	}
+	if block != "" {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += block
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(hm.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	debugLog("updating hosts file %s", hm.path)
+	return os.WriteFile(hm.path, []byte(content), mode)
+}
+
+// renderHostsBlock formats entries as a delimited hosts block ("" when empty).
+// A name claimed by an earlier entry is not repeated.
+func renderHostsBlock(entries []hostsEntry) string {
+	seen := make(map[string]bool)
+	var lines []string
+	for _, e := range entries {
+		var names []string
+		for _, n := range e.Names {
+			if !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+		if len(names) > 0 {
+			lines = append(lines, e.Address+"\t"+strings.Join(names, " "))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	sort.Strings(lines)
+	return hostsBlockBegin + "\n" + strings.Join(lines, "\n") + "\n" + hostsBlockEnd + "\n"
+}
+
+// stripHostsBlock returns content without the kubefwd block
+func stripHostsBlock(content string) string {
+	start := strings.Index(content, hostsBlockBegin)
+	if start < 0 {
+		return content
+	}
+	end := strings.Index(content[start:], hostsBlockEnd)
+	if end < 0 {
+		// Unterminated block: drop everything after the begin marker
+		return content[:start]
+	}
+	end += start + len(hostsBlockEnd)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return content[:start] + content[end:]
+}
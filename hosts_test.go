@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHostsManagerSync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	original := "127.0.0.1\tlocalhost\n::1\tlocalhost\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hm := NewHostsManager(path)
+	err := hm.Sync([]hostsEntry{
+		{Address: hostsAddress("127.0.0.2"), Names: serviceHostNames("api", "default")},
+		{Address: hostsAddress(""), Names: serviceHostNames("api", "staging")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	got := string(data)
+	if !strings.HasPrefix(got, original+hostsBlockBegin+"\n") || !strings.HasSuffix(got, hostsBlockEnd+"\n") {
+		t.Fatalf("block not appended after existing content:\n%s", got)
+	}
+	if !strings.Contains(got, "127.0.0.2\tapi api.default api.default.svc api.default.svc.cluster.local\n") {
+		t.Fatalf("missing default entry:\n%s", got)
+	}
+	// The short name is claimed by the first entry only
+	if !strings.Contains(got, "127.0.0.1\tapi.staging api.staging.svc api.staging.svc.cluster.local\n") {
+		t.Fatalf("missing staging entry:\n%s", got)
+	}
+
+	if err := hm.Sync(nil); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	if string(data) != original {
+		t.Fatalf("block not removed:\n%s", data)
+	}
+
+	if err := hm.Sync([]hostsEntry{{Address: "127.0.0.1", Names: []string{"db"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := hm.Sync([]hostsEntry{{Address: "127.0.0.1", Names: []string{"db"}}}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	if string(data) != original {
+		t.Fatalf("closed manager changed the file:\n%s", data)
+	}
+}
+
+func TestStripHostsBlockLeftover(t *testing.T) {
+	content := "10.0.0.1\tfoo\n" + hostsBlockBegin + "\n127.0.0.1\tapi\n" + hostsBlockEnd + "\n10.0.0.2\tbar\n"
+	if got := stripHostsBlock(content); got != "10.0.0.1\tfoo\n10.0.0.2\tbar\n" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// processStart is when this kubefwd started
+var processStart = time.Now()
+
+// errAlreadyRunning is returned by lockInstance when another kubefwd holds the lock
+var errAlreadyRunning = errors.New("kubefwd is already running")
+
+// instanceInfo describes a running kubefwd (GET /api/instance)
+type instanceInfo struct {
+	PID          int       `json:"pid"`
+	ConfigSource string    `json:"config_source"`
+	WebPort      int       `json:"web_port"`
+	StartedAt    time.Time `json:"started_at"`
+}
+
+// instance is the lock a kubefwd holds on its config source, and its control socket.
+// The lock is an flock, so the kernel releases it when kubefwd dies in any way.
+type instance struct {
+	lockPath   string
+	socketPath string
+	lockFile   *os.File
+	listener   net.Listener
+}
+
+// instanceDir returns the directory of the lock files and control sockets:
+// $XDG_RUNTIME_DIR/kubefwd, or kubefwd-<uid> in the temporary directory
+func instanceDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "kubefwd")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("kubefwd-%d", os.Getuid()))
+}
+
+// instancePaths returns the lock file and control socket of a config source (see
+// ConfigStore.Description). Names are hashed, as sockets have a short path limit.
+func instancePaths(source string) (lockPath, socketPath string) {
+	sum := sha256.Sum256([]byte(source))
+	base := filepath.Join(instanceDir(), hex.EncodeToString(sum[:8]))
+	return base + ".lock", base + ".sock"
+}
+
+// lockInstance takes the lock of a config source. If another kubefwd holds it, the
+// returned instance has only its paths set and the error wraps errAlreadyRunning.
+func lockInstance(source string) (*instance, error) {
+	lockPath, socketPath := instancePaths(source)
+	in := &instance{lockPath: lockPath, socketPath: socketPath}
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		data, _ := io.ReadAll(f)
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			if pid := strings.TrimSpace(string(data)); pid != "" {
+				return in, fmt.Errorf("%w for %s (PID %s)", errAlreadyRunning, source, pid)
+			}
+			return in, fmt.Errorf("%w for %s", errAlreadyRunning, source)
+		}
+		return nil, fmt.Errorf("cannot lock %s: %w", lockPath, err)
+	}
+	_ = f.Truncate(0)
+	_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	in.lockFile = f
+	return in, nil
+}
+
+// Serve serves handler on the control socket in the background. The socket is only
+// accessible to the current user.
+func (in *instance) Serve(handler http.Handler) error {
+	_ = os.Remove(in.socketPath) // Left behind by a kubefwd that was killed; we hold the lock
+	ln, err := net.Listen("unix", in.socketPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(in.socketPath, 0o600); err != nil {
+		ln.Close()
+		return err
+	}
+	in.listener = ln
+	go func() {
+		if err := http.Serve(ln, handler); err != nil && !errors.Is(err, net.ErrClosed) {
+			debugLog("control socket: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Close removes the control socket and releases the lock
+func (in *instance) Close() {
+	if in.listener != nil {
+		in.listener.Close()
+		_ = os.Remove(in.socketPath)
+	}
+	if in.lockFile != nil {
+		in.lockFile.Close()
+	}
+}
+
+// controlClient talks to the running kubefwd over its control socket
+type controlClient struct {
+	http *http.Client
+}
+
+func newControlClient(socketPath string) *controlClient {
+	return &controlClient{http: &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}}
+}
+
+// call sends a request to the API of the running kubefwd and decodes the answer
+// into out (unless nil). Error answers are returned with the message of the API.
+func (c *controlClient) call(method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, "http://kubefwd"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot reach the running kubefwd: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return errors.New(apiErr.Error)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// startupActions are the command-line flags a second invocation hands over to the
+// running kubefwd instead of starting another one
+type startupActions struct {
+	Default      bool
+	DefaultProxy bool
+	Restore      bool
+	Preset       string
+}
+
+func (a startupActions) any() bool {
+	return a.Default || a.DefaultProxy || a.Restore || a.Preset != ""
+}
+
+// handOff performs actions on the running kubefwd behind socketPath and returns the
+// exit code: 0 when every action was accepted, 1 otherwise or without any action.
+func handOff(socketPath string, actions startupActions) int {
+	c := newControlClient(socketPath)
+	var info instanceInfo
+	if err := c.call("GET", "/api/instance", nil, &info); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("kubefwd is already running for %s (PID %d) at http://localhost:%d\n", info.ConfigSource, info.PID, info.WebPort)
+	if !actions.any() {
+		return 1
+	}
+
+	steps := []struct {
+		want bool
+		path string
+		done string
+	}{
+		{actions.Restore, "/api/session/restore", "restoring the saved session"},
+		{actions.Default, "/api/services/start-defaults", "starting default services"},
+		{actions.DefaultProxy, "/api/proxy-services/start-defaults", "starting default proxy services"},
+		{actions.Preset != "", "/api/presets/" + url.PathEscape(actions.Preset) + "/apply", fmt.Sprintf("applying preset %s", actions.Preset)},
+	}
+	code := 0
+	for _, step := range steps {
+		if !step.want {
+			continue
+		}
+		if err := c.call("POST", step.path, nil, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Error %s: %v\n", step.done, err)
+			code = 1
+			continue
+		}
+		fmt.Printf("Handed over: %s\n", step.done)
+	}
+	return code
+}
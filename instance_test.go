@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestInstanceLock(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	first, err := lockInstance("/home/me/.kubefwd.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := lockInstance("/home/me/.kubefwd.yaml")
+	if !errors.Is(err, errAlreadyRunning) || !strings.Contains(err.Error(), "PID") {
+		t.Fatalf("second lock: %v", err)
+	}
+	if second.socketPath != first.socketPath {
+		t.Fatalf("socket paths differ: %s %s", second.socketPath, first.socketPath)
+	}
+	other, err := lockInstance("sqlite:/home/me/kubefwd.db")
+	if err != nil {
+		t.Fatalf("another config source is locked separately: %v", err)
+	}
+	other.Close()
+
+	first.Close()
+	again, err := lockInstance("/home/me/.kubefwd.yaml")
+	if err != nil {
+		t.Fatalf("lock not released: %v", err)
+	}
+	again.Close()
+}
+
+func TestHandOffOverControlSocket(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	in, err := lockInstance("/home/me/.kubefwd.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		jsonOK(w, map[string]string{"status": "ok"})
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/instance", func(w http.ResponseWriter, r *http.Request) {
+		jsonOK(w, instanceInfo{PID: 42, ConfigSource: "/home/me/.kubefwd.yaml", WebPort: 8765})
+	})
+	mux.HandleFunc("POST /api/services/start-defaults", record)
+	mux.HandleFunc("POST /api/presets/{name}/apply", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("name") != "Backend Dev" {
+			jsonError(w, "preset not found", http.StatusNotFound)
+			return
+		}
+		record(w, r)
+	})
+	if err := in.Serve(mux); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := handOff(in.socketPath, startupActions{}); code != 1 {
+		t.Fatalf("without actions: exit code %d", code)
+	}
+	if code := handOff(in.socketPath, startupActions{Default: true, Preset: "Backend Dev"}); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	if got := strings.Join(calls, ", "); got != "POST /api/services/start-defaults, POST /api/presets/Backend Dev/apply" {
+		t.Fatalf("calls: %s", got)
+	}
+	if code := handOff(in.socketPath, startupActions{Preset: "Missing"}); code != 1 {
+		t.Fatalf("unknown preset: exit code %d", code)
+	}
+
+	err = newControlClient(in.socketPath).call("POST", "/api/presets/Missing/apply", nil, nil)
+	if err == nil || err.Error() != "preset not found" {
+		t.Fatalf("API error: %v", err)
+	}
+}
@@ -26,21 +26,30 @@ func getDefaultConfigPath() string {
 }
 
 func main() {
+	// Subcommands drive the kubefwd that is already running (see cli.go)
+	if len(os.Args) > 1 {
+		if run, ok := cliCommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
 	configFile := flag.String("config", getDefaultConfigPath(), "Path to YAML configuration file (ignored when -db is set)")
 	dbPath := flag.String("db", "", "SQLite database path for configuration (if set, YAML file is not used)")
 	importYAML := flag.String("import-yaml", "", "Import a YAML file into the SQLite database (only with -db), then start")
 	debug := flag.Bool("debug", false, "Enable debug output")
 	defaultFlag := flag.Bool("default", false, "Auto-start services marked with selected_by_default")
 	defaultProxyFlag := flag.Bool("default-proxy", false, "Auto-start proxy services marked with selected_by_default")
+	restoreFlag := flag.Bool("restore", false, "Restore the forwards and context that were running when kubefwd last stopped")
+	presetFlag := flag.String("preset", "", "Apply the named preset on launch")
 	flag.Parse()
 
 	debugMode = *debug
+	actions := startupActions{Default: *defaultFlag, DefaultProxy: *defaultProxyFlag, Restore: *restoreFlag, Preset: *presetFlag}
 
-	// Check if kubectl is available
-	if err := CheckKubectlAvailable(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Please ensure kubectl is installed and available in your PATH\n")
-		os.Exit(1)
+	// The config source names the instance lock, so relative paths are made absolute
+	*configFile = absPath(*configFile)
+	if *dbPath != "" {
+		*dbPath = absPath(*dbPath)
 	}
 
 	var store ConfigStore
@@ -55,7 +64,24 @@ func main() {
 		}
 		defer func() { _ = sqliteDB.Close() }()
 		store = sqliteDB
+	} else {
+		store = &FileConfigStore{Path: *configFile}
+	}
 
+	// One kubefwd per config source: a second one hands its flags to the running one
+	inst, err := lockInstance(store.Description())
+	if errors.Is(err, errAlreadyRunning) {
+		if *importYAML != "" {
+			fmt.Fprintf(os.Stderr, "Error: %v; stop it before importing\n", err)
+			os.Exit(1)
+		}
+		os.Exit(handOff(inst.socketPath, actions))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot lock %s: %v\n", store.Description(), err)
+	}
+
+	if sqliteDB != nil {
 		if *importYAML != "" {
 			data, err := os.ReadFile(*importYAML)
 			if err != nil {
@@ -72,8 +98,6 @@ func main() {
 				os.Exit(1)
 			}
 		}
-	} else {
-		store = &FileConfigStore{Path: *configFile}
 	}
 
 	config, err := store.Load()
@@ -86,8 +110,36 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The saved session is restored with --restore or when the UI toggle is on
+	var saved *session
+	if sessions, ok := store.(sessionStore); ok {
+		saved, err = sessions.LoadSession()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot load the saved session: %v\n", err)
+		}
+	}
+	restore := saved != nil && (*restoreFlag || saved.RestoreOnStart)
+	if *restoreFlag && saved == nil {
+		fmt.Fprintf(os.Stderr, "No saved session to restore\n")
+	}
+	if restore {
+		applySessionContext(config, saved)
+	}
+
+	// Check if kubectl is available. The native engine forwards without it, but proxy
+	// pods and the Explore tab still shell out to kubectl.
+	if err := CheckKubectlAvailable(); err != nil {
+		if config.ForwardEngine != ForwardEngineNative {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Please ensure kubectl is installed and available in your PATH\n")
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Proxy pods and the Explore tab will not work without kubectl\n")
+	}
+
 	// Validate cluster context
-	if err := ValidateContext(config.ClusterContext); err != nil {
+	if err := ValidateContextForEngine(config.ForwardEngine, config.ClusterContext); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		fmt.Fprintf(os.Stderr, "Please ensure the context exists and kubectl is configured correctly\n")
 		os.Exit(1)
@@ -118,6 +170,24 @@ func main() {
 
 	// Create the web application state
 	app := NewWebApp(config, store)
+	app.useSession(saved)
+
+	// The control socket serves the same API, for later invocations
+	if inst != nil {
+		if err := inst.Serve(app.routes()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot serve the control socket %s: %v\n", inst.socketPath, err)
+		}
+		defer inst.Close()
+	}
+
+	// Leftovers of a kubefwd that was killed are dealt with before anything starts
+	app.RecoverOrphans()
+	if restore {
+		go app.RestoreSession(*saved)
+	}
+	if *presetFlag != "" && !app.ApplyPreset(*presetFlag) {
+		fmt.Fprintf(os.Stderr, "Warning: preset %q not found\n", *presetFlag)
+	}
 
 	// Auto-start default services if requested
 	if *defaultFlag {
@@ -131,6 +201,17 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go app.startSSEBroadcaster(ctx)
+	go app.saveSessionLoop(ctx)
+
+	// Optional DNS server answering cluster names of running forwards
+	if config.DNSAddress != "" {
+		dns := NewDNSServer(config.DNSAddress, config.DNSUpstream, app.forwardedServices)
+		if err := dns.Start(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting DNS server on %s: %v\n", config.DNSAddress, err)
+			os.Exit(1)
+		}
+		fmt.Printf("DNS server listening on %s (udp)\n", dns.Addr())
+	}
 
 	// Print the URL and start the HTTP server
 	url := fmt.Sprintf("http://localhost:%d", config.WebPort)
@@ -143,7 +224,10 @@ func main() {
 		<-sigChan
 		fmt.Fprintf(os.Stderr, "\nShutting down…\n")
 		cancel()
-		app.StopAll()
+		app.Shutdown()
+		if inst != nil {
+			inst.Close()
+		}
 		os.Exit(0)
 	}()
 
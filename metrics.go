@@ -0,0 +1,137 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	metricsBucket  = 10 * time.Second // Width of one history sample
+	metricsHistory = 30               // Samples kept for sparklines (5 minutes)
+)
+
+// metricsSample is the traffic of one history bucket
+type metricsSample struct {
+	Bytes int64 `json:"bytes"` // Bytes relayed in either direction
+	Conns int   `json:"conns"` // Highest number of concurrent connections
+}
+
+// metricsSnapshot is the JSON form of connMetrics in the SSE state
+type metricsSnapshot struct {
+	ActiveConns int             `json:"active_conns"`
+	TotalConns  int64           `json:"total_conns"`
+	ConnErrors  int64           `json:"conn_errors"`
+	BytesIn     int64           `json:"bytes_in"`  // Received from the cluster
+	BytesOut    int64           `json:"bytes_out"` // Sent to the cluster
+	LastActive  *time.Time      `json:"last_active,omitempty"`
+	History     []metricsSample `json:"history"` // Oldest first, one sample per 10s
+}
+
+// connMetrics counts the connections relayed for one service or proxy service. It
+// belongs to the forward, so the totals survive restarts.
+type connMetrics struct {
+	mu         sync.Mutex
+	active     int
+	total      int64
+	errors     int64
+	bytesIn    int64
+	bytesOut   int64
+	lastActive time.Time
+	bucket     time.Time // Start of the current history bucket
+	current    metricsSample
+	history    []metricsSample // Completed buckets, oldest first
+}
+
+func newConnMetrics() *connMetrics {
+	return &connMetrics{}
+}
+
+// rollLocked closes finished history buckets up to now, adding empty ones for gaps
+func (m *connMetrics) rollLocked(now time.Time) {
+	start := now.Truncate(metricsBucket)
+	if m.bucket.IsZero() {
+		m.bucket = start
+		return
+	}
+	for m.bucket.Before(start) {
+		m.history = append(m.history, m.current)
+		if len(m.history) >= metricsHistory {
+			// Keep room for the current bucket
+			m.history = m.history[len(m.history)-metricsHistory+1:]
+		}
+		m.current = metricsSample{Conns: m.active}
+		m.bucket = m.bucket.Add(metricsBucket)
+		if start.Sub(m.bucket) > metricsHistory*metricsBucket {
+			// Long idle: the skipped buckets would all be dropped anyway
+			m.bucket = start.Add(-metricsHistory * metricsBucket)
+		}
+	}
+}
+
+func (m *connMetrics) connOpened() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.rollLocked(now)
+	m.active++
+	m.total++
+	m.lastActive = now
+	if m.active > m.current.Conns {
+		m.current.Conns = m.active
+	}
+}
+
+func (m *connMetrics) connClosed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.rollLocked(now)
+	m.active--
+	m.lastActive = now
+}
+
+// connFailed counts a client that could not be connected to the forward
+func (m *connMetrics) connFailed() {
+	m.mu.Lock()
+	m.errors++
+	m.mu.Unlock()
+}
+
+// addBytes records relayed traffic; in is received from the cluster, out is sent to it
+func (m *connMetrics) addBytes(in, out int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.rollLocked(now)
+	m.bytesIn += in
+	m.bytesOut += out
+	m.current.Bytes += in + out
+	m.lastActive = now
+}
+
+// Activity returns the number of open connections and when traffic was last seen
+func (m *connMetrics) Activity() (active int, lastActive time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.active, m.lastActive
+}
+
+// Snapshot returns the counters and the rolling history including the current bucket
+func (m *connMetrics) Snapshot() metricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollLocked(time.Now())
+	s := metricsSnapshot{
+		ActiveConns: m.active,
+		TotalConns:  m.total,
+		ConnErrors:  m.errors,
+		BytesIn:     m.bytesIn,
+		BytesOut:    m.bytesOut,
+		History:     append(append(make([]metricsSample, 0, len(m.history)+1), m.history...), m.current),
+	}
+	if !m.lastActive.IsZero() {
+		last := m.lastActive
+		s.LastActive = &last
+	}
+	return s
+}
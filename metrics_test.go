@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnMetricsHistory(t *testing.T) {
+	m := newConnMetrics()
+	t0 := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	m.rollLocked(t0)
+	m.current.Bytes = 100
+
+	// Two buckets later: the first is closed, one empty bucket fills the gap
+	m.rollLocked(t0.Add(2*metricsBucket + time.Second))
+	if len(m.history) != 2 || m.history[0].Bytes != 100 || m.history[1].Bytes != 0 || m.current.Bytes != 0 {
+		t.Fatalf("history after gap: %+v current %+v", m.history, m.current)
+	}
+
+	// Open connections carry over into new buckets
+	m.active = 2
+	m.rollLocked(t0.Add(3 * metricsBucket))
+	if m.current.Conns != 2 {
+		t.Fatalf("carried conns: %+v", m.current)
+	}
+
+	// A long idle period keeps the history bounded
+	m.rollLocked(t0.Add(24 * time.Hour))
+	if len(m.history) != metricsHistory-1 {
+		t.Fatalf("history length %d", len(m.history))
+	}
+	if got := len(m.Snapshot().History); got != metricsHistory {
+		t.Fatalf("snapshot history length %d", got)
+	}
+}
+
+func TestConnMetricsCounters(t *testing.T) {
+	m := newConnMetrics()
+	m.connOpened()
+	m.connOpened()
+	m.addBytes(10, 3)
+	m.connClosed()
+	m.connFailed()
+	s := m.Snapshot()
+	if s.ActiveConns != 1 || s.TotalConns != 2 || s.BytesIn != 10 || s.BytesOut != 3 || s.ConnErrors != 1 || s.LastActive == nil {
+		t.Fatalf("snapshot: %+v", s)
+	}
+	if cur := s.History[len(s.History)-1]; cur.Bytes != 13 || cur.Conns != 2 {
+		t.Fatalf("current sample: %+v", cur)
+	}
+}
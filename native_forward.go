@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+func init() {
+	// client-go reports per-connection stream failures through HandleError instead of
+	// returning them. Route them into the debug log rather than klog on stderr.
+	utilruntime.ErrorHandlers = []utilruntime.ErrorHandler{
+		func(_ context.Context, err error, msg string, _ ...interface{}) {
+			if err != nil {
+				debugLog("native stream error: %v", err)
+			} else {
+				debugLog("native stream error: %s", msg)
+			}
+		},
+	}
+}
+
+// restConfigForContext builds a client config for the given kubeconfig context,
+// honouring $KUBECONFIG the same way kubectl does.
+func restConfigForContext(kubeCtx string) (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeCtx}
+	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("load kubeconfig for context '%s': %w", kubeCtx, err)
+	}
+	return cfg, nil
+}
+
+// clientsetForContext builds a typed client for the given kubeconfig context
+func clientsetForContext(kubeCtx string) (kubernetes.Interface, *rest.Config, error) {
+	restCfg, err := restConfigForContext(kubeCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create kubernetes client: %w", err)
+	}
+	return clientset, restCfg, nil
+}
+
+// ValidateKubeconfigContext checks that the context exists in the kubeconfig without
+// calling kubectl. Used when every forward runs on the native engine.
+func ValidateKubeconfigContext(kubeCtx string) error {
+	raw, err := clientcmd.NewDefaultClientConfigLoadingRules().Load()
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	if _, ok := raw.Contexts[kubeCtx]; !ok {
+		return fmt.Errorf("context '%s' not found", kubeCtx)
+	}
+	return nil
+}
+
+// nativeForwarder is an in-process port-forward using client-go's SPDY/WebSocket dialer
+type nativeForwarder struct {
+	done chan struct{}
+	err  error
+}
+
+// startNativeForwarder returns immediately; resolving the target and dialing the API
+// server happen in the background so failures surface through Wait, just like a
+// kubectl process exiting.
+func startNativeForwarder(ctx context.Context, spec forwardSpec, stdout, stderr io.Writer) (forwarder, error) {
+	nf := &nativeForwarder{done: make(chan struct{})}
+	go func() {
+		nf.err = runNativeForward(ctx, spec, stdout)
+		if nf.err != nil && stderr != nil {
+			fmt.Fprintf(stderr, "error: %v\n", nf.err)
+		}
+		close(nf.done)
+	}()
+	return nf, nil
+}
+
+// runNativeForward forwards spec until ctx is cancelled or the connection is lost
+func runNativeForward(ctx context.Context, spec forwardSpec, stdout io.Writer) error {
+	clientset, restCfg, err := clientsetForContext(spec.Context)
+	if err != nil {
+		return err
+	}
+
+	podName, ports, err := resolveNativeTarget(ctx, clientset, spec)
+	if err != nil {
+		return err
+	}
+
+	req := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(spec.Namespace).
+		Name(podName).
+		SubResource("portforward")
+	dialer, err := newPortForwardDialer(restCfg, req.URL())
+	if err != nil {
+		return err
+	}
+
+	addresses := []string{"localhost"}
+	if spec.Address != "" {
+		addresses = []string{spec.Address}
+	}
+	stopCh := make(chan struct{})
+	fw, err := portforward.NewOnAddresses(dialer, addresses, ports, stopCh, nil, stdout, io.Discard)
+	if err != nil {
+		return err
+	}
+	debugLog("native port-forward to pod/%s ports=%s", podName, strings.Join(ports, ","))
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			close(stopCh)
+		case <-done:
+		}
+	}()
+	return fw.ForwardPorts()
+}
+
+func (nf *nativeForwarder) Wait() error {
+	<-nf.done
+	return nf.err
+}
+
+// PID returns our own PID: the upstream listener lives inside the kubefwd process.
+func (nf *nativeForwarder) PID() int {
+	return os.Getpid()
+}
+
+// newPortForwardDialer prefers the WebSocket tunnel and falls back to SPDY for API
+// servers that do not support it, mirroring kubectl's behaviour.
+func newPortForwardDialer(restCfg *rest.Config, target *url.URL) (httpstream.Dialer, error) {
+	transport, upgrader, err := spdy.RoundTripperFor(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("create spdy transport: %w", err)
+	}
+	spdyDialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, target)
+	wsDialer, err := portforward.NewSPDYOverWebsocketDialer(target, restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("create websocket dialer: %w", err)
+	}
+	return portforward.NewFallbackDialer(wsDialer, spdyDialer, func(err error) bool {
+		return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+	}), nil
+}
+
+// resolveNativeTarget turns a kubectl-style resource into a pod name and translates
+// the remote side of each port spec to a container port, like kubectl port-forward does.
+func resolveNativeTarget(ctx context.Context, clientset kubernetes.Interface, spec forwardSpec) (string, []string, error) {
+	kind, name, ok := strings.Cut(spec.Resource, "/")
+	if !ok {
+		return "", nil, fmt.Errorf("invalid forward target %q", spec.Resource)
+	}
+
+	switch kind {
+	case "pod":
+		return name, spec.Ports, nil
+	case "deployment", "statefulset":
+		var selector *metav1.LabelSelector
+		if kind == "deployment" {
+			d, err := clientset.AppsV1().Deployments(spec.Namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return "", nil, err
+			}
+			selector = d.Spec.Selector
+		} else {
+			ss, err := clientset.AppsV1().StatefulSets(spec.Namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return "", nil, err
+			}
+			selector = ss.Spec.Selector
+		}
+		sel, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s %s: %w", kind, name, err)
+		}
+		pod, err := firstReadyPod(ctx, clientset, spec.Namespace, sel.String())
+		if err != nil {
+			return "", nil, fmt.Errorf("%s %s: %w", kind, name, err)
+		}
+		// Like kubectl, remote ports of workloads are container ports
+		return pod.Name, spec.Ports, nil
+	case "service":
+		svc, err := clientset.CoreV1().Services(spec.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", nil, err
+		}
+		if len(svc.Spec.Selector) == 0 {
+			return "", nil, fmt.Errorf("service %s has no selector", name)
+		}
+		pod, err := firstReadyPod(ctx, clientset, spec.Namespace, labels.SelectorFromSet(svc.Spec.Selector).String())
+		if err != nil {
+			return "", nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		ports, err := translateServicePorts(svc, pod, spec.Ports)
+		if err != nil {
+			return "", nil, err
+		}
+		return pod.Name, ports, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported forward target kind %q", kind)
+	}
+}
+
+// firstReadyPod returns a running pod with the Ready condition matching selector
+func firstReadyPod(ctx context.Context, clientset kubernetes.Interface, namespace, selector string) (*corev1.Pod, error) {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		if isPodReady(&pods.Items[i]) {
+			return &pods.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no running pod found for selector %s", selector)
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// translateServicePorts maps "local:servicePort" specs to "local:containerPort"
+func translateServicePorts(svc *corev1.Service, pod *corev1.Pod, specs []string) ([]string, error) {
+	out := make([]string, 0, len(specs))
+	for _, ps := range specs {
+		local, remote, ok := strings.Cut(ps, ":")
+		if !ok {
+			local, remote = ps, ps
+		}
+		svcPort, err := strconv.Atoi(remote)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", ps)
+		}
+		containerPort, err := containerPortForServicePort(svc, pod, int32(svcPort))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, fmt.Sprintf("%s:%d", local, containerPort))
+	}
+	return out, nil
+}
+
+func containerPortForServicePort(svc *corev1.Service, pod *corev1.Pod, port int32) (int32, error) {
+	for _, sp := range svc.Spec.Ports {
+		if sp.Port != port {
+			continue
+		}
+		if sp.TargetPort.StrVal != "" {
+			for _, c := range pod.Spec.Containers {
+				for _, cp := range c.Ports {
+					if cp.Name == sp.TargetPort.StrVal {
+						return cp.ContainerPort, nil
+					}
+				}
+			}
+			return 0, fmt.Errorf("pod %s has no container port named %q", pod.Name, sp.TargetPort.StrVal)
+		}
+		if sp.TargetPort.IntVal == 0 {
+			return port, nil
+		}
+		return sp.TargetPort.IntVal, nil
+	}
+	return 0, fmt.Errorf("service %s does not have a service port %d", svc.Name, port)
+}
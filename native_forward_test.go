@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTranslateServicePorts(t *testing.T) {
+	svc := &corev1.Service{Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+		{Port: 80, TargetPort: intstr.FromInt32(8080)},
+		{Port: 9090, TargetPort: intstr.FromString("metrics")},
+		{Port: 5432},
+	}}}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Ports: []corev1.ContainerPort{{Name: "metrics", ContainerPort: 9100}},
+	}}}}
+
+	got, err := translateServicePorts(svc, pod, []string{"8000:80", "9090:9090", "5432:5432"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"8000:8080", "9090:9100", "5432:5432"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if _, err := translateServicePorts(svc, pod, []string{"1:1234"}); err == nil {
+		t.Fatal("expected error for unknown service port")
+	}
+}
+
+func TestForwardSpecCommandString(t *testing.T) {
+	spec := forwardSpec{Context: "ctx", Namespace: "ns", Resource: "service/api", Ports: []string{"8080:80"}}
+	if got := spec.commandString(ForwardEngineKubectl); got != "kubectl --context=ctx -n ns port-forward service/api 8080:80" {
+		t.Fatalf("kubectl: %q", got)
+	}
+	if got := spec.commandString(ForwardEngineNative); got != "native --context=ctx -n ns port-forward service/api 8080:80" {
+		t.Fatalf("native: %q", got)
+	}
+	spec.Address = "127.0.0.2"
+	if got := spec.commandString(ForwardEngineKubectl); got != "kubectl --context=ctx -n ns port-forward --address=127.0.0.2 service/api 8080:80" {
+		t.Fatalf("kubectl with address: %q", got)
+	}
+}
+
+func TestResolveNativeTargetWorkload(t *testing.T) {
+	labels := map[string]string{"app": "pg"}
+	ready := corev1.PodStatus{
+		Phase:      corev1.PodRunning,
+		Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+	}
+	clientset := fake.NewSimpleClientset(
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "postgres", Namespace: "db"},
+			Spec:       appsv1.StatefulSetSpec{Selector: &metav1.LabelSelector{MatchLabels: labels}},
+		},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "postgres-0", Namespace: "db", Labels: labels}, Status: ready},
+	)
+
+	spec := forwardSpec{Namespace: "db", Resource: "statefulset/postgres", Ports: []string{"5432:5432"}}
+	pod, ports, err := resolveNativeTarget(context.Background(), clientset, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pod != "postgres-0" || !reflect.DeepEqual(ports, spec.Ports) {
+		t.Fatalf("got %s %v", pod, ports)
+	}
+
+	spec.Resource = "deployment/missing"
+	if _, _, err := resolveNativeTarget(context.Background(), clientset, spec); err == nil {
+		t.Fatal("expected error for missing deployment")
+	}
+}
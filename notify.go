@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// notifierTimeout bounds how long a notification command or webhook request may take
+const notifierTimeout = 10 * time.Second
+
+// eventTest is sent by the test endpoint; it skips filters and the rate limit
+const eventTest = "test"
+
+// notification is what a notifier is told. Webhooks receive it as JSON.
+type notification struct {
+	Event      string    `json:"event"`
+	Kind       string    `json:"kind"` // service, proxy_service, proxy_pod or sql_tap
+	Name       string    `json:"name"` // Service name, or context/namespace of a proxy pod
+	Message    string    `json:"message"`
+	Time       time.Time `json:"time"`
+	Suppressed int       `json:"suppressed,omitempty"` // Notifications for the same service and event held back by the rate limit since the last one
+}
+
+// notifierManager sends failure and recovery notifications to the configured notifiers
+type notifierManager struct {
+	mu         sync.Mutex
+	notifiers  []Notifier
+	failed     map[string]bool      // kind/name of everything with a failure event and no recovery yet
+	last       map[string]time.Time // When a notifier last sent an event for an entry
+	suppressed map[string]int       // Notifications held back since then
+	send       func(Notifier, notification) error
+}
+
+// notifications sends the notifications of this kubefwd process
+var notifications = newNotifierManager()
+
+func newNotifierManager() *notifierManager {
+	return &notifierManager{
+		failed:     make(map[string]bool),
+		last:       make(map[string]time.Time),
+		suppressed: make(map[string]int),
+		send:       sendNotification,
+	}
+}
+
+// configure replaces the notifiers. Rate limits carry over for notifiers that keep
+// their name.
+func (m *notifierManager) configure(notifiers []Notifier) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifiers = append([]Notifier(nil), notifiers...)
+}
+
+// failure records that kind/name failed and notifies about event
+func (m *notifierManager) failure(event, kind, name, message string) {
+	m.mu.Lock()
+	m.failed[kind+"/"+name] = true
+	m.mu.Unlock()
+	m.notify(event, kind, name, message)
+}
+
+// ready notifies about the recovery of kind/name if it had a failure event
+func (m *notifierManager) ready(kind, name string) {
+	key := kind + "/" + name
+	m.mu.Lock()
+	wasFailed := m.failed[key]
+	delete(m.failed, key)
+	m.mu.Unlock()
+	if wasFailed {
+		m.notify(EventRecovered, kind, name, fmt.Sprintf("%s is running again", name))
+	}
+}
+
+// notify sends event to every notifier that wants it and is not rate limited, in
+// the background
+func (m *notifierManager) notify(event, kind, name, message string) {
+	now := time.Now()
+	type delivery struct {
+		n   Notifier
+		msg notification
+	}
+	var due []delivery
+	m.mu.Lock()
+	send := m.send
+	for _, n := range m.notifiers {
+		if !n.Wants(event, name) {
+			continue
+		}
+		key := n.Name + "\x00" + event + "\x00" + kind + "\x00" + name
+		if limit := n.GetRateLimit(); limit > 0 && now.Sub(m.last[key]) < limit {
+			m.suppressed[key]++
+			debugLog("notifier %s: %s for %s rate limited", n.Name, event, name)
+			continue
+		}
+		m.last[key] = now
+		msg := notification{Event: event, Kind: kind, Name: name, Message: message, Time: now, Suppressed: m.suppressed[key]}
+		delete(m.suppressed, key)
+		due = append(due, delivery{n, msg})
+	}
+	m.mu.Unlock()
+
+	for _, d := range due {
+		go func(d delivery) {
+			if err := send(d.n, d.msg); err != nil {
+				debugLog("notifier %s: %s for %s failed: %v", d.n.Name, d.msg.Event, d.msg.Name, err)
+			}
+		}(d)
+	}
+}
+
+// test sends a test notification through the named notifier and waits for it
+func (m *notifierManager) test(name string) (bool, error) {
+	m.mu.Lock()
+	var found *Notifier
+	for i := range m.notifiers {
+		if m.notifiers[i].Name == name {
+			n := m.notifiers[i]
+			found = &n
+		}
+	}
+	send := m.send
+	m.mu.Unlock()
+	if found == nil {
+		return false, nil
+	}
+	return true, send(*found, notification{
+		Event:   eventTest,
+		Name:    "kubefwd",
+		Message: "Test notification from kubefwd",
+		Time:    time.Now(),
+	})
+}
+
+// sendNotification delivers msg through n
+func sendNotification(n Notifier, msg notification) error {
+	debugLog("notifier %s: %s for %s: %s", n.Name, msg.Event, msg.Name, msg.Message)
+	switch n.Type {
+	case NotifierCommand:
+		return runNotifierCommand(n, msg)
+	case NotifierWebhook:
+		return postNotifierWebhook(n, msg)
+	}
+	return fmt.Errorf("unknown notifier type %q", n.Type)
+}
+
+// runNotifierCommand runs the command with sh -c; the notification is passed in
+// KUBEFWD_* environment variables
+func runNotifierCommand(n Notifier, msg notification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifierTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", n.Command)
+	cmd.Env = append(os.Environ(),
+		"KUBEFWD_EVENT="+msg.Event,
+		"KUBEFWD_KIND="+msg.Kind,
+		"KUBEFWD_SERVICE="+msg.Name,
+		"KUBEFWD_MESSAGE="+msg.Message,
+		"KUBEFWD_TIME="+msg.Time.Format(time.RFC3339),
+		fmt.Sprintf("KUBEFWD_SUPPRESSED=%d", msg.Suppressed),
+	)
+	out := &lineWriter{emit: func(line string) { debugLog("notifier %s: %s", n.Name, line) }}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.WaitDelay = 2 * time.Second
+	err := cmd.Run()
+	out.flush()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", notifierTimeout)
+	}
+	return err
+}
+
+// postNotifierWebhook POSTs the notification as JSON and expects a 2xx answer
+func postNotifierWebhook(n Notifier, msg notification) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifierTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kubefwd")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingNotifiers returns a manager whose notifications are delivered to the
+// returned channel instead of being sent
+func recordingNotifiers(notifiers ...Notifier) (*notifierManager, chan notification) {
+	m := newNotifierManager()
+	sent := make(chan notification, 16)
+	m.send = func(n Notifier, msg notification) error {
+		sent <- msg
+		return nil
+	}
+	m.configure(notifiers)
+	return m, sent
+}
+
+func nextNotification(t *testing.T, sent chan notification) notification {
+	t.Helper()
+	select {
+	case msg := <-sent:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification sent")
+	}
+	return notification{}
+}
+
+func expectNoNotification(t *testing.T, sent chan notification) {
+	t.Helper()
+	select {
+	case msg := <-sent:
+		t.Fatalf("unexpected notification: %+v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNotifierRateLimitCountsSuppressed(t *testing.T) {
+	m, sent := recordingNotifiers(Notifier{Name: "desktop", Type: NotifierCommand, Command: "true"})
+
+	m.failure(EventRetriesExhausted, historyKindService, "API", "API failed")
+	if msg := nextNotification(t, sent); msg.Event != EventRetriesExhausted || msg.Name != "API" || msg.Suppressed != 0 {
+		t.Fatalf("first notification: %+v", msg)
+	}
+	m.failure(EventRetriesExhausted, historyKindService, "API", "API failed")
+	m.failure(EventRetriesExhausted, historyKindService, "API", "API failed")
+	expectNoNotification(t, sent)
+
+	// Another service is limited separately
+	m.failure(EventRetriesExhausted, historyKindService, "DB", "DB failed")
+	if msg := nextNotification(t, sent); msg.Name != "DB" {
+		t.Fatalf("other service: %+v", msg)
+	}
+
+	// Once the window has passed the next notification reports what was held back
+	m.mu.Lock()
+	for key := range m.last {
+		m.last[key] = time.Now().Add(-time.Hour)
+	}
+	m.mu.Unlock()
+	m.failure(EventRetriesExhausted, historyKindService, "API", "API failed")
+	if msg := nextNotification(t, sent); msg.Suppressed != 2 {
+		t.Fatalf("suppressed count: %+v", msg)
+	}
+}
+
+func TestNotifierRecoveredOnlyAfterFailure(t *testing.T) {
+	zero := 0
+	m, sent := recordingNotifiers(Notifier{Name: "desktop", Type: NotifierCommand, Command: "true", RateLimit: &zero})
+
+	m.ready(historyKindService, "API")
+	expectNoNotification(t, sent)
+
+	m.failure(EventSqlTapCrashed, historyKindSqlTap, "API", "sql-tapd crashed")
+	nextNotification(t, sent)
+	m.ready(historyKindService, "API") // A different kind did not fail
+	expectNoNotification(t, sent)
+	m.ready(historyKindSqlTap, "API")
+	if msg := nextNotification(t, sent); msg.Event != EventRecovered || msg.Kind != historyKindSqlTap {
+		t.Fatalf("recovery: %+v", msg)
+	}
+	m.ready(historyKindSqlTap, "API")
+	expectNoNotification(t, sent)
+}
+
+func TestNotifierFilters(t *testing.T) {
+	n := Notifier{Name: "db", Type: NotifierCommand, Command: "true",
+		Events: []string{EventRetriesExhausted}, Services: []string{"Database"}}
+	if !n.Wants(EventRetriesExhausted, "Database") {
+		t.Fatal("wanted event for listed service was filtered")
+	}
+	if n.Wants(EventRecovered, "Database") || n.Wants(EventRetriesExhausted, "API") {
+		t.Fatal("filters not applied")
+	}
+	all := Notifier{Name: "all"}
+	if !all.Wants(EventProxyPodNotReady, "ctx/ns") {
+		t.Fatal("notifier without filters should want everything")
+	}
+	if all.GetRateLimit() != defaultNotifierRateLimit*time.Second {
+		t.Fatalf("default rate limit: %s", all.GetRateLimit())
+	}
+}
+
+func TestNotifierValidation(t *testing.T) {
+	negative := -1
+	cases := []struct {
+		n    Notifier
+		want string
+	}{
+		{Notifier{Type: NotifierCommand, Command: "true"}, "name is required"},
+		{Notifier{Name: "x", Type: "email"}, "type must be"},
+		{Notifier{Name: "x", Type: NotifierCommand, Command: "  "}, "command is required"},
+		{Notifier{Name: "x", Type: NotifierWebhook, URL: "hooks.example.com"}, "http or https"},
+		{Notifier{Name: "x", Type: NotifierWebhook, URL: "https://hooks.example.com", Events: []string{"crashed"}}, "unknown event"},
+		{Notifier{Name: "x", Type: NotifierCommand, Command: "true", RateLimit: &negative}, "rate_limit"},
+	}
+	for _, c := range cases {
+		cfg := &Config{ClusterContext: "ctx1", Namespace: "default",
+			Services:  []Service{{Name: "A", ServiceName: "a", RemotePort: 80, LocalPort: 8080}},
+			Notifiers: []Notifier{c.n}}
+		ApplyConfigDefaults(cfg)
+		err := ValidateConfig(cfg)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%+v: got %v, want %q", c.n, err, c.want)
+		}
+	}
+
+	cfg := &Config{ClusterContext: "ctx1", Namespace: "default",
+		Services: []Service{{Name: "A", ServiceName: "a", RemotePort: 80, LocalPort: 8080}},
+		Notifiers: []Notifier{
+			{Name: "x", Type: NotifierCommand, Command: "true"},
+			{Name: "x", Type: NotifierWebhook, URL: "https://hooks.example.com"},
+		}}
+	ApplyConfigDefaults(cfg)
+	if err := ValidateConfig(cfg); err == nil || !strings.Contains(err.Error(), "used twice") {
+		t.Fatalf("duplicate notifier name: %v", err)
+	}
+}
+
+func TestWebhookNotifierPostsJSON(t *testing.T) {
+	received := make(chan notification, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg notification
+		if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&msg) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- msg
+	}))
+	defer srv.Close()
+
+	m := newNotifierManager()
+	m.configure([]Notifier{{Name: "chat", Type: NotifierWebhook, URL: srv.URL}})
+	found, err := m.test("chat")
+	if !found || err != nil {
+		t.Fatalf("test notification: found=%v err=%v", found, err)
+	}
+	if msg := <-received; msg.Event != eventTest || msg.Message == "" {
+		t.Fatalf("webhook payload: %+v", msg)
+	}
+	if found, _ := m.test("missing"); found {
+		t.Fatal("unknown notifier found")
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	m.configure([]Notifier{{Name: "chat", Type: NotifierWebhook, URL: failing.URL}})
+	if _, err := m.test("chat"); err == nil {
+		t.Fatal("expected error for non-2xx webhook answer")
+	}
+}
+
+// stubSqlTapd puts a sql-tapd on PATH that runs the shell script body
+func stubSqlTapd(t *testing.T, body string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sql-tapd"), []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestSqlTapExitAtStartNotifiesOnce(t *testing.T) {
+	stubSqlTapd(t, "echo 'bind: address already in use' >&2; exit 1")
+	zero := 0
+	m, sent := recordingNotifiers(Notifier{Name: "desktop", Type: NotifierCommand, Command: "true", RateLimit: &zero})
+	defer func(prev *notifierManager) { notifications = prev }(notifications)
+	notifications = m
+
+	stm := NewSqlTapManager("DB", true, "postgres", "127.0.0.1", 0, 0, 0, 0)
+	if err := stm.Start(); err == nil {
+		t.Fatal("sql-tapd exiting at start was not reported")
+	}
+	if msg := nextNotification(t, sent); msg.Event != EventSqlTapCrashed || msg.Name != "DB" {
+		t.Fatalf("notification: %+v", msg)
+	}
+	expectNoNotification(t, sent)
+	if status, errMsg := stm.GetStatus(); status != StatusError || !strings.Contains(errMsg, "address already in use") {
+		t.Fatalf("status %s: %s", status, errMsg)
+	}
+}
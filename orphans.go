@@ -0,0 +1,519 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Actions selectable through orphans, for the leftovers of a kubefwd that was killed
+const (
+	OrphansAsk     = "ask"     // Report them in the web UI and the port checker (default)
+	OrphansAdopt   = "adopt"   // Reuse proxy pods and restart the forwards under this kubefwd
+	OrphansCleanup = "cleanup" // Kill the processes and delete the pods
+	OrphansIgnore  = "ignore"  // Do not look for them
+)
+
+func isValidOrphanAction(action string) bool {
+	switch action {
+	case OrphansAsk, OrphansAdopt, OrphansCleanup, OrphansIgnore:
+		return true
+	}
+	return false
+}
+
+// Kinds of orphans
+const (
+	orphanKubectl = "kubectl"  // kubectl port-forward of a service or proxy pod
+	orphanSqlTapd = "sql-tapd" // sql-tapd of a service or proxy service
+	orphanPod     = "pod"      // Proxy pod
+)
+
+// orphan is a process or proxy pod a kubefwd that was killed left behind
+type orphan struct {
+	ID        string   `json:"id"`
+	Kind      string   `json:"kind"`
+	Name      string   `json:"name,omitempty"`  // Service or proxy service it belongs to; the proxy group for proxy pods and their forwards
+	Proxy     bool     `json:"proxy,omitempty"` // Name is a proxy service or proxy group
+	PID       int      `json:"pid,omitempty"`
+	Command   string   `json:"command,omitempty"`
+	Context   string   `json:"context,omitempty"`
+	Namespace string   `json:"namespace,omitempty"`
+	Pod       string   `json:"pod,omitempty"`
+	Services  []string `json:"services,omitempty"` // Proxy services an adoptable pod relays
+	Adoptable bool     `json:"adoptable"`          // Pods: running and relaying configured proxy services
+
+	podPorts map[string]int // Pod port of each proxy service in Services
+}
+
+// processEntry is a process as listed by ps
+type processEntry struct {
+	PID     int
+	PPID    int
+	Command string
+}
+
+// listProcesses returns every process with its parent and command line
+func listProcesses() ([]processEntry, error) {
+	debugLog("CMD: ps -axo pid=,ppid=,command=")
+	out, err := exec.Command("ps", "-axo", "pid=,ppid=,command=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ps: %w", err)
+	}
+	return parseProcessList(string(out)), nil
+}
+
+// parseProcessList parses the output of ps -o pid=,ppid=,command=
+func parseProcessList(out string) []processEntry {
+	var procs []processEntry
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		procs = append(procs, processEntry{PID: pid, PPID: ppid, Command: strings.Join(fields[2:], " ")})
+	}
+	return procs
+}
+
+// commandName returns the executable name of a command line
+func commandName(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return ""
+	}
+	return filepath.Base(fields[0])
+}
+
+// matchOrphanProcesses returns the kubectl port-forwards and sql-tapd processes of
+// procs that belong to configured forwards and whose kubefwd is gone. Processes
+// started by a running kubefwd, this one included, are left out.
+func matchOrphanProcesses(cfg *Config, procs []processEntry) []orphan {
+	byPID := make(map[int]processEntry, len(procs))
+	for _, p := range procs {
+		byPID[p.PID] = p
+	}
+	var out []orphan
+	for _, p := range procs {
+		if parent, ok := byPID[p.PPID]; ok && strings.Contains(commandName(parent.Command), "kubefwd") {
+			continue
+		}
+		o, ok := matchKubectlOrphan(cfg, p.Command)
+		if !ok {
+			o, ok = matchSqlTapdOrphan(cfg, p.Command)
+		}
+		if ok {
+			o.ID = "pid:" + strconv.Itoa(p.PID)
+			o.PID = p.PID
+			o.Command = p.Command
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// parseKubectlForward parses a kubectl port-forward command line as kubefwd builds
+// them (see forwardSpec.kubectlArgs). kubefwd always binds them to 127.0.0.1, which
+// tells them apart from port-forwards started by hand.
+func parseKubectlForward(command string) (forwardSpec, bool) {
+	f := strings.Fields(command)
+	if len(f) < 8 || commandName(command) != "kubectl" || !strings.HasPrefix(f[1], "--context=") ||
+		f[2] != "-n" || f[4] != "port-forward" || f[5] != "--address=127.0.0.1" {
+		return forwardSpec{}, false
+	}
+	return forwardSpec{
+		Context:   strings.TrimPrefix(f[1], "--context="),
+		Namespace: f[3],
+		Address:   "127.0.0.1",
+		Resource:  f[6],
+		Ports:     f[7:],
+	}, true
+}
+
+// matchKubectlOrphan matches a kubectl port-forward to the service or proxy group it
+// forwards. Services without a context of their own may have run on any configured
+// context. Selector targets forward to a pod picked at start, so they are matched by
+// their remote ports.
+func matchKubectlOrphan(cfg *Config, command string) (orphan, bool) {
+	spec, ok := parseKubectlForward(command)
+	if !ok {
+		return orphan{}, false
+	}
+	remote := make([]string, len(spec.Ports))
+	for i, p := range spec.Ports {
+		remote[i] = p[strings.LastIndex(p, ":")+1:]
+	}
+	for i := range cfg.Services {
+		svc := &cfg.Services[i]
+		if !serviceContextMatches(cfg, svc, spec.Context) || svc.GetNamespace(cfg.Namespace) != spec.Namespace {
+			continue
+		}
+		var ports []string
+		for _, pm := range svc.PortMappings() {
+			ports = append(ports, strconv.Itoa(pm.RemotePort))
+		}
+		if spec.Resource == svc.Resource() ||
+			(svc.Selector != "" && strings.HasPrefix(spec.Resource, TargetKindPod+"/") && strings.Join(ports, " ") == strings.Join(remote, " ")) {
+			return orphan{Kind: orphanKubectl, Name: svc.Name}, true
+		}
+	}
+	for _, ps := range cfg.ProxyServices {
+		if ps.ProxyPodContext == spec.Context && ps.ProxyPodNamespace == spec.Namespace &&
+			spec.Resource == TargetKindPod+"/"+BuildPodName(cfg.ProxyPodName, ps.ProxyPodContext, ps.ProxyPodNamespace) {
+			return orphan{Kind: orphanKubectl, Name: ps.ProxyGroupKey(), Proxy: true}, true
+		}
+	}
+	return orphan{}, false
+}
+
+// serviceContextMatches reports whether svc may have forwarded through context
+func serviceContextMatches(cfg *Config, svc *Service, context string) bool {
+	if svc.Context != "" {
+		return svc.Context == context
+	}
+	if cfg.ClusterContext == context {
+		return true
+	}
+	for _, ac := range cfg.AlternativeContexts {
+		if ac.Context == context {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSqlTapdOrphan matches a sql-tapd to the service or proxy service whose
+// sql_tap_port and bind_address it listens on
+func matchSqlTapdOrphan(cfg *Config, command string) (orphan, bool) {
+	if commandName(command) != "sql-tapd" {
+		return orphan{}, false
+	}
+	var listen string
+	for _, arg := range strings.Fields(command) {
+		if strings.HasPrefix(arg, "--listen=") {
+			listen = strings.TrimPrefix(arg, "--listen=")
+		}
+	}
+	host, portStr, err := net.SplitHostPort(listen)
+	if err != nil {
+		return orphan{}, false
+	}
+	port, _ := strconv.Atoi(portStr)
+	for _, svc := range cfg.Services {
+		if svc.SqlTapPort != nil && *svc.SqlTapPort == port && svc.BindAddress == host {
+			return orphan{Kind: orphanSqlTapd, Name: svc.Name}, true
+		}
+	}
+	for _, ps := range cfg.ProxyServices {
+		if ps.SqlTapPort != nil && *ps.SqlTapPort == port && ps.BindAddress == host {
+			return orphan{Kind: orphanSqlTapd, Name: ps.Name, Proxy: true}, true
+		}
+	}
+	return orphan{}, false
+}
+
+// findOrphanPods returns the pods this machine's kubefwd created in context and
+// namespace, and the pod named podName, the proxy pod of the group there. group are
+// the proxy services of that group.
+func findOrphanPods(context, namespace, podName string, group []ProxyService) ([]orphan, error) {
+	get := func(selector string) ([]orphan, error) {
+		cmd := exec.Command("kubectl",
+			"--context="+context,
+			"-n", namespace,
+			"get", "pods", selector,
+			"--request-timeout=10s",
+			"-o", "json")
+		output, err := debugRunCmd(cmd)
+		if err != nil {
+			return nil, fmt.Errorf("kubectl get pods failed: %v | %s", err, string(output))
+		}
+		return parseOrphanPods(output, context, namespace, podName, group)
+	}
+	found, err := get("--selector=" + proxyPodLabel)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range found {
+		if o.Pod == podName {
+			return found, nil
+		}
+	}
+	// Pods created before kubefwd labelled them are only found by name
+	named, err := get("--field-selector=metadata.name=" + podName)
+	if err != nil {
+		return nil, err
+	}
+	return append(found, named...), nil
+}
+
+// socatListenRe matches a relay of the proxy pod script: the pod port and the target
+var socatListenRe = regexp.MustCompile(`TCP-LISTEN:(\d+),\S*\s+TCP:(\S+):(\d+)`)
+
+// parseOrphanPods turns a kubectl pod list into orphans. The pod named podName is the
+// proxy pod of the group; running, it can be adopted with the proxy services of group
+// its script relays.
+func parseOrphanPods(data []byte, context, namespace, podName string, group []ProxyService) ([]orphan, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name              string `json:"name"`
+				DeletionTimestamp string `json:"deletionTimestamp"`
+			} `json:"metadata"`
+			Spec struct {
+				Containers []struct {
+					Command []string `json:"command"`
+				} `json:"containers"`
+			} `json:"spec"`
+			Status struct {
+				Phase string `json:"phase"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse pod list JSON: %v", err)
+	}
+	var out []orphan
+	for _, item := range list.Items {
+		if item.Metadata.DeletionTimestamp != "" {
+			continue
+		}
+		o := orphan{
+			ID:        "pod:" + context + "/" + namespace + "/" + item.Metadata.Name,
+			Kind:      orphanPod,
+			Context:   context,
+			Namespace: namespace,
+			Pod:       item.Metadata.Name,
+		}
+		if item.Metadata.Name == podName {
+			o.Name, o.Proxy = proxyGroupKey(context, namespace), true
+			if item.Status.Phase == "Running" && len(item.Spec.Containers) > 0 {
+				o.podPorts = make(map[string]int)
+				script := strings.Join(item.Spec.Containers[0].Command, " ")
+				for _, m := range socatListenRe.FindAllStringSubmatch(script, -1) {
+					podPort, _ := strconv.Atoi(m[1])
+					target, _ := strconv.Atoi(m[3])
+					for _, ps := range group {
+						if _, taken := o.podPorts[ps.Name]; !taken && ps.TargetHost == m[2] && ps.TargetPort == target {
+							o.podPorts[ps.Name] = podPort
+							o.Services = append(o.Services, ps.Name)
+							break
+						}
+					}
+				}
+				o.Adoptable = len(o.Services) > 0
+			}
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+// deleteOrphanPod deletes a pod without waiting for it to be gone
+func deleteOrphanPod(o orphan) error {
+	cmd := exec.Command("kubectl",
+		"--context="+o.Context,
+		"-n", o.Namespace,
+		"delete", "pod", o.Pod,
+		"--ignore-not-found=true",
+		"--wait=false")
+	if output, err := debugRunCmd(cmd); err != nil {
+		return fmt.Errorf("kubectl delete pod failed: %v | %s", err, string(output))
+	}
+	return nil
+}
+
+// RecoverOrphans looks for the leftovers of a kubefwd that was killed and adopts or
+// cleans them up as the orphans setting says. With ask they are only reported.
+func (wa *WebApp) RecoverOrphans() {
+	wa.mu.RLock()
+	action := wa.config.Orphans
+	wa.mu.RUnlock()
+	if action == OrphansIgnore {
+		return
+	}
+	found := wa.ScanOrphans()
+	if len(found) == 0 {
+		return
+	}
+	switch action {
+	case OrphansAdopt, OrphansCleanup:
+		errs := wa.resolveOrphans(nil, action == OrphansAdopt)
+		verb := "Cleaned up"
+		if action == OrphansAdopt {
+			verb = "Adopted"
+		}
+		fmt.Printf("%s %d leftovers of a previous kubefwd\n", verb, len(found)-len(errs))
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	default:
+		fmt.Printf("Found %d leftovers of a previous kubefwd; adopt or clean them up in the web UI\n", len(found))
+	}
+}
+
+// ScanOrphans looks for the processes and proxy pods a kubefwd that was killed left
+// behind and keeps them for the web UI. Groups whose proxy pod this kubefwd already
+// created are not searched.
+func (wa *WebApp) ScanOrphans() []orphan {
+	type podGroup struct {
+		mgr      *ProxyPodManager
+		services []ProxyService
+	}
+	wa.mu.RLock()
+	cfg := wa.config
+	var keys []string
+	groups := make(map[string]podGroup)
+	for key, mgr := range wa.proxyPodManagers {
+		if st, _, _ := mgr.GetStatus(); st == ProxyPodStatusNotCreated {
+			keys = append(keys, key)
+			groups[key] = podGroup{mgr: mgr, services: wa.allServicesForGroup(key)}
+		}
+	}
+	wa.mu.RUnlock()
+	sort.Strings(keys)
+
+	var found []orphan
+	procs, err := listProcesses()
+	if err != nil {
+		debugLog("orphans: %v", err)
+	} else {
+		found = matchOrphanProcesses(cfg, procs)
+	}
+	for _, key := range keys {
+		g := groups[key]
+		pods, err := findOrphanPods(g.mgr.context, g.mgr.namespace, g.mgr.podName, g.services)
+		if err != nil {
+			debugLog("orphans: proxy pods in %s: %v", key, err)
+			continue
+		}
+		found = append(found, pods...)
+	}
+	debugLog("orphans: found %d", len(found))
+
+	wa.mu.Lock()
+	wa.orphans = found
+	wa.mu.Unlock()
+	return found
+}
+
+// currentOrphanPIDs returns the PIDs of orphaned processes: those of the last scan
+// and those ps lists now, for the port checker
+func (wa *WebApp) currentOrphanPIDs() map[int]bool {
+	pids := make(map[int]bool)
+	wa.mu.RLock()
+	cfg := wa.config
+	for _, o := range wa.orphans {
+		if o.PID > 0 {
+			pids[o.PID] = true
+		}
+	}
+	wa.mu.RUnlock()
+	if procs, err := listProcesses(); err == nil {
+		for _, o := range matchOrphanProcesses(cfg, procs) {
+			pids[o.PID] = true
+		}
+	}
+	return pids
+}
+
+// resolveOrphans adopts the orphans with the given IDs, or cleans them up when adopt
+// is false; all of them when ids is empty. Cleaning up kills processes and deletes
+// pods. Adopting takes over a running proxy pod and restarts the forwards the
+// orphans belonged to under this kubefwd; pods that cannot be adopted are deleted.
+// It returns an error for every orphan it could not handle, which stays listed.
+func (wa *WebApp) resolveOrphans(ids []string, adopt bool) []error {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	wa.mu.RLock()
+	var selected []orphan
+	for _, o := range wa.orphans {
+		if len(ids) == 0 || wanted[o.ID] {
+			selected = append(selected, o)
+		}
+	}
+	wa.mu.RUnlock()
+
+	var errs []error
+	var restart []string
+	resolved := make(map[string]bool)
+	for _, o := range selected {
+		var err error
+		switch {
+		case o.Kind != orphanPod:
+			err = KillProcess(o.PID)
+			// A proxy pod forward comes back with its adopted pod
+			if err == nil && adopt && !(o.Proxy && o.Kind == orphanKubectl) {
+				restart = append(restart, o.Name)
+			}
+		case adopt && o.Adoptable:
+			err = wa.adoptOrphanPod(o)
+			if err == nil {
+				restart = append(restart, o.Services...)
+			}
+		default:
+			err = deleteOrphanPod(o)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", o.ID, err))
+			continue
+		}
+		resolved[o.ID] = true
+	}
+
+	wa.mu.Lock()
+	var remaining []orphan
+	for _, o := range wa.orphans {
+		if !resolved[o.ID] {
+			remaining = append(remaining, o)
+		}
+	}
+	wa.orphans = remaining
+	wa.mu.Unlock()
+
+	if len(restart) > 0 {
+		seen := make(map[string]bool)
+		var names []string
+		for _, n := range restart {
+			if !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+		debugLog("orphans: restarting adopted forwards: %s", strings.Join(names, ", "))
+		go wa.startWithDependencies(names, false)
+	}
+	return errs
+}
+
+// adoptOrphanPod makes the proxy pod orphan o the pod of its group
+func (wa *WebApp) adoptOrphanPod(o orphan) error {
+	wa.mu.RLock()
+	mgr, ok := wa.proxyPodManagers[o.Name]
+	var services []ProxyService
+	for _, ps := range wa.allServicesForGroup(o.Name) {
+		if _, relayed := o.podPorts[ps.Name]; relayed {
+			services = append(services, ps)
+		}
+	}
+	wa.mu.RUnlock()
+	if !ok || mgr.podName != o.Pod {
+		return fmt.Errorf("no proxy group for pod %s", o.Pod)
+	}
+	mgr.AdoptPod(services, o.podPorts)
+	debugLog("orphans: adopted proxy pod %s relaying %d services", o.Pod, len(services))
+	return nil
+}
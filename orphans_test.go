@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// orphanConfig returns a config with a service, a selector service, a sql-tap service
+// and a proxy service
+func orphanConfig() *Config {
+	tapPort := 5433
+	cfg := &Config{ClusterContext: "prod", Namespace: "default",
+		AlternativeContexts: []AlternativeContext{{Name: "Staging", Context: "staging"}},
+		Services: []Service{
+			{Name: "API", ServiceName: "api", RemotePort: 80, LocalPort: 8080},
+			{Name: "Worker", Selector: "app=worker", RemotePort: 9000, LocalPort: 9000},
+			{Name: "Database", ServiceName: "postgres", RemotePort: 5432, LocalPort: 5432, SqlTapPort: &tapPort},
+		},
+		ProxyServices: []ProxyService{
+			{Name: "CloudSQL", TargetHost: "10.0.0.5", TargetPort: 5432, LocalPort: 5434},
+			{Name: "Redis", TargetHost: "10.0.0.6", TargetPort: 6379, LocalPort: 6379},
+		},
+	}
+	ApplyConfigDefaults(cfg)
+	return cfg
+}
+
+func TestMatchOrphanProcesses(t *testing.T) {
+	cfg := orphanConfig()
+	api := forwardSpec{Context: "staging", Namespace: "default", Resource: "service/api", Ports: []string{"51234:80"}, Address: "127.0.0.1"}
+	proxy := forwardSpec{Context: "prod", Namespace: "default", Resource: "pod/" + BuildPodName("kubefwd-proxy", "prod", "default"), Ports: []string{"51236:10000"}, Address: "127.0.0.1"}
+	procs := parseProcessList(strings.Join([]string{
+		"  100     1 /usr/local/bin/kubefwd --default",
+		"  200     1 " + api.commandString(ForwardEngineKubectl),
+		"  201   100 " + api.commandString(ForwardEngineKubectl), // Child of a running kubefwd
+		"  202     1 kubectl --context=prod -n default port-forward --address=127.0.0.1 pod/worker-7d9f 51235:9000",
+		"  203     1 " + proxy.commandString(ForwardEngineKubectl),
+		"  204     1 kubectl --context=prod -n default port-forward service/api 8080:80", // Started by hand
+		"  205     1 kubectl --context=other -n default port-forward --address=127.0.0.1 service/api 51237:80",
+		"  300   250 sql-tapd --driver=postgres --listen=:5433 --upstream=localhost:5432 --grpc=:9091",
+		"  301   250 sql-tapd --driver=postgres --listen=127.0.0.2:5433 --upstream=127.0.0.2:5432 --grpc=:9091",
+		"  400     1 bash",
+	}, "\n"))
+
+	got := make(map[int]string)
+	for _, o := range matchOrphanProcesses(cfg, procs) {
+		got[o.PID] = o.Kind + " " + o.Name
+	}
+	want := map[int]string{
+		200: "kubectl API",
+		202: "kubectl Worker",
+		203: "kubectl prod/default",
+		300: "sql-tapd Database",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("orphans: %v", got)
+	}
+}
+
+func TestParseOrphanPods(t *testing.T) {
+	cfg := orphanConfig()
+	podName := BuildPodName(cfg.ProxyPodName, "prod", "default")
+	data := `{"items": [
+		{"metadata": {"name": "` + podName + `"}, "status": {"phase": "Running"},
+		 "spec": {"containers": [{"command": ["sh", "-c", "socat TCP-LISTEN:10000,fork,reuseaddr TCP:10.0.0.6:6379 & socat TCP-LISTEN:10001,fork,reuseaddr TCP:10.9.9.9:3306 & wait"]}]}},
+		{"metadata": {"name": "kubefwd-old-prod-default"}, "status": {"phase": "Running"}, "spec": {"containers": [{"command": ["sh"]}]}},
+		{"metadata": {"name": "kubefwd-gone", "deletionTimestamp": "2026-01-01T00:00:00Z"}, "status": {"phase": "Running"}}
+	]}`
+	orphans, err := parseOrphanPods([]byte(data), "prod", "default", podName, cfg.ProxyServices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 2 {
+		t.Fatalf("orphans: %+v", orphans)
+	}
+	pod := orphans[0]
+	if !pod.Adoptable || pod.Name != "prod/default" || !reflect.DeepEqual(pod.Services, []string{"Redis"}) || pod.podPorts["Redis"] != 10000 {
+		t.Fatalf("group pod: %+v", pod)
+	}
+	if stale := orphans[1]; stale.Adoptable || stale.Name != "" || stale.ID != "pod:prod/default/kubefwd-old-prod-default" {
+		t.Fatalf("stale pod: %+v", stale)
+	}
+
+	pending := `{"items": [{"metadata": {"name": "` + podName + `"}, "status": {"phase": "Pending"}}]}`
+	if orphans, _ := parseOrphanPods([]byte(pending), "prod", "default", podName, cfg.ProxyServices); len(orphans) != 1 || orphans[0].Adoptable {
+		t.Fatalf("pending pod: %+v", orphans)
+	}
+}
+
+func TestOrphansValidation(t *testing.T) {
+	cfg := orphanConfig()
+	if cfg.Orphans != OrphansAsk {
+		t.Fatalf("default: %q", cfg.Orphans)
+	}
+	cfg.Orphans = "delete"
+	if err := ValidateConfig(cfg); err == nil || !strings.Contains(err.Error(), "orphans must be") {
+		t.Fatalf("got %v", err)
+	}
+}
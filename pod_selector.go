@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// errTargetPodGone ends a selector forward whose pod was deleted or stopped being
+// Ready. monitor restarts such forwards right away against another pod.
+var errTargetPodGone = errors.New("target pod went away")
+
+// selectorForwarder resolves a label selector to a Ready pod, forwards to that pod
+// with the configured engine and ends as soon as the pod goes away.
+type selectorForwarder struct {
+	mu    sync.Mutex
+	pod   string
+	inner forwarder
+	done  chan struct{}
+	err   error
+}
+
+// startSelectorForwarder returns immediately; resolving the pod happens in the
+// background so failures surface through Wait like any other forwarder.
+func startSelectorForwarder(ctx context.Context, engine string, spec forwardSpec, stdout, stderr io.Writer) (forwarder, error) {
+	sf := &selectorForwarder{done: make(chan struct{})}
+	go func() {
+		sf.err = sf.run(ctx, engine, spec, stdout, stderr)
+		if sf.err != nil && stderr != nil && !errors.Is(sf.err, errTargetPodGone) && ctx.Err() == nil {
+			fmt.Fprintf(stderr, "error: %v\n", sf.err)
+		}
+		close(sf.done)
+	}()
+	return sf, nil
+}
+
+func (sf *selectorForwarder) run(ctx context.Context, engine string, spec forwardSpec, stdout, stderr io.Writer) error {
+	clientset, _, err := clientsetForContext(spec.Context)
+	if err != nil {
+		return err
+	}
+	pod, err := firstReadyPod(ctx, clientset, spec.Namespace, spec.Selector)
+	if err != nil {
+		return fmt.Errorf("selector %s: %w", spec.Selector, err)
+	}
+	sf.mu.Lock()
+	sf.pod = pod.Name
+	sf.mu.Unlock()
+	debugLog("selector %s resolved to pod/%s", spec.Selector, pod.Name)
+
+	innerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	podSpec := spec
+	podSpec.Selector = ""
+	podSpec.Resource = "pod/" + pod.Name
+	inner, err := startForwarder(innerCtx, engine, podSpec, stdout, stderr)
+	if err != nil {
+		return err
+	}
+	sf.mu.Lock()
+	sf.inner = inner
+	sf.mu.Unlock()
+
+	gone := make(chan struct{})
+	go func() {
+		if watchPodGone(innerCtx, clientset, spec.Namespace, pod.Name) {
+			close(gone)
+			cancel()
+		}
+	}()
+
+	err = inner.Wait()
+	select {
+	case <-gone:
+		return fmt.Errorf("pod %s: %w", pod.Name, errTargetPodGone)
+	default:
+	}
+	// The forward may die before the watch reports it (e.g. "lost connection to pod")
+	if err != nil && ctx.Err() == nil && !podStillReady(clientset, spec.Namespace, pod.Name) {
+		return fmt.Errorf("pod %s: %w", pod.Name, errTargetPodGone)
+	}
+	return err
+}
+
+func (sf *selectorForwarder) Wait() error {
+	<-sf.done
+	return sf.err
+}
+
+func (sf *selectorForwarder) PID() int {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.inner != nil {
+		return sf.inner.PID()
+	}
+	return 0
+}
+
+// PodName returns the pod the selector resolved to ("" while resolving)
+func (sf *selectorForwarder) PodName() string {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.pod
+}
+
+// watchPodGone blocks until the pod is deleted or no longer Ready (true) or ctx is
+// cancelled (false). Expired watches are re-established.
+func watchPodGone(ctx context.Context, clientset kubernetes.Interface, namespace, name string) bool {
+	opts := metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", name).String()}
+	for {
+		w, err := clientset.CoreV1().Pods(namespace).Watch(ctx, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false
+			}
+			debugLog("watch pod/%s: %v", name, err)
+		} else {
+		events:
+			for {
+				select {
+				case <-ctx.Done():
+					w.Stop()
+					return false
+				case ev, ok := <-w.ResultChan():
+					if !ok {
+						break events
+					}
+					pod, isPod := ev.Object.(*corev1.Pod)
+					if !isPod || pod.Name != name {
+						continue
+					}
+					if ev.Type == watch.Deleted || !isPodReady(pod) {
+						w.Stop()
+						return true
+					}
+				}
+			}
+			w.Stop()
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		// The watch ended (timeout or error); check the current state before watching again
+		if !podStillReady(clientset, namespace, name) {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// podStillReady reports whether the pod exists and is Ready. API errors other than
+// NotFound count as ready so a flaky API server does not trigger a failover.
+func podStillReady(clientset kubernetes.Interface, namespace, name string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return isPodReady(pod)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWatchPodGone(t *testing.T) {
+	ready := corev1.PodStatus{
+		Phase:      corev1.PodRunning,
+		Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+	}
+	clientset := fake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "ns"}, Status: ready},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-2", Namespace: "ns"}, Status: ready},
+	)
+
+	result := make(chan bool, 1)
+	go func() { result <- watchPodGone(context.Background(), clientset, "ns", "api-1") }()
+	time.Sleep(100 * time.Millisecond)
+
+	// Deleting another pod must not trigger a failover
+	if err := clientset.CoreV1().Pods("ns").Delete(context.Background(), "api-2", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-result:
+		t.Fatal("reported gone after another pod was deleted")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := clientset.CoreV1().Pods("ns").Delete(context.Background(), "api-1", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case gone := <-result:
+		if !gone {
+			t.Fatal("expected gone")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("watch did not notice the deleted pod")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if watchPodGone(ctx, clientset, "ns", "api-2") {
+		t.Fatal("cancelled watch reported gone")
+	}
+}
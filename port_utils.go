@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"net"
+	"os"
 	"os/exec"
 	"regexp"
 	"strconv"
@@ -16,6 +18,7 @@ const (
 	PortStatusFree     PortStatus = "free"
 	PortStatusKubefwd  PortStatus = "kubefwd"
 	PortStatusExternal PortStatus = "external"
+	PortStatusOrphan   PortStatus = "orphan" // Left behind by a kubefwd that was killed (see orphans.go)
 )
 
 // PortUsageInfo contains information about port usage
@@ -26,8 +29,81 @@ type PortUsageInfo struct {
 	Status      PortStatus
 }
 
-// GetPortUsage checks if a port is in use and returns information about the process
+// anyAddress matches listeners on every local address (see addressesOverlap)
+const anyAddress = "*"
+
+// isValidBindAddress reports whether addr can be used as a bind_address ("" means localhost)
+func isValidBindAddress(addr string) bool {
+	return addr == "" || addr == "localhost" || net.ParseIP(addr) != nil
+}
+
+// isWildcardAddress reports whether addr listens on all interfaces
+func isWildcardAddress(addr string) bool {
+	if addr == anyAddress {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.IsUnspecified()
+}
+
+// bindAddressSet expands a bind address to the concrete IPs it listens on.
+// "" and "localhost" bind both loopback addresses, like kubectl does by default.
+func bindAddressSet(addr string) []string {
+	if addr == "" || addr == "localhost" {
+		return []string{"127.0.0.1", "::1"}
+	}
+	if ip := net.ParseIP(addr); ip != nil {
+		return []string{ip.String()}
+	}
+	return []string{addr}
+}
+
+// addressesOverlap reports whether listeners on a and b would conflict on the same port
+func addressesOverlap(a, b string) bool {
+	if isWildcardAddress(a) || isWildcardAddress(b) {
+		return true
+	}
+	for _, x := range bindAddressSet(a) {
+		for _, y := range bindAddressSet(b) {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// displayBindAddress renders a bind address for messages ("" is shown as localhost)
+func displayBindAddress(addr string) string {
+	if addr == "" {
+		return "localhost"
+	}
+	return addr
+}
+
+// dialHost returns the host to connect to for a listener bound to addr.
+// Wildcard binds are reached over loopback.
+func dialHost(addr string) string {
+	switch {
+	case addr == "":
+		return "localhost"
+	case isWildcardAddress(addr):
+		if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+			return "::1"
+		}
+		return "127.0.0.1"
+	}
+	return addr
+}
+
+// GetPortUsage checks if a port is in use on any address and returns information about the process
 func GetPortUsage(port int) (PortUsageInfo, error) {
+	return GetPortUsageOn(anyAddress, port)
+}
+
+// GetPortUsageOn checks if a port is in use on an address that overlaps bindAddress
+// ("" means localhost) and returns information about the process
+func GetPortUsageOn(bindAddress string, port int) (PortUsageInfo, error) {
 	info := PortUsageInfo{
 		InUse:  false,
 		PID:    0,
@@ -79,6 +155,11 @@ func GetPortUsage(port int) (PortUsageInfo, error) {
 		command := fields[0]
 		pidStr := fields[1]
 
+		// Skip listeners on other addresses (e.g. 127.0.0.2:5432 when checking localhost)
+		if host, ok := lsofListenHost(fields); ok && !addressesOverlap(bindAddress, host) {
+			continue
+		}
+
 		pid, err := strconv.Atoi(pidStr)
 		if err != nil {
 			continue
@@ -100,6 +181,23 @@ func GetPortUsage(port int) (PortUsageInfo, error) {
 	return info, nil
 }
 
+// lsofListenHost extracts the host from the NAME column of an lsof line
+// ("*:5432", "127.0.0.2:5432" or "[::1]:5432", followed by "(LISTEN)")
+func lsofListenHost(fields []string) (string, bool) {
+	if len(fields) < 2 {
+		return "", false
+	}
+	name := fields[len(fields)-1]
+	if strings.HasPrefix(name, "(") {
+		name = fields[len(fields)-2]
+	}
+	idx := strings.LastIndex(name, ":")
+	if idx <= 0 {
+		return "", false
+	}
+	return strings.Trim(name[:idx], "[]"), true
+}
+
 // getProcessDetails retrieves detailed information about a process
 func getProcessDetails(pid int) string {
 	// Use ps to get the full command line
@@ -135,22 +233,47 @@ func KillProcess(pid int) error {
 	return nil
 }
 
+// pickFreePort returns a loopback port that is currently unused, for forwarders
+// that sit behind a kubefwd relay
+func pickFreePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
 // GetAllPortsFromConfig extracts all local ports from the configuration
 func GetAllPortsFromConfig(config *Config) []ConfigPort {
 	var ports []ConfigPort
 
 	// Collect ports from direct services
 	for _, svc := range config.Services {
-		ports = append(ports, ConfigPort{
-			Port:        svc.LocalPort,
-			ServiceName: svc.Name,
-			Type:        "Direct",
-		})
+		mappings := svc.PortMappings()
+		for _, pm := range mappings {
+			name := svc.Name
+			if len(mappings) > 1 {
+				// Label each mapping so multi-port services are distinguishable
+				label := pm.Name
+				if label == "" {
+					label = strconv.Itoa(pm.RemotePort)
+				}
+				name = fmt.Sprintf("%s (%s)", svc.Name, label)
+			}
+			ports = append(ports, ConfigPort{
+				Port:        pm.LocalPort,
+				Address:     svc.BindAddress,
+				ServiceName: name,
+				Type:        "Direct",
+			})
+		}
 
 		// Add sql-tap port if configured
 		if svc.SqlTapPort != nil {
 			ports = append(ports, ConfigPort{
 				Port:        *svc.SqlTapPort,
+				Address:     svc.BindAddress,
 				ServiceName: svc.Name + " (SQL-Tap)",
 				Type:        "Direct",
 			})
@@ -159,6 +282,7 @@ func GetAllPortsFromConfig(config *Config) []ConfigPort {
 		if svc.SqlTapHttpPort != nil {
 			ports = append(ports, ConfigPort{
 				Port:        *svc.SqlTapHttpPort,
+				Address:     svc.BindAddress,
 				ServiceName: svc.Name + " (SQL-Tap Web)",
 				Type:        "Direct",
 			})
@@ -169,6 +293,7 @@ func GetAllPortsFromConfig(config *Config) []ConfigPort {
 	for _, pxSvc := range config.ProxyServices {
 		ports = append(ports, ConfigPort{
 			Port:        pxSvc.LocalPort,
+			Address:     pxSvc.BindAddress,
 			ServiceName: pxSvc.Name,
 			Type:        "Proxy",
 		})
@@ -177,6 +302,7 @@ func GetAllPortsFromConfig(config *Config) []ConfigPort {
 		if pxSvc.SqlTapPort != nil {
 			ports = append(ports, ConfigPort{
 				Port:        *pxSvc.SqlTapPort,
+				Address:     pxSvc.BindAddress,
 				ServiceName: pxSvc.Name + " (SQL-Tap)",
 				Type:        "Proxy",
 			})
@@ -185,6 +311,7 @@ func GetAllPortsFromConfig(config *Config) []ConfigPort {
 		if pxSvc.SqlTapHttpPort != nil {
 			ports = append(ports, ConfigPort{
 				Port:        *pxSvc.SqlTapHttpPort,
+				Address:     pxSvc.BindAddress,
 				ServiceName: pxSvc.Name + " (SQL-Tap Web)",
 				Type:        "Proxy",
 			})
@@ -197,6 +324,7 @@ func GetAllPortsFromConfig(config *Config) []ConfigPort {
 // ConfigPort represents a port from the configuration
 type ConfigPort struct {
 	Port        int
+	Address     string // bind_address of the owning service ("" = localhost)
 	ServiceName string
 	Type        string // "Direct" or "Proxy"
 }
@@ -207,7 +335,12 @@ func IsKubefwdProcess(pid int, portForwards []*PortForward, proxyForwards map[st
 		return false
 	}
 
-	// Check direct port forwards
+	// The relays holding the local ports of all forwards listen in kubefwd itself
+	if pid == os.Getpid() {
+		return true
+	}
+
+	// Check the kubectl processes on the internal upstream ports of direct port forwards
 	for _, pf := range portForwards {
 		if pf.GetPID() == pid {
 			return true
@@ -220,7 +353,7 @@ func IsKubefwdProcess(pid int, portForwards []*PortForward, proxyForwards map[st
 		}
 	}
 
-	// Check proxy forwards
+	// Check proxy forwards likewise
 	for _, pxf := range proxyForwards {
 		if pxf.GetPID() == pid {
 			return true
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -75,3 +80,65 @@ func TestIsKubefwdProcess(t *testing.T) {
 		t.Error("Expected false for negative PID")
 	}
 }
+
+func TestAddressesOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"", "", true},
+		{"", "localhost", true},
+		{"", "127.0.0.1", true},
+		{"", "::1", true},
+		{"", "127.0.0.2", false},
+		{"127.0.0.2", "127.0.0.3", false},
+		{"127.0.0.2", "127.0.0.2", true},
+		{"0.0.0.0", "127.0.0.2", true},
+		{"::", "", true},
+		{anyAddress, "127.0.0.9", true},
+	}
+	for _, tt := range tests {
+		if got := addressesOverlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("addressesOverlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLsofListenHost(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"kubectl 12345 user 3u IPv4 0x1234 0t0 TCP *:5432 (LISTEN)", "*"},
+		{"kubectl 12345 user 3u IPv4 0x1234 0t0 TCP 127.0.0.2:5432 (LISTEN)", "127.0.0.2"},
+		{"kubectl 12345 user 4u IPv6 0x1234 0t0 TCP [::1]:5432 (LISTEN)", "::1"},
+	}
+	for _, tt := range tests {
+		got, ok := lsofListenHost(strings.Fields(tt.line))
+		if !ok || got != tt.want {
+			t.Errorf("lsofListenHost(%q) = %q, %v; want %q", tt.line, got, ok, tt.want)
+		}
+	}
+}
+
+// TestKillPortRefusesOwnPort checks that the kill endpoint never signals kubefwd,
+// which holds the local ports of its forwards
+func TestKillPortRefusesOwnPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+	time.Sleep(100 * time.Millisecond)
+
+	cfg := &Config{}
+	ApplyConfigDefaults(cfg)
+	wa := NewWebApp(cfg, &FileConfigStore{Path: filepath.Join(t.TempDir(), "kubefwd.yaml")})
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/ports/%d/kill?address=127.0.0.1", port), nil)
+	rec := httptest.NewRecorder()
+	wa.routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math"
+	"io"
+	"net"
 	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,32 +21,62 @@ const (
 	StatusStarting PortForwardStatus = "starting"
 	StatusRunning  PortForwardStatus = "running"
 	StatusError    PortForwardStatus = "error"
+	StatusArmed    PortForwardStatus = "armed" // Lazy: kubefwd holds the local port and starts the forward on the first connection
 )
 
+// PortMappingStatus is the state of one port mapping of a PortForward
+type PortMappingStatus struct {
+	Name       string
+	LocalPort  int
+	RemotePort int
+	Status     PortForwardStatus
+}
+
 // PortForward manages a single kubectl port-forward process
 type PortForward struct {
 	Service       Service
 	Status        PortForwardStatus
 	ErrorMessage  string
 	CommandString string
-	cmd           *exec.Cmd
+	fwd           forwarder // Running forward session (kubectl process or native)
 	cancel        context.CancelFunc
 	mu            sync.Mutex
 	context       string
 	namespace     string
+	engine        string // Forward engine: kubectl or native
 	retryCount    int  // Current retry attempt number
 	maxRetries    int  // Maximum retry attempts (-1 for infinite, 0 to disable)
+	retryPolicy   RetryPolicy // Backoff between retry attempts
 	manualStop    bool // Flag to prevent retries when user stops manually
 	retrying      bool // Indicates if currently in retry mode
+	readyFailed   bool // Set when the forward was stopped for missing its ready timeout or failing its post_start hook
+	readyTimeout  time.Duration // How long to wait for the local port to accept connections
+	portReady     map[int]bool  // Local ports of the current attempt that accept connections
+	idleTimeout   time.Duration // Stop after this long without client connections (0 = never)
+	maxLifetime   time.Duration // Stop this long after the session started (0 = never)
+	sessionStart  time.Time     // When the current session was started; retries and failovers continue it
+	runningSince  time.Time     // When the current attempt became ready
+	relays        []*portRelay  // Local listeners owned by kubefwd for the whole session, one per port mapping
+	upstreamPorts []int         // Internal forwarder ports the relays connect to, one per port mapping
+	metrics       *connMetrics  // Relayed connection and byte counters
+	logs          *logBuffer    // Forwarder output and lifecycle events
+	hooks         *hookRunner   // Lifecycle hooks (nil when the service has none)
+	hooksStarted  bool          // pre_start ran for the current session, so post_stop is due when it ends
+	postStarted   bool          // post_start ran for the current session
 	sqlTapManager *SqlTapManager // Manages sql-tapd process if enabled
 }
 
 // NewPortForward creates a new PortForward instance
-func NewPortForward(service Service, globalContext, globalNamespace string, globalMaxRetries int) *PortForward {
-	// Use service-specific context/namespace or fall back to global
-	context := service.GetContext(globalContext)
-	namespace := service.GetNamespace(globalNamespace)
-	maxRetries := service.GetMaxRetries(globalMaxRetries)
+func NewPortForward(service Service, cfg *Config) *PortForward {
+	// Use service-specific settings or fall back to global
+	context := service.GetContext(cfg.ClusterContext)
+	namespace := service.GetNamespace(cfg.Namespace)
+	maxRetries := service.GetMaxRetries(cfg.MaxRetries)
+	retryPolicy := service.GetRetryPolicy(cfg.RetryPolicy)
+	engine := service.GetForwardEngine(cfg.ForwardEngine)
+	readyTimeout := time.Duration(service.GetReadyTimeout(cfg.ReadyTimeout)) * time.Second
+	idleTimeout := time.Duration(service.GetIdleTimeout(cfg.IdleTimeout)) * time.Minute
+	maxLifetime := time.Duration(service.GetMaxLifetime(cfg.MaxLifetime)) * time.Hour
 	
 	// Initialize sql-tap manager if configured
 	var sqlTapManager *SqlTapManager
@@ -57,32 +90,176 @@ func NewPortForward(service Service, globalContext, globalNamespace string, glob
 			httpPort = *service.SqlTapHttpPort
 		}
 		sqlTapManager = NewSqlTapManager(
+			service.Name,
 			true,
 			service.SqlTapDriver,
+			service.BindAddress,
 			*service.SqlTapPort,
 			service.LocalPort,
 			grpcPort,
 			httpPort,
 		)
 	} else {
-		sqlTapManager = NewSqlTapManager(false, "", 0, 0, 0, 0)
+		sqlTapManager = NewSqlTapManager(service.Name, false, "", "", 0, 0, 0, 0)
 	}
 	
-	return &PortForward{
+	pf := &PortForward{
 		Service:       service,
 		Status:        StatusStopped,
 		context:       context,
 		namespace:     namespace,
+		engine:        engine,
 		maxRetries:    maxRetries,
+		retryPolicy:   retryPolicy,
 		retryCount:    0,
 		manualStop:    false,
 		retrying:      false,
+		readyTimeout:  readyTimeout,
+		idleTimeout:   idleTimeout,
+		maxLifetime:   maxLifetime,
+		metrics:       newConnMetrics(),
+		logs:          newLogBuffer(logSourceForward, serviceLogLines),
 		sqlTapManager: sqlTapManager,
 	}
+	var ports []int
+	for _, pm := range service.PortMappings() {
+		ports = append(ports, pm.LocalPort)
+	}
+	pf.hooks = newHookRunner(service.Name, service.Hooks, hookEnv(service.Name, service.BindAddress, ports, context, namespace))
+	return pf
+}
+
+// setStatusLocked changes the status and records the transition in the status history
+func (pf *PortForward) setStatusLocked(status PortForwardStatus, reason string) {
+	if status != pf.Status {
+		history.record(historyKindService, pf.Service.Name, string(pf.Status), string(status), reason)
+	}
+	pf.Status = status
+}
+
+// notifyFailedLocked tells the notifiers that the forward failed and is not retried
+func (pf *PortForward) notifyFailedLocked() {
+	notifications.failure(EventRetriesExhausted, historyKindService, pf.Service.Name,
+		fmt.Sprintf("%s failed: %s", pf.Service.Name, pf.ErrorMessage))
+}
+
+// StartOrArm arms lazy services and starts all others. Bulk actions (defaults,
+// presets, start all) use it; an explicit Start always starts the forward.
+func (pf *PortForward) StartOrArm() error {
+	if pf.Service.Lazy {
+		return pf.Arm()
+	}
+	return pf.Start()
+}
+
+// Arm binds the local ports without starting the forward. The forward is started
+// when the first client connects, which waits until it is ready.
+func (pf *PortForward) Arm() error {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	if pf.Status == StatusRunning || pf.Status == StatusStarting || pf.Status == StatusArmed {
+		return fmt.Errorf("port forward already running")
+	}
+	if err := pf.openRelaysLocked(); err != nil {
+		pf.setStatusLocked(StatusError, fmt.Sprintf("failed to arm: %v", err))
+		pf.ErrorMessage = fmt.Sprintf("Failed to arm: %v", err)
+		return err
+	}
+	pf.setStatusLocked(StatusArmed, "armed")
+	pf.ErrorMessage = ""
+	pf.manualStop = false
+	pf.retrying = false
+	pf.retryCount = 0
+	debugLog("%s: armed on %s", pf.Service.Name, joinPorts(pf.localPorts()))
+	return nil
+}
+
+// openRelaysLocked binds one relay per port mapping
+func (pf *PortForward) openRelaysLocked() error {
+	var relays []*portRelay
+	for i, pm := range pf.Service.PortMappings() {
+		idx := i
+		name := fmt.Sprintf("%s :%d", pf.Service.Name, pm.LocalPort)
+		r, err := listenRelay(name, pf.Service.BindAddress, pm.LocalPort, pf.metrics, func() (net.Conn, error) {
+			return pf.dialUpstream(idx)
+		})
+		if err != nil {
+			for _, opened := range relays {
+				opened.Close("arming failed")
+			}
+			return err
+		}
+		relays = append(relays, r)
+	}
+	pf.relays = relays
+	return nil
+}
+
+// dropConnectionsLocked closes relayed connections but keeps the local ports bound
+func (pf *PortForward) dropConnectionsLocked(reason string) {
+	for _, r := range pf.relays {
+		r.DropConnections(reason)
+	}
+}
+
+// closeRelaysLocked releases the local ports and drops relayed connections
+func (pf *PortForward) closeRelaysLocked(reason string) {
+	for _, r := range pf.relays {
+		r.Close(reason)
+	}
+	pf.relays = nil
+}
+
+// dialUpstream connects a relayed client to port mapping idx of the forward. An
+// armed forward is started by the first connection. While the forward starts or
+// reconnects the client is queued, for at most the ready timeout.
+func (pf *PortForward) dialUpstream(idx int) (net.Conn, error) {
+	deadline := time.Now().Add(pf.readyTimeout)
+	for {
+		pf.mu.Lock()
+		status, msg := pf.Status, pf.ErrorMessage
+		retrying := pf.retrying
+		port := 0
+		if status == StatusRunning && idx < len(pf.upstreamPorts) {
+			port = pf.upstreamPorts[idx]
+		}
+		pf.mu.Unlock()
+
+		switch {
+		case port != 0:
+			return net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), 5*time.Second)
+		case status == StatusArmed:
+			debugLog("%s: first connection, starting forward", pf.Service.Name)
+			if err := pf.Start(); err != nil {
+				// Another client may have started it first; a real failure shows as StatusError
+				debugLog("%s: start on connection: %v", pf.Service.Name, err)
+			}
+		case status == StatusStopped:
+			return nil, fmt.Errorf("forward stopped")
+		case status == StatusError && !retrying:
+			return nil, fmt.Errorf("forward failed: %s", msg)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("forward not ready within %s", pf.readyTimeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// localPorts returns the local port of every mapping
+func (pf *PortForward) localPorts() []int {
+	var ports []int
+	for _, pm := range pf.Service.PortMappings() {
+		ports = append(ports, pm.LocalPort)
+	}
+	return ports
 }
 
 // Start initiates the kubectl port-forward process
 func (pf *PortForward) Start() error {
+	pf.beginHooks()
+
 	pf.mu.Lock()
 	defer pf.mu.Unlock()
 
@@ -90,124 +267,339 @@ func (pf *PortForward) Start() error {
 		return fmt.Errorf("port forward already running")
 	}
 
-	pf.Status = StatusStarting
+	if pf.relays == nil {
+		// kubefwd owns the local ports and keeps them bound across reconnects
+		if err := pf.openRelaysLocked(); err != nil {
+			pf.setStatusLocked(StatusError, fmt.Sprintf("failed to start: %v", err))
+			pf.ErrorMessage = fmt.Sprintf("Failed to start: %v", err)
+			pf.hooksEndedLocked()
+			return err
+		}
+	}
+
+	newSession := !pf.retrying
+	if newSession {
+		pf.setStatusLocked(StatusStarting, "started")
+	} else {
+		pf.setStatusLocked(StatusStarting, "reconnecting")
+	}
 	pf.ErrorMessage = ""
 	pf.manualStop = false
+	if newSession {
+		pf.retryCount = 0 // A manual start begins a fresh retry budget
+	}
 	pf.retrying = false
+	pf.readyFailed = false
+	pf.portReady = make(map[int]bool)
 
 	// Create context for the command
 	ctx, cancel := context.WithCancel(context.Background())
 	pf.cancel = cancel
 
-	// Build kubectl command
-	portSpec := fmt.Sprintf("%d:%d", pf.Service.LocalPort, pf.Service.RemotePort)
-	serviceSpec := fmt.Sprintf("service/%s", pf.Service.ServiceName)
-
-	args := []string{
-		"--context=" + pf.context,
-		"-n", pf.namespace,
-		"port-forward",
-		serviceSpec,
-		portSpec,
+	// Build the forward spec: one session carries every port mapping. The relays own
+	// the local ports, so the forwarder listens on internal loopback ports instead.
+	spec := forwardSpec{
+		Context:   pf.context,
+		Namespace: pf.namespace,
+		Resource:  pf.Service.Resource(),
+		Selector:  pf.Service.Selector,
+		Address:   "127.0.0.1",
+	}
+	pf.upstreamPorts = nil
+	for _, pm := range pf.Service.PortMappings() {
+		port, err := pickFreePort()
+		if err != nil {
+			pf.setStatusLocked(StatusError, fmt.Sprintf("failed to start: %v", err))
+			pf.ErrorMessage = fmt.Sprintf("Failed to start: %v", err)
+			pf.closeRelaysLocked("forward failed to start")
+			pf.hooksEndedLocked()
+			cancel()
+			return err
+		}
+		pf.upstreamPorts = append(pf.upstreamPorts, port)
+		spec.Ports = append(spec.Ports, fmt.Sprintf("%d:%d", port, pm.RemotePort))
 	}
 
 	// Store the command string for debugging
-	pf.CommandString = fmt.Sprintf("kubectl %s", strings.Join(args, " "))
+	pf.CommandString = spec.commandString(pf.engine)
 	
 	debugLog("Executing: %s", pf.CommandString)
+	pf.logs.event("Executing: %s", pf.CommandString)
 
-	pf.cmd = exec.CommandContext(ctx, "kubectl", args...)
-	
-	// Capture stderr for error messages
+	// Capture stderr for error messages, stdout for the "Forwarding from" line;
+	// both go to the service log
 	var stderr strings.Builder
-	pf.cmd.Stderr = &stderr
+	watcher := newReadinessWatcher()
 
-	// Start the command
-	if err := pf.cmd.Start(); err != nil {
-		pf.Status = StatusError
+	// Start the forward
+	fwd, err := startForwarder(ctx, pf.engine, spec,
+		io.MultiWriter(watcher, pf.logs.writer(logStreamStdout)),
+		io.MultiWriter(&stderr, pf.logs.writer(logStreamStderr)))
+	if err != nil {
+		pf.logs.event("Failed to start: %v", err)
+		pf.setStatusLocked(StatusError, fmt.Sprintf("failed to start: %v", err))
 		pf.ErrorMessage = fmt.Sprintf("Failed to start: %v", err)
 		if stderr.Len() > 0 {
 			pf.ErrorMessage += fmt.Sprintf(" | stderr: %s", stderr.String())
 		}
+		pf.closeRelaysLocked("forward failed to start")
+		pf.hooksEndedLocked()
 		cancel()
 		return err
 	}
+	pf.fwd = fwd
+
+	if newSession {
+		pf.sessionStart = time.Now()
+		if pf.idleTimeout > 0 || pf.maxLifetime > 0 {
+			go pf.enforceLimits(pf.sessionStart)
+		}
+	}
 
-	// Monitor the process in a goroutine
-	go pf.monitor(&stderr)
+	// Monitor the forward and wait for it to become ready in the background.
+	// Status stays StatusStarting until awaitReady promotes it.
+	exited := make(chan struct{})
+	go pf.monitor(fwd, &stderr, exited)
+	go pf.awaitReady(ctx, fwd, watcher.Ready(), exited)
 
-	pf.Status = StatusRunning
-	pf.retryCount = 0 // Reset retry count on successful start
-	
-	// Start sql-tap if enabled
-	if pf.sqlTapManager.IsEnabled() {
-		// Unlock before starting sql-tap to avoid deadlock
+	return nil
+}
+
+// beginHooks runs pre_start before a new session; retries and failovers continue the
+// current one. Hook failures are only logged.
+func (pf *PortForward) beginHooks() {
+	if pf.hooks == nil {
+		return
+	}
+	pf.mu.Lock()
+	begin := !pf.hooksStarted && !pf.retrying && pf.Status != StatusRunning && pf.Status != StatusStarting
+	if begin {
+		pf.hooksStarted = true
+		pf.postStarted = false
+	}
+	pf.mu.Unlock()
+	if begin {
+		_ = pf.hooks.run(HookPreStart)
+	}
+}
+
+// hooksEndedLocked runs post_stop in the background when a session that ran pre_start
+// ended without Stop: it failed for good or was re-armed
+func (pf *PortForward) hooksEndedLocked() {
+	if pf.hooksStarted {
+		pf.hooksStarted = false
+		go pf.hooks.run(HookPostStop)
+	}
+}
+
+// awaitReady marks the forward as running once kubectl reported "Forwarding from" and
+// every internal forwarder port accepts connections, runs post_start once per session,
+// then starts sql-tap. A forward that is not ready within readyTimeout, whose post_start
+// hook fails with fail_on_post_start set, or whose sql-tap does not start is stopped with
+// an error and not retried.
+func (pf *PortForward) awaitReady(ctx context.Context, fwd forwarder, ready, exited <-chan struct{}) {
+	localPorts := pf.localPorts()
+	pf.mu.Lock()
+	checkPorts := pf.upstreamPorts // reported as the local port of the same mapping
+	pf.mu.Unlock()
+	err := waitForForwardReady(ctx, ready, exited, "127.0.0.1", checkPorts, pf.readyTimeout, func(port int) {
+		pf.mu.Lock()
+		if pf.fwd == fwd {
+			for i, p := range checkPorts {
+				if p == port {
+					pf.portReady[localPorts[i]] = true
+				}
+			}
+		}
+		pf.mu.Unlock()
+	})
+
+	pf.mu.Lock()
+	if pf.fwd != fwd || pf.Status != StatusStarting {
+		// Stopped or superseded by another attempt in the meantime
 		pf.mu.Unlock()
-		
-		// Wait briefly for port-forward to be ready
-		time.Sleep(2 * time.Second)
-		
-		// Start sql-tapd
+		return
+	}
+	if err != nil {
+		select {
+		case <-exited:
+			// monitor reports the exit and handles retries
+			pf.mu.Unlock()
+			return
+		default:
+		}
+		debugLog("%s: not ready after %s: %v", pf.Service.Name, pf.readyTimeout, err)
+		pf.setStatusLocked(StatusError, fmt.Sprintf("not ready after %s: %v", pf.readyTimeout, err))
+		pf.ErrorMessage = fmt.Sprintf("Not ready after %s: %v | Command: %s", pf.readyTimeout, err, pf.CommandString)
+		pf.readyFailed = true
+		pf.retrying = false
+		pf.notifyFailedLocked()
+		if pf.cancel != nil {
+			pf.cancel()
+			pf.cancel = nil
+		}
+		pf.closeRelaysLocked("forward not ready")
+		pf.hooksEndedLocked()
+		pf.mu.Unlock()
+		return
+	}
+
+	pf.setStatusLocked(StatusRunning, "ready")
+	notifications.ready(historyKindService, pf.Service.Name)
+	pf.runningSince = time.Now()
+	pf.retryCount = 0 // Reset retry count once the forward is actually usable
+	postStart := pf.hooksStarted && !pf.postStarted
+	if postStart {
+		pf.postStarted = true
+	}
+	pf.mu.Unlock()
+	debugLog("%s: ready on port %d", pf.Service.Name, pf.Service.LocalPort)
+
+	if postStart {
+		if err := pf.hooks.run(HookPostStart); err != nil && pf.hooks.failOnPostStart() {
+			pf.mu.Lock()
+			defer pf.mu.Unlock()
+			if pf.fwd != fwd || pf.Status != StatusRunning {
+				return
+			}
+			pf.setStatusLocked(StatusError, fmt.Sprintf("post_start hook failed: %v", err))
+			pf.ErrorMessage = fmt.Sprintf("Hook failed: %v", err)
+			pf.readyFailed = true
+			pf.retrying = false
+			if pf.cancel != nil {
+				pf.cancel()
+				pf.cancel = nil
+			}
+			pf.closeRelaysLocked("post_start hook failed")
+			pf.hooksEndedLocked()
+			return
+		}
+	}
+
+	// Start sql-tap if enabled (it survives forward restarts, so only start it once)
+	if pf.sqlTapManager.IsEnabled() && !pf.sqlTapManager.IsRunning() {
 		if err := pf.sqlTapManager.Start(); err != nil {
 			// If sql-tap fails, stop the port-forward
 			pf.mu.Lock()
+			defer pf.mu.Unlock()
+			if pf.fwd != fwd || pf.Status != StatusRunning {
+				return
+			}
 			debugLog("Failed to start sql-tapd for %s: %v", pf.Service.Name, err)
-			pf.Status = StatusError
+			pf.setStatusLocked(StatusError, fmt.Sprintf("sql-tap failed: %v", err))
 			pf.ErrorMessage = fmt.Sprintf("sql-tap failed: %v", err)
+			pf.readyFailed = true
+			pf.retrying = false
 			if pf.cancel != nil {
 				pf.cancel()
+				pf.cancel = nil
 			}
-			return err
+			pf.closeRelaysLocked("sql-tap failed")
+			pf.notifyFailedLocked()
+			pf.hooksEndedLocked()
 		}
-		
-		// Re-lock for the deferred unlock
-		pf.mu.Lock()
 	}
-	
-	return nil
 }
 
-// monitor watches the port-forward process and updates status
-func (pf *PortForward) monitor(stderr *strings.Builder) {
-	err := pf.cmd.Wait()
+// monitor watches the port-forward session and updates status. exited is closed as
+// soon as the session ends so a pending readiness check can give up.
+func (pf *PortForward) monitor(fwd forwarder, stderr *strings.Builder, exited chan struct{}) {
+	err := fwd.Wait()
+	close(exited)
+	if err != nil {
+		pf.logs.event("Exited: %v", err)
+	} else {
+		pf.logs.event("Exited")
+	}
 
 	pf.mu.Lock()
+
+	if pf.fwd != fwd || pf.readyFailed {
+		// Superseded by a newer attempt, or awaitReady already stopped it and reported why
+		pf.mu.Unlock()
+		return
+	}
 	
+	if err != nil && pf.Status != StatusStopped && !pf.manualStop && errors.Is(err, errTargetPodGone) {
+		// Selector target: re-point to another Ready pod right away, without backoff
+		debugLog("%s: %v, failing over", pf.Service.Name, err)
+		pf.setStatusLocked(StatusError, fmt.Sprintf("%v, failing over", err))
+		pf.ErrorMessage = fmt.Sprintf("%v, switching to another pod...", err)
+		pf.retrying = true // keep the retry budget untouched
+		pf.dropConnectionsLocked("target pod gone, switching to another pod")
+		pf.mu.Unlock()
+		if err := pf.Start(); err != nil {
+			pf.mu.Lock()
+			pf.setStatusLocked(StatusError, fmt.Sprintf("failover failed: %v", err))
+			pf.ErrorMessage = fmt.Sprintf("Failover failed: %v", err)
+			pf.mu.Unlock()
+		}
+		return
+	}
+
 	if err != nil && pf.Status != StatusStopped {
 		debugLog("EXIT: %v  cmd=%s", err, pf.CommandString)
+		if hint := classifyForwardError(stderr.String()); hint != "" && !pf.manualStop {
+			// Retrying cannot fix this; fail right away and say what to change
+			debugLog("%s: permanent failure, not retrying: %s", pf.Service.Name, hint)
+			pf.setStatusLocked(StatusError, "permanent failure: "+hint)
+			pf.retrying = false
+			pf.ErrorMessage = hint
+			if stderr.Len() > 0 {
+				pf.ErrorMessage += fmt.Sprintf(" | stderr: %s", strings.TrimSpace(stderr.String()))
+			}
+			pf.ErrorMessage += fmt.Sprintf(" | Command: %s", pf.CommandString)
+			pf.notifyFailedLocked()
+			pf.closeRelaysLocked("forward failed")
+			pf.hooksEndedLocked()
+			pf.mu.Unlock()
+			return
+		}
+
 		// Check if we should retry
 		shouldRetry := !pf.manualStop && (pf.maxRetries == -1 || pf.retryCount < pf.maxRetries)
 
 		if shouldRetry {
-			// Calculate exponential backoff delay: min(2^retryCount seconds, 60 seconds)
-			backoffSeconds := math.Min(math.Pow(2, float64(pf.retryCount)), 60)
+			delay := pf.retryPolicy.nextDelay(pf.retryCount)
 			pf.retryCount++
 			pf.retrying = true
-			pf.Status = StatusError // Temporarily set to error while waiting
-			pf.ErrorMessage = fmt.Sprintf("Connection lost, retrying in %.0fs (attempt %d", backoffSeconds, pf.retryCount)
+			pf.setStatusLocked(StatusError, fmt.Sprintf("connection lost: %v", err)) // Temporarily set to error while waiting
+			pf.ErrorMessage = fmt.Sprintf("Connection lost, retrying in %s (attempt %d", formatRetryDelay(delay), pf.retryCount)
 			if pf.maxRetries == -1 {
 				pf.ErrorMessage += ")..."
 			} else {
 				pf.ErrorMessage += fmt.Sprintf("/%d)...", pf.maxRetries)
 			}
 			
-			debugLog("%s: Retrying after %.0fs (attempt %d)", pf.Service.Name, backoffSeconds, pf.retryCount)
+			debugLog("%s: Retrying after %s (attempt %d)", pf.Service.Name, formatRetryDelay(delay), pf.retryCount)
+			pf.logs.event("Retrying after %s (attempt %d)", formatRetryDelay(delay), pf.retryCount)
+			// The local ports stay bound; new clients queue until the forward is back
+			pf.dropConnectionsLocked("connection to the cluster lost, reconnecting")
 			
 			pf.mu.Unlock()
 			
 			// Wait for backoff period
-			time.Sleep(time.Duration(backoffSeconds) * time.Second)
+			time.Sleep(delay)
+
+			pf.mu.Lock()
+			cancelled := pf.manualStop || !pf.retrying
+			pf.mu.Unlock()
+			if cancelled {
+				// Stopped (e.g. by a session limit) or started by hand during the backoff
+				return
+			}
 			
 			// Attempt to restart
 			if err := pf.Start(); err != nil {
 				pf.mu.Lock()
-				pf.Status = StatusError
+				pf.setStatusLocked(StatusError, fmt.Sprintf("retry failed: %v", err))
 				pf.ErrorMessage = fmt.Sprintf("Retry failed: %v", err)
+				pf.notifyFailedLocked()
 				pf.mu.Unlock()
 			}
 		} else {
 			// Max retries exceeded or manual stop
-			pf.Status = StatusError
+			pf.setStatusLocked(StatusError, fmt.Sprintf("exited: %v, not retrying", err))
 			pf.retrying = false
 			pf.ErrorMessage = fmt.Sprintf("Process exited: %v", err)
 			if stderr.Len() > 0 {
@@ -217,25 +609,51 @@ func (pf *PortForward) monitor(stderr *strings.Builder) {
 				pf.ErrorMessage += fmt.Sprintf(" | Failed after %d retries", pf.retryCount)
 			}
 			pf.ErrorMessage += fmt.Sprintf(" | Command: %s", pf.CommandString)
+			if !pf.manualStop {
+				pf.notifyFailedLocked()
+			}
+			pf.closeRelaysLocked("forward failed")
+			pf.hooksEndedLocked()
 			pf.mu.Unlock()
 		}
 	} else {
-		if pf.Status == StatusRunning {
-			pf.Status = StatusStopped
+		if pf.Status == StatusRunning || pf.Status == StatusStarting {
+			pf.setStatusLocked(StatusStopped, "forward ended")
+			pf.closeRelaysLocked("forward ended")
 		}
 		pf.mu.Unlock()
 	}
 }
 
-// Stop terminates the kubectl port-forward process
+// Stop terminates the kubectl port-forward process, including one waiting to retry,
+// and runs the pre_stop and post_stop hooks of a started session around it
 func (pf *PortForward) Stop() error {
 	pf.mu.Lock()
-	defer pf.mu.Unlock()
 
-	if pf.Status != StatusRunning && pf.Status != StatusStarting {
+	if pf.Status != StatusRunning && pf.Status != StatusStarting && pf.Status != StatusArmed && !pf.retrying {
+		pf.mu.Unlock()
 		return nil // Already stopped
 	}
+	if pf.hooksStarted && pf.hooks.has(HookPreStop) {
+		pf.mu.Unlock()
+		_ = pf.hooks.run(HookPreStop)
+		pf.mu.Lock()
+	}
+	pf.stopLocked("stopped")
+	pf.ErrorMessage = ""
+	postStop := pf.hooksStarted
+	pf.hooksStarted = false
+	pf.mu.Unlock()
+
+	if postStop {
+		_ = pf.hooks.run(HookPostStop)
+	}
+	return nil
+}
 
+// stopLocked ends the forward without retries; reason is logged for dropped
+// relayed connections. pf.mu is released while sql-tap shuts down.
+func (pf *PortForward) stopLocked(reason string) {
 	// Stop sql-tap first if enabled
 	if pf.sqlTapManager.IsEnabled() {
 		pf.mu.Unlock()
@@ -248,18 +666,109 @@ func (pf *PortForward) Stop() error {
 		pf.cancel = nil
 	}
 
-	pf.Status = StatusStopped
-	pf.ErrorMessage = ""
+	pf.closeRelaysLocked(reason)
+	pf.setStatusLocked(StatusStopped, reason)
 	pf.manualStop = true  // Prevent auto-retry
 	pf.retrying = false
-	return nil
 }
 
-// IsRunning returns true if the port forward is currently running
+// limitCheckInterval is how often enforceLimits checks a session
+var limitCheckInterval = 5 * time.Second
+
+// enforceLimits stops the session started at session once it has been idle for
+// idleTimeout or alive for maxLifetime. Lazy services are re-armed after an idle
+// stop. The stop is deliberate, so monitor does not retry it.
+func (pf *PortForward) enforceLimits(session time.Time) {
+	ticker := time.NewTicker(limitCheckInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		pf.mu.Lock()
+		if pf.sessionStart != session || pf.Status == StatusStopped || pf.Status == StatusArmed ||
+			(pf.Status == StatusError && !pf.retrying) {
+			// Stopped, failed for good, or replaced by a new session
+			pf.mu.Unlock()
+			return
+		}
+		reason, idle := sessionLimitReason(now, session, pf.idleSinceLocked(session), pf.idleTimeout, pf.maxLifetime)
+		if reason == "" {
+			pf.mu.Unlock()
+			continue
+		}
+		debugLog("%s: %s", pf.Service.Name, reason)
+		if pf.hooksStarted && pf.hooks.has(HookPreStop) {
+			pf.mu.Unlock()
+			_ = pf.hooks.run(HookPreStop)
+			pf.mu.Lock()
+			if pf.sessionStart != session || pf.Status == StatusStopped || pf.Status == StatusArmed {
+				pf.mu.Unlock()
+				return
+			}
+		}
+		if idle && pf.Service.Lazy {
+			pf.rearmLocked(reason)
+			pf.ErrorMessage = reason + ", armed for the next connection"
+		} else {
+			pf.stopLocked(reason)
+			pf.ErrorMessage = reason
+		}
+		pf.hooksEndedLocked()
+		pf.mu.Unlock()
+		return
+	}
+}
+
+// sessionLimitReason returns why a session has to end at now, if at all. idleSince
+// is zero while clients are connected. idle reports an idle timeout as opposed to
+// the maximum lifetime.
+func sessionLimitReason(now, started, idleSince time.Time, idleTimeout, maxLifetime time.Duration) (reason string, idle bool) {
+	if maxLifetime > 0 && now.Sub(started) >= maxLifetime {
+		return fmt.Sprintf("Stopped after reaching max lifetime of %s", maxLifetime), false
+	}
+	if idleTimeout > 0 && !idleSince.IsZero() && now.Sub(idleSince) >= idleTimeout {
+		return fmt.Sprintf("Stopped after %s without connections", idleTimeout), true
+	}
+	return "", false
+}
+
+// idleSinceLocked returns since when no client is connected, or the zero time while
+// one is. A session counts as active when it starts.
+func (pf *PortForward) idleSinceLocked(session time.Time) time.Time {
+	active, last := pf.metrics.Activity()
+	if active > 0 {
+		return time.Time{}
+	}
+	if last.After(session) {
+		return last
+	}
+	return session
+}
+
+// GetMetrics returns the relayed connection and traffic counters
+func (pf *PortForward) GetMetrics() metricsSnapshot {
+	return pf.metrics.Snapshot()
+}
+
+// rearmLocked ends the forward session but keeps the relays listening, so the next
+// connection starts a new one
+func (pf *PortForward) rearmLocked(reason string) {
+	debugLog("%s: re-arming: %s", pf.Service.Name, reason)
+	if pf.cancel != nil {
+		pf.cancel()
+		pf.cancel = nil
+	}
+	pf.fwd = nil // monitor treats the ended session as superseded
+	pf.upstreamPorts = nil
+	pf.setStatusLocked(StatusArmed, reason)
+	pf.retrying = false
+	pf.retryCount = 0
+}
+
+// IsRunning returns true if the port forward is currently running (or armed), or
+// waiting to retry; its relay keeps the local port bound during the backoff
 func (pf *PortForward) IsRunning() bool {
 	pf.mu.Lock()
 	defer pf.mu.Unlock()
-	return pf.Status == StatusRunning || pf.Status == StatusStarting
+	return pf.Status == StatusRunning || pf.Status == StatusStarting || pf.Status == StatusArmed || pf.retrying
 }
 
 // GetStatus returns the current status and error message
@@ -269,6 +778,17 @@ func (pf *PortForward) GetStatus() (PortForwardStatus, string) {
 	return pf.Status, pf.ErrorMessage
 }
 
+// GetUptime returns how long the forward has been running since it last became
+// ready, or 0 when it is not running
+func (pf *PortForward) GetUptime() time.Duration {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	if pf.Status != StatusRunning {
+		return 0
+	}
+	return time.Since(pf.runningSince)
+}
+
 // GetRetryInfo returns retry information for UI display
 func (pf *PortForward) GetRetryInfo() (retrying bool, attempt int, max int) {
 	pf.mu.Lock()
@@ -276,17 +796,49 @@ func (pf *PortForward) GetRetryInfo() (retrying bool, attempt int, max int) {
 	return pf.retrying, pf.retryCount, pf.maxRetries
 }
 
+// GetPortStatuses returns the status of each port mapping. All mappings share one
+// forward session, so they only differ while starting or after a readiness failure.
+func (pf *PortForward) GetPortStatuses() []PortMappingStatus {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	mappings := pf.Service.PortMappings()
+	out := make([]PortMappingStatus, len(mappings))
+	for i, pm := range mappings {
+		st := pf.Status
+		switch {
+		case pf.Status == StatusStarting && pf.portReady[pm.LocalPort]:
+			st = StatusRunning
+		case pf.Status == StatusError && pf.readyFailed && pf.portReady[pm.LocalPort]:
+			st = StatusStopped // this port was fine, another one missed the timeout
+		}
+		out[i] = PortMappingStatus{Name: pm.Name, LocalPort: pm.LocalPort, RemotePort: pm.RemotePort, Status: st}
+	}
+	return out
+}
+
+// GetPodName returns the pod a selector target is currently forwarding to
+func (pf *PortForward) GetPodName() string {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	if pn, ok := pf.fwd.(podNamer); ok {
+		return pn.PodName()
+	}
+	return ""
+}
+
 // GetSqlTapManager returns the sql-tap manager for this port forward
 func (pf *PortForward) GetSqlTapManager() *SqlTapManager {
 	return pf.sqlTapManager
 }
 
-// GetPID returns the process ID of the kubectl port-forward process
+// GetPID returns the process ID listening on the internal upstream port: the kubectl
+// port-forward, or kubefwd itself for the native engine. The local port is held by
+// kubefwd's relay. 0 while no forward runs.
 func (pf *PortForward) GetPID() int {
 	pf.mu.Lock()
 	defer pf.mu.Unlock()
-	if pf.cmd != nil && pf.cmd.Process != nil {
-		return pf.cmd.Process.Pid
+	if pf.fwd != nil {
+		return pf.fwd.PID()
 	}
 	return 0
 }
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionLimitReason(t *testing.T) {
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name      string
+		now       time.Time
+		idleSince time.Time
+		idle      time.Duration
+		life      time.Duration
+		wantIdle  bool
+		wantStop  bool
+	}{
+		{"no limits", start.Add(48 * time.Hour), start, 0, 0, false, false},
+		{"idle not reached", start.Add(9 * time.Minute), start, 10 * time.Minute, 0, false, false},
+		{"idle reached", start.Add(10 * time.Minute), start, 10 * time.Minute, 0, true, true},
+		{"clients connected", start.Add(2 * time.Hour), time.Time{}, 10 * time.Minute, 0, false, false},
+		{"idle since last disconnect", start.Add(15 * time.Minute), start.Add(8 * time.Minute), 10 * time.Minute, 0, false, false},
+		{"lifetime reached while busy", start.Add(8 * time.Hour), time.Time{}, 10 * time.Minute, 8 * time.Hour, false, true},
+		{"lifetime wins over idle", start.Add(8 * time.Hour), start, 10 * time.Minute, 8 * time.Hour, false, true},
+	}
+	for _, tc := range cases {
+		reason, idle := sessionLimitReason(tc.now, start, tc.idleSince, tc.idle, tc.life)
+		if (reason != "") != tc.wantStop || idle != tc.wantIdle {
+			t.Errorf("%s: reason %q idle %v", tc.name, reason, idle)
+		}
+	}
+}
+
+func TestIdleTimeoutOverride(t *testing.T) {
+	off := 0
+	svc := Service{IdleTimeout: &off}
+	if got := svc.GetIdleTimeout(30); got != 0 {
+		t.Fatalf("override to 0: %d", got)
+	}
+	if got := (&Service{}).GetMaxLifetime(8); got != 8 {
+		t.Fatalf("global max_lifetime: %d", got)
+	}
+	pf := NewPortForward(Service{Name: "db", ServiceName: "pg", RemotePort: 5432, LocalPort: 5432}, &Config{IdleTimeout: 30, MaxLifetime: 8})
+	if pf.idleTimeout != 30*time.Minute || pf.maxLifetime != 8*time.Hour {
+		t.Fatalf("limits: %s %s", pf.idleTimeout, pf.maxLifetime)
+	}
+}
+
+func TestDialUpstreamQueuesDuringReconnect(t *testing.T) {
+	upstream := echoServer(t)
+	_, portStr, _ := net.SplitHostPort(upstream.Addr().String())
+	upstreamPort, _ := strconv.Atoi(portStr)
+
+	pf := NewPortForward(Service{Name: "api", ServiceName: "api", RemotePort: 80, LocalPort: 8080}, &Config{ReadyTimeout: 5})
+	pf.Status = StatusError
+	pf.retrying = true
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		pf.mu.Lock()
+		pf.Status = StatusRunning
+		pf.retrying = false
+		pf.upstreamPorts = []int{upstreamPort}
+		pf.mu.Unlock()
+	}()
+	start := time.Now()
+	c, err := pf.dialUpstream(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if time.Since(start) < 150*time.Millisecond {
+		t.Fatal("connection was not queued until the forward was back")
+	}
+
+	// The queue is bounded by the ready timeout
+	pf.mu.Lock()
+	pf.Status = StatusError
+	pf.retrying = true
+	pf.readyTimeout = 100 * time.Millisecond
+	pf.mu.Unlock()
+	if _, err := pf.dialUpstream(0); err == nil {
+		t.Fatal("queued connection did not time out")
+	}
+}
+
+func TestStopAllStopsRetryingForwards(t *testing.T) {
+	cfg := &Config{Services: []Service{{Name: "api", ServiceName: "api", RemotePort: 80, LocalPort: 8080}}}
+	ApplyConfigDefaults(cfg)
+	wa := NewWebApp(cfg, &FileConfigStore{Path: filepath.Join(t.TempDir(), "kubefwd.yaml")})
+	pf := wa.portForwards[0]
+	pf.Status = StatusError
+	pf.retrying = true
+	if !pf.IsRunning() {
+		t.Fatal("a forward waiting to retry is not running")
+	}
+	wa.StopAll()
+	if pf.IsRunning() || pf.retrying || pf.Status != StatusStopped {
+		t.Fatalf("after StopAll: %s retrying=%v", pf.Status, pf.retrying)
+	}
+}
+
+// sessionForwarder is a forwarder whose session lasts until its context is cancelled
+type sessionForwarder struct{ ctx context.Context }
+
+func (f sessionForwarder) Wait() error {
+	<-f.ctx.Done()
+	return errors.New("signal: killed")
+}
+
+func (f sessionForwarder) PID() int { return 0 }
+
+func TestSqlTapFailureStopsForward(t *testing.T) {
+	stubSqlTapd(t, "exit 1")
+	upstream := echoServer(t)
+	_, portStr, _ := net.SplitHostPort(upstream.Addr().String())
+	upstreamPort, _ := strconv.Atoi(portStr)
+
+	tapPort := 5433
+	pf := NewPortForward(Service{Name: "db", ServiceName: "db", RemotePort: 5432, LocalPort: 5432,
+		SqlTapPort: &tapPort, SqlTapDriver: "postgres"}, &Config{ReadyTimeout: 5, MaxRetries: 3})
+	ctx, cancel := context.WithCancel(context.Background())
+	fwd := sessionForwarder{ctx}
+	pf.mu.Lock()
+	pf.Status = StatusStarting
+	pf.cancel = cancel
+	pf.fwd = fwd
+	pf.upstreamPorts = []int{upstreamPort}
+	pf.portReady = make(map[int]bool)
+	pf.mu.Unlock()
+
+	ready, exited := make(chan struct{}), make(chan struct{})
+	close(ready)
+	go pf.monitor(fwd, &strings.Builder{}, exited)
+	pf.awaitReady(ctx, fwd, ready, exited)
+	<-exited
+	time.Sleep(50 * time.Millisecond) // Let monitor handle the exit
+
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	if pf.Status != StatusError || pf.retrying || !pf.readyFailed || pf.cancel != nil {
+		t.Fatalf("after sql-tap failed: %s retrying=%v readyFailed=%v: %s", pf.Status, pf.retrying, pf.readyFailed, pf.ErrorMessage)
+	}
+}
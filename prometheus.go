@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// forwardStatuses are the values of the status label of the *_status gauges
+var forwardStatuses = []PortForwardStatus{StatusStopped, StatusStarting, StatusRunning, StatusError, StatusArmed}
+
+var proxyPodStatuses = []ProxyPodStatus{ProxyPodStatusNotCreated, ProxyPodStatusCreating, ProxyPodStatusReady, ProxyPodStatusError}
+
+// promLabel is one label pair of a sample
+type promLabel struct {
+	Name, Value string
+}
+
+// promWriter collects metrics in the Prometheus text exposition format. Samples are
+// grouped by metric, in the order each metric was first seen, and written by flush.
+type promWriter struct {
+	order    []string
+	families map[string]*promFamily
+}
+
+type promFamily struct {
+	typ, help string
+	lines     []string
+}
+
+func newPromWriter() *promWriter {
+	return &promWriter{families: make(map[string]*promFamily)}
+}
+
+func (p *promWriter) sample(name, typ, help string, value float64, labels ...promLabel) {
+	fam, ok := p.families[name]
+	if !ok {
+		fam = &promFamily{typ: typ, help: help}
+		p.families[name] = fam
+		p.order = append(p.order, name)
+	}
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=\"%s\"", l.Name, promEscape(l.Value))
+		}
+		b.WriteByte('}')
+	}
+	fmt.Fprintf(&b, " %g", value)
+	fam.lines = append(fam.lines, b.String())
+}
+
+// flush writes every metric with its HELP and TYPE lines
+func (p *promWriter) flush(w io.Writer) {
+	for _, name := range p.order {
+		fam := p.families[name]
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, fam.help, name, fam.typ)
+		for _, line := range fam.lines {
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// status adds one sample per known status, 1 for the current one and 0 for the rest
+func (p *promWriter) status(name, help, current string, all []string, labels ...promLabel) {
+	for _, st := range all {
+		v := 0.0
+		if st == current {
+			v = 1
+		}
+		p.sample(name, "gauge", help, v, append(labels[:len(labels):len(labels)], promLabel{"status", st})...)
+	}
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promEscape(s string) string {
+	return promEscaper.Replace(s)
+}
+
+func statusStrings[T ~string](statuses []T) []string {
+	out := make([]string, len(statuses))
+	for i, st := range statuses {
+		out[i] = string(st)
+	}
+	return out
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// handleMetrics exposes forward, proxy pod, sql-tap and kubectl health for Prometheus.
+func (wa *WebApp) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	wa.collectMetrics().flush(w)
+}
+
+// collectMetrics samples the state of every forward, proxy pod and kubectl counter
+func (wa *WebApp) collectMetrics() *promWriter {
+	wa.mu.RLock()
+	defer wa.mu.RUnlock()
+	p := newPromWriter()
+	fwdStatuses := statusStrings(forwardStatuses)
+
+	for _, pf := range wa.portForwards {
+		labels := []promLabel{{"service", pf.Service.Name}, {"context", pf.context}, {"namespace", pf.namespace}}
+		status, _ := pf.GetStatus()
+		retrying, attempt, _ := pf.GetRetryInfo()
+		m := pf.GetMetrics()
+		p.status("kubefwd_service_status", "Current status of the service forward.", string(status), fwdStatuses, labels...)
+		p.sample("kubefwd_service_retrying", "gauge", "Whether the service forward is waiting to reconnect.", boolFloat(retrying), labels...)
+		p.sample("kubefwd_service_retry_count", "gauge", "Reconnect attempts since the service forward was last ready.", float64(attempt), labels...)
+		p.sample("kubefwd_service_uptime_seconds", "gauge", "Seconds since the service forward last became ready (0 when not running).", pf.GetUptime().Seconds(), labels...)
+		p.sample("kubefwd_service_active_connections", "gauge", "Open client connections relayed to the service.", float64(m.ActiveConns), labels...)
+		p.sample("kubefwd_service_connections_total", "counter", "Client connections relayed to the service.", float64(m.TotalConns), labels...)
+		p.sample("kubefwd_service_connection_errors_total", "counter", "Clients that could not be connected to the service forward.", float64(m.ConnErrors), labels...)
+		p.sample("kubefwd_service_received_bytes_total", "counter", "Bytes received from the cluster for the service.", float64(m.BytesIn), labels...)
+		p.sample("kubefwd_service_sent_bytes_total", "counter", "Bytes sent to the cluster for the service.", float64(m.BytesOut), labels...)
+		if stm := pf.GetSqlTapManager(); stm.IsEnabled() {
+			st, _ := stm.GetStatus()
+			p.status("kubefwd_sqltap_status", "Current status of the sql-tapd process of a service or proxy service.", string(st), fwdStatuses, labels...)
+		}
+	}
+
+	for _, ps := range wa.config.ProxyServices {
+		labels := []promLabel{{"service", ps.Name}, {"context", ps.ProxyPodContext}, {"namespace", ps.ProxyPodNamespace}}
+		status := StatusStopped
+		pxf, active := wa.proxyForwards[ps.Name]
+		if active {
+			status, _ = pxf.GetStatus()
+		}
+		p.status("kubefwd_proxy_service_status", "Current status of the proxy service forward.", string(status), fwdStatuses, labels...)
+		if m, ok := wa.proxyMetrics[ps.Name]; ok {
+			snap := m.Snapshot()
+			p.sample("kubefwd_proxy_service_active_connections", "gauge", "Open client connections relayed to the proxy service.", float64(snap.ActiveConns), labels...)
+			p.sample("kubefwd_proxy_service_connections_total", "counter", "Client connections relayed to the proxy service.", float64(snap.TotalConns), labels...)
+			p.sample("kubefwd_proxy_service_connection_errors_total", "counter", "Clients that could not be connected to the proxy service forward.", float64(snap.ConnErrors), labels...)
+		}
+		if active && ps.SqlTapPort != nil {
+			st, _ := pxf.GetSqlTapManager().GetStatus()
+			p.status("kubefwd_sqltap_status", "Current status of the sql-tapd process of a service or proxy service.", string(st), fwdStatuses, labels...)
+		}
+	}
+
+	keys := make([]string, 0, len(wa.proxyPodManagers))
+	for key := range wa.proxyPodManagers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		mgr := wa.proxyPodManagers[key]
+		ctx, ns := splitGroupKey(key)
+		labels := []promLabel{{"context", ctx}, {"namespace", ns}}
+		st, _, count := mgr.GetStatus()
+		p.status("kubefwd_proxy_pod_status", "Current status of the proxy pod of a context and namespace.", string(st), statusStrings(proxyPodStatuses), labels...)
+		p.sample("kubefwd_proxy_pod_services", "gauge", "Proxy services routed through the proxy pod.", float64(count), labels...)
+		p.sample("kubefwd_proxy_pod_ready_seconds", "gauge", "Seconds the last successful proxy pod creation took until the pod was ready.", mgr.GetReadyDuration().Seconds(), labels...)
+	}
+
+	verbs, stats := getKubectlRunStats()
+	for i, verb := range verbs {
+		labels := []promLabel{{"command", verb}}
+		p.sample("kubefwd_kubectl_runs_total", "counter", "kubectl commands run by kubefwd, by subcommand (port-forwards excluded).", float64(stats[i].Runs), labels...)
+		p.sample("kubefwd_kubectl_errors_total", "counter", "kubectl commands that failed, by subcommand.", float64(stats[i].Errors), labels...)
+	}
+	return p
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPromWriterGroupsFamilies(t *testing.T) {
+	p := newPromWriter()
+	p.sample("a_total", "counter", "A.", 1, promLabel{"service", "api"})
+	p.sample("b", "gauge", "B.", 2.5)
+	p.sample("a_total", "counter", "A.", 3, promLabel{"service", `say "hi"\`})
+	var out strings.Builder
+	p.flush(&out)
+	want := `# HELP a_total A.
+# TYPE a_total counter
+a_total{service="api"} 1
+a_total{service="say \"hi\"\\"} 3
+# HELP b B.
+# TYPE b gauge
+b 2.5
+`
+	if out.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestPromWriterStatus(t *testing.T) {
+	p := newPromWriter()
+	p.status("s", "S.", "running", []string{"stopped", "running"}, promLabel{"service", "db"})
+	var out strings.Builder
+	p.flush(&out)
+	if !strings.Contains(out.String(), `s{service="db",status="stopped"} 0`) ||
+		!strings.Contains(out.String(), `s{service="db",status="running"} 1`) {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestKubectlVerb(t *testing.T) {
+	cases := map[string][]string{
+		"get":     {"--context=dev", "-n", "default", "get", "pods"},
+		"config":  {"config", "get-contexts"},
+		"delete":  {"--context", "dev", "delete", "pod", "x"},
+		"unknown": {"--context=dev"},
+	}
+	for want, args := range cases {
+		if got := kubectlVerb(args); got != want {
+			t.Errorf("kubectlVerb(%v) = %q, want %q", args, got, want)
+		}
+	}
+}
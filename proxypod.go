@@ -4,8 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
+	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -31,6 +35,11 @@ type ProxyPodManager struct {
 	podPorts        map[string]int    // Maps service name to unique pod port
 	status          ProxyPodStatus
 	errorMessage    string
+	readyDuration   time.Duration // How long the last successful creation took until the pod was ready
+	generation      int           // Bumped by every create and delete so a stale pod watcher exits
+	retryPolicy     RetryPolicy   // Backoff between attempts to recreate a lost pod
+	maxRetries      int           // Recreate attempts after the first (-1 for infinite)
+	onRecreated     func()        // Called after a lost pod was recreated with its services
 	mu              sync.Mutex
 }
 
@@ -58,6 +67,27 @@ func BuildPodName(baseName, podContext, podNamespace string) string {
 	return name
 }
 
+// proxyPodManagedBy marks the pods kubefwd creates
+const proxyPodManagedBy = "app.kubernetes.io/managed-by=kubefwd"
+
+// proxyPodLabel is the label set of the pods kubefwd creates. It names the machine
+// too, so the leftovers of a kubefwd that was killed can be found without touching
+// pods of other machines (see orphans.go).
+var proxyPodLabel = proxyPodManagedBy + ",kubefwd/host=" + proxyPodHost()
+
+// proxyPodHost returns the hostname as a label value
+func proxyPodHost() string {
+	host, _ := os.Hostname()
+	host = sanitizePodNameSegment(host)
+	if len(host) > 63 {
+		host = strings.TrimRight(host[:63], "-")
+	}
+	if host == "" {
+		return "unknown"
+	}
+	return host
+}
+
 // NewProxyPodManager creates a new proxy pod manager
 func NewProxyPodManager(podName, podImage, namespace, context string) *ProxyPodManager {
 	return &ProxyPodManager{
@@ -71,12 +101,43 @@ func NewProxyPodManager(podName, podImage, namespace, context string) *ProxyPodM
 	}
 }
 
-// CreatePodWithServices creates a single-container pod with all selected services
+// CreatePodWithServices creates a single-container pod with all selected services and
+// watches it, recreating it when it is evicted or deleted behind kubefwd's back
 func (pm *ProxyPodManager) CreatePodWithServices(selectedServices []ProxyService) error {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
-	pm.status = ProxyPodStatusCreating
+	pm.generation++
+	if err := pm.createLocked(selectedServices); err != nil {
+		return err
+	}
+	if len(selectedServices) > 0 {
+		go pm.watchPod(pm.generation)
+	}
+	return nil
+}
+
+// setStatusLocked changes the pod status and records the transition in the status
+// history, keyed by the pod's context and namespace
+func (pm *ProxyPodManager) setStatusLocked(status ProxyPodStatus, reason string) {
+	if status != pm.status {
+		history.record(historyKindProxyPod, proxyGroupKey(pm.context, pm.namespace), string(pm.status), string(status), reason)
+	}
+	pm.status = status
+}
+
+// notifyNotReadyLocked tells the notifiers that the pod could not be created or did
+// not become ready
+func (pm *ProxyPodManager) notifyNotReadyLocked() {
+	key := proxyGroupKey(pm.context, pm.namespace)
+	notifications.failure(EventProxyPodNotReady, historyKindProxyPod, key,
+		fmt.Sprintf("Proxy pod %s (%s) failed: %s", pm.podName, key, pm.errorMessage))
+}
+
+// createLocked (re)creates the pod with the given services (caller must hold lock)
+func (pm *ProxyPodManager) createLocked(selectedServices []ProxyService) error {
+	createStart := time.Now()
+	pm.setStatusLocked(ProxyPodStatusCreating, "creating")
 	pm.errorMessage = ""
 
 	// Delete old pod if it exists
@@ -84,7 +145,7 @@ func (pm *ProxyPodManager) CreatePodWithServices(selectedServices []ProxyService
 
 	if len(selectedServices) == 0 {
 		// No services selected, just ensure pod is deleted
-		pm.status = ProxyPodStatusNotCreated
+		pm.setStatusLocked(ProxyPodStatusNotCreated, "no services selected")
 		pm.currentServices = []ProxyService{}
 		pm.podPorts = make(map[string]int)
 		return nil
@@ -115,6 +176,7 @@ func (pm *ProxyPodManager) CreatePodWithServices(selectedServices []ProxyService
 		"--context="+pm.context,
 		"run", "-n", pm.namespace, pm.podName,
 		"--image="+pm.podImage,
+		"--labels="+proxyPodLabel,
 		"--restart=Never",
 		"--command", "--", "sh", "-c", shellCommand)
 
@@ -133,26 +195,30 @@ func (pm *ProxyPodManager) CreatePodWithServices(selectedServices []ProxyService
 				"--context="+pm.context,
 				"run", "-n", pm.namespace, pm.podName,
 				"--image="+pm.podImage,
+				"--labels="+proxyPodLabel,
 				"--restart=Never",
 				"--command", "--", "sh", "-c", shellCommand)
 
 			output, err = debugRunCmd(retryCmd)
 			if err != nil {
-				pm.status = ProxyPodStatusError
+				pm.setStatusLocked(ProxyPodStatusError, fmt.Sprintf("failed to create pod: %v", err))
 				pm.errorMessage = fmt.Sprintf("Failed to create pod (retry): %v | %s", err, string(output))
+				pm.notifyNotReadyLocked()
 				return fmt.Errorf("%s", pm.errorMessage)
 			}
 		} else {
-			pm.status = ProxyPodStatusError
+			pm.setStatusLocked(ProxyPodStatusError, fmt.Sprintf("failed to create pod: %v", err))
 			pm.errorMessage = fmt.Sprintf("Failed to create pod: %v | %s", err, string(output))
+			pm.notifyNotReadyLocked()
 			return fmt.Errorf("%s", pm.errorMessage)
 		}
 	}
 
 	// Wait for pod to be ready
 	if err := pm.waitForPodReady(60 * time.Second); err != nil {
-		pm.status = ProxyPodStatusError
+		pm.setStatusLocked(ProxyPodStatusError, fmt.Sprintf("pod not ready: %v", err))
 		pm.errorMessage = fmt.Sprintf("Pod failed to become ready: %v", err)
+		pm.notifyNotReadyLocked()
 		
 		// Get pod status for debugging
 		descCmd := exec.Command("kubectl",
@@ -172,12 +238,144 @@ func (pm *ProxyPodManager) CreatePodWithServices(selectedServices []ProxyService
 		return err
 	}
 
-	pm.status = ProxyPodStatusReady
+	pm.setStatusLocked(ProxyPodStatusReady, "ready")
+	notifications.ready(historyKindProxyPod, proxyGroupKey(pm.context, pm.namespace))
 	pm.currentServices = selectedServices
 	pm.errorMessage = ""
+	pm.readyDuration = time.Since(createStart)
 	return nil
 }
 
+// podWatchInterval is how often a ready proxy pod is checked for eviction or deletion
+var podWatchInterval = 10 * time.Second
+
+// podLost reports whether a pod in phase (as returned by podPhase) is gone for good
+// and has to be recreated
+func podLost(phase string) bool {
+	switch phase {
+	case "", "Failed", "Succeeded", "Terminating":
+		return true
+	}
+	return false
+}
+
+// watchPod recreates the pod created as generation gen, with the same services, when
+// it disappears. It exits once the pod is replaced or deleted through the manager, or
+// recreating it failed for good.
+func (pm *ProxyPodManager) watchPod(gen int) {
+	for {
+		time.Sleep(podWatchInterval)
+
+		pm.mu.Lock()
+		current := pm.generation == gen
+		pm.mu.Unlock()
+		if !current {
+			return
+		}
+
+		phase, err := pm.podPhase()
+		if err != nil {
+			// The cluster may just be unreachable; only a pod known to be gone is recreated
+			debugLog("proxy pod %s: watch: %v", pm.podName, err)
+			continue
+		}
+		if !podLost(phase) {
+			continue
+		}
+		if !pm.recreate(gen, phase) {
+			return
+		}
+	}
+}
+
+// recreate replaces the lost pod of generation gen, backing off between attempts
+// like a forward does. It returns false when it gave up or the pod was replaced or
+// deleted through the manager meanwhile.
+func (pm *ProxyPodManager) recreate(gen int, phase string) bool {
+	reason := fmt.Sprintf("has stopped (%s)", phase)
+	switch phase {
+	case "":
+		reason = "was deleted"
+	case "Terminating":
+		reason = "is being deleted"
+	}
+
+	pm.mu.Lock()
+	if pm.generation != gen {
+		pm.mu.Unlock()
+		return false
+	}
+	services := pm.currentServices
+	pm.setStatusLocked(ProxyPodStatusError, fmt.Sprintf("pod %s", reason))
+	pm.errorMessage = fmt.Sprintf("Pod %s, recreating...", reason)
+	pm.mu.Unlock()
+	debugLog("proxy pod %s %s, recreating with %d services", pm.podName, reason, len(services))
+
+	for attempt := 0; ; attempt++ {
+		pm.mu.Lock()
+		if pm.generation != gen {
+			pm.mu.Unlock()
+			return false
+		}
+		err := pm.createLocked(services)
+		if err == nil {
+			onRecreated := pm.onRecreated
+			pm.mu.Unlock()
+			debugLog("proxy pod %s recreated", pm.podName)
+			if onRecreated != nil {
+				onRecreated()
+			}
+			return true
+		}
+		if pm.maxRetries != -1 && attempt >= pm.maxRetries {
+			pm.errorMessage = fmt.Sprintf("Pod %s and could not be recreated: %v", reason, err)
+			if attempt > 0 {
+				pm.errorMessage += fmt.Sprintf(" | Failed after %d retries", attempt)
+			}
+			pm.mu.Unlock()
+			return false
+		}
+		delay := pm.retryPolicy.nextDelay(attempt)
+		pm.errorMessage = fmt.Sprintf("Pod %s, recreating failed, retrying in %s: %v", reason, formatRetryDelay(delay), err)
+		pm.mu.Unlock()
+		time.Sleep(delay)
+	}
+}
+
+// podPhase returns the phase of the proxy pod, "Terminating" while it is being
+// deleted, or "" when it does not exist
+func (pm *ProxyPodManager) podPhase() (string, error) {
+	cmd := exec.Command("kubectl",
+		"--context="+pm.context,
+		"-n", pm.namespace,
+		"get", "pod", pm.podName,
+		"-o", "json")
+
+	output, err := debugRunCmd(cmd)
+	if err != nil {
+		if strings.Contains(string(output), "NotFound") {
+			return "", nil
+		}
+		return "", fmt.Errorf("kubectl get pod failed: %v | %s", err, string(output))
+	}
+
+	var podData struct {
+		Metadata struct {
+			DeletionTimestamp string `json:"deletionTimestamp"`
+		} `json:"metadata"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(output, &podData); err != nil {
+		return "", fmt.Errorf("failed to parse pod JSON: %v", err)
+	}
+	if podData.Metadata.DeletionTimestamp != "" {
+		return "Terminating", nil
+	}
+	return podData.Status.Phase, nil
+}
+
 // checkPodExists checks if the proxy pod exists and is ready
 func (pm *ProxyPodManager) checkPodExists() (exists bool, ready bool, err error) {
 	cmd := exec.Command("kubectl",
@@ -304,12 +502,14 @@ func (pm *ProxyPodManager) DeletePod() error {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
+	pm.generation++ // The pod is meant to be gone; stop watching it
+
 	err := pm.deletePodUnsafe()
 	if err != nil {
 		return err
 	}
 
-	pm.status = ProxyPodStatusNotCreated
+	pm.setStatusLocked(ProxyPodStatusNotCreated, "deleted")
 	pm.currentServices = []ProxyService{}
 	pm.podPorts = make(map[string]int)
 	pm.errorMessage = ""
@@ -317,6 +517,20 @@ func (pm *ProxyPodManager) DeletePod() error {
 	return nil
 }
 
+// AdoptPod takes over the pod a previous kubefwd left running instead of recreating
+// it. services are the proxy services it relays, on the pod ports of podPorts.
+func (pm *ProxyPodManager) AdoptPod(services []ProxyService, podPorts map[string]int) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.generation++
+	pm.currentServices = services
+	pm.podPorts = podPorts
+	pm.errorMessage = ""
+	pm.setStatusLocked(ProxyPodStatusReady, "adopted")
+	go pm.watchPod(pm.generation)
+}
+
 // GetActiveServiceNames returns the names of services currently in the pod
 func (pm *ProxyPodManager) GetActiveServiceNames() []string {
 	pm.mu.Lock()
@@ -358,6 +572,14 @@ func (pm *ProxyPodManager) GetStatus() (ProxyPodStatus, string, int) {
 	return pm.status, pm.errorMessage, len(pm.currentServices)
 }
 
+// GetReadyDuration returns how long the last successful pod creation took until the
+// pod was ready (0 if it never was)
+func (pm *ProxyPodManager) GetReadyDuration() time.Duration {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	return pm.readyDuration
+}
+
 // ProxyForward manages a port-forward to the proxy pod
 type ProxyForward struct {
 	ProxyService  ProxyService
@@ -365,14 +587,29 @@ type ProxyForward struct {
 	Status        PortForwardStatus
 	ErrorMessage  string
 	CommandString string
-	cmd           *exec.Cmd
+	fwd           forwarder // Running forward session (kubectl process or native)
 	cancel        context.CancelFunc
 	mu            sync.Mutex
+	engine        string         // Forward engine: kubectl or native
+	readyFailed   bool           // Set when the forward was stopped for missing its ready timeout or failing its post_start hook
+	readyTimeout  time.Duration  // How long to wait for the local port to accept connections
+	relay         *portRelay     // Local listener owned by kubefwd while the forward is up
+	upstreamPort  int            // Internal forwarder port the relay connects to
+	metrics       *connMetrics   // Relayed connection and byte counters
+	logs          *logBuffer     // Forwarder output and lifecycle events
+	retryCount    int            // Current retry attempt number
+	maxRetries    int            // Maximum retry attempts (-1 for infinite, 0 to disable)
+	retryPolicy   RetryPolicy    // Backoff between retry attempts
+	manualStop    bool           // Flag to prevent retries when user stops manually
+	retrying      bool           // Indicates if currently in retry mode
+	hooks         *hookRunner    // Lifecycle hooks (nil when the proxy service has none)
+	hooksStarted  bool           // pre_start ran for the current session, so post_stop is due when it ends
+	postStarted   bool           // post_start ran for the current session
 	sqlTapManager *SqlTapManager // Manages sql-tapd process if enabled
 }
 
 // NewProxyForward creates a new proxy forward instance
-func NewProxyForward(proxyService ProxyService, podManager *ProxyPodManager) *ProxyForward {
+func NewProxyForward(proxyService ProxyService, podManager *ProxyPodManager, cfg *Config) *ProxyForward {
 	// Initialize sql-tap manager if configured
 	var sqlTapManager *SqlTapManager
 	if proxyService.SqlTapPort != nil {
@@ -385,27 +622,57 @@ func NewProxyForward(proxyService ProxyService, podManager *ProxyPodManager) *Pr
 			httpPort = *proxyService.SqlTapHttpPort
 		}
 		sqlTapManager = NewSqlTapManager(
+			proxyService.Name,
 			true,
 			proxyService.SqlTapDriver,
+			proxyService.BindAddress,
 			*proxyService.SqlTapPort,
 			proxyService.LocalPort,
 			grpcPort,
 			httpPort,
 		)
 	} else {
-		sqlTapManager = NewSqlTapManager(false, "", 0, 0, 0, 0)
+		sqlTapManager = NewSqlTapManager(proxyService.Name, false, "", "", 0, 0, 0, 0)
 	}
 
 	return &ProxyForward{
 		ProxyService:  proxyService,
 		PodManager:    podManager,
 		Status:        StatusStopped,
+		engine:        proxyService.GetForwardEngine(cfg.ForwardEngine),
+		readyTimeout:  time.Duration(proxyService.GetReadyTimeout(cfg.ReadyTimeout)) * time.Second,
+		metrics:       newConnMetrics(),
+		logs:          newLogBuffer(logSourceForward, serviceLogLines),
+		maxRetries:    proxyService.GetMaxRetries(cfg.MaxRetries),
+		retryPolicy:   proxyService.GetRetryPolicy(cfg.RetryPolicy),
+		hooks: newHookRunner(proxyService.Name, proxyService.Hooks, hookEnv(proxyService.Name, proxyService.BindAddress,
+			[]int{proxyService.LocalPort}, podManager.context, podManager.namespace)),
 		sqlTapManager: sqlTapManager,
 	}
 }
 
+// setStatusLocked changes the status and records the transition in the status history
+func (pf *ProxyForward) setStatusLocked(status PortForwardStatus, reason string) {
+	if status != pf.Status {
+		history.record(historyKindProxyService, pf.ProxyService.Name, string(pf.Status), string(status), reason)
+	}
+	pf.Status = status
+}
+
+// notifyFailedLocked tells the notifiers that the proxy forward failed and is not retried
+func (pf *ProxyForward) notifyFailedLocked() {
+	notifications.failure(EventRetriesExhausted, historyKindProxyService, pf.ProxyService.Name,
+		fmt.Sprintf("%s failed: %s", pf.ProxyService.Name, pf.ErrorMessage))
+}
+
 // Start initiates the proxy forward
 func (pf *ProxyForward) Start() error {
+	pf.beginHooks()
+
+	// Get the pod port for this service. This waits while the pod is being recreated,
+	// so it must not hold pf.mu.
+	podPort, exists := pf.PodManager.GetPodPort(pf.ProxyService.Name)
+
 	pf.mu.Lock()
 	defer pf.mu.Unlock()
 
@@ -413,113 +680,371 @@ func (pf *ProxyForward) Start() error {
 		return fmt.Errorf("proxy forward already running")
 	}
 
-	// Get the pod port for this service
-	podPort, exists := pf.PodManager.GetPodPort(pf.ProxyService.Name)
 	if !exists {
-		pf.Status = StatusError
+		pf.setStatusLocked(StatusError, "service not found in proxy pod")
 		pf.ErrorMessage = "Service not found in proxy pod"
+		pf.retrying = false
+		pf.closeRelayLocked("proxy forward failed to start")
+		pf.hooksEndedLocked()
 		return fmt.Errorf("%s", pf.ErrorMessage)
 	}
 
-	pf.Status = StatusStarting
+	// Like services, kubefwd owns the local port and relays to an internal one
+	if pf.relay == nil {
+		name := fmt.Sprintf("proxy %s :%d", pf.ProxyService.Name, pf.ProxyService.LocalPort)
+		relay, err := listenRelay(name, pf.ProxyService.BindAddress, pf.ProxyService.LocalPort, pf.metrics, pf.dialUpstream)
+		if err != nil {
+			pf.setStatusLocked(StatusError, fmt.Sprintf("failed to start: %v", err))
+			pf.ErrorMessage = fmt.Sprintf("Failed to start: %v", err)
+			pf.hooksEndedLocked()
+			return err
+		}
+		pf.relay = relay
+	}
+	upstreamPort, err := pickFreePort()
+	if err != nil {
+		pf.setStatusLocked(StatusError, fmt.Sprintf("failed to start: %v", err))
+		pf.ErrorMessage = fmt.Sprintf("Failed to start: %v", err)
+		pf.closeRelayLocked("proxy forward failed to start")
+		pf.hooksEndedLocked()
+		return err
+	}
+	pf.upstreamPort = upstreamPort
+
+	newSession := !pf.retrying
+	if newSession {
+		pf.setStatusLocked(StatusStarting, "started")
+		pf.retryCount = 0 // A manual start begins a fresh retry budget
+	} else {
+		pf.setStatusLocked(StatusStarting, "reconnecting")
+	}
 	pf.ErrorMessage = ""
+	pf.manualStop = false
+	pf.retrying = false
+	pf.readyFailed = false
 
 	// Create context for the command
 	ctx, cancel := context.WithCancel(context.Background())
 	pf.cancel = cancel
 
-	// Build kubectl port-forward command to the proxy pod
-	portSpec := fmt.Sprintf("%d:%d", pf.ProxyService.LocalPort, podPort)
-	podSpec := fmt.Sprintf("pod/%s", pf.PodManager.podName)
-
-	args := []string{
-		"--context=" + pf.PodManager.context,
-		"-n", pf.PodManager.namespace,
-		"port-forward",
-		podSpec,
-		portSpec,
+	// Build port-forward spec for the proxy pod
+	spec := forwardSpec{
+		Context:   pf.PodManager.context,
+		Namespace: pf.PodManager.namespace,
+		Resource:  fmt.Sprintf("pod/%s", pf.PodManager.podName),
+		Ports:     []string{fmt.Sprintf("%d:%d", upstreamPort, podPort)},
+		Address:   "127.0.0.1",
 	}
 
-	pf.CommandString = fmt.Sprintf("kubectl %s", strings.Join(args, " "))
+	pf.CommandString = spec.commandString(pf.engine)
 
 	debugLog("Executing proxy port-forward: %s", pf.CommandString)
-
-	pf.cmd = exec.CommandContext(ctx, "kubectl", args...)
+	pf.logs.event("Executing: %s", pf.CommandString)
 
 	var stderr strings.Builder
-	pf.cmd.Stderr = &stderr
+	watcher := newReadinessWatcher()
 
-	if err := pf.cmd.Start(); err != nil {
-		pf.Status = StatusError
+	fwd, err := startForwarder(ctx, pf.engine, spec,
+		io.MultiWriter(watcher, pf.logs.writer(logStreamStdout)),
+		io.MultiWriter(&stderr, pf.logs.writer(logStreamStderr)))
+	if err != nil {
+		pf.logs.event("Failed to start: %v", err)
+		pf.setStatusLocked(StatusError, fmt.Sprintf("failed to start: %v", err))
 		pf.ErrorMessage = fmt.Sprintf("Failed to start: %v", err)
 		if stderr.Len() > 0 {
 			pf.ErrorMessage += fmt.Sprintf(" | stderr: %s", stderr.String())
 		}
+		pf.closeRelayLocked("proxy forward failed to start")
+		pf.hooksEndedLocked()
 		cancel()
 		return err
 	}
+	pf.fwd = fwd
 
-	// Monitor the process in a goroutine
-	go pf.monitor(&stderr)
+	// Monitor the forward and wait for it to become ready in the background
+	exited := make(chan struct{})
+	go pf.monitor(fwd, &stderr, exited)
+	go pf.awaitReady(ctx, fwd, watcher.Ready(), exited)
 
-	pf.Status = StatusRunning
-	
-	// Start sql-tap if enabled
-	if pf.sqlTapManager.IsEnabled() {
-		// Unlock before starting sql-tap to avoid deadlock
+	return nil
+}
+
+// dialUpstream connects a relayed client to the forward, queueing it for at most
+// the ready timeout while the forward starts or reconnects
+func (pf *ProxyForward) dialUpstream() (net.Conn, error) {
+	deadline := time.Now().Add(pf.readyTimeout)
+	for {
+		pf.mu.Lock()
+		status, msg, port := pf.Status, pf.ErrorMessage, pf.upstreamPort
+		retrying := pf.retrying
 		pf.mu.Unlock()
-		
-		// Wait briefly for port-forward to be ready
-		time.Sleep(2 * time.Second)
-		
-		// Start sql-tapd
+
+		switch {
+		case status == StatusRunning:
+			return net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), 5*time.Second)
+		case status == StatusStopped:
+			return nil, fmt.Errorf("proxy forward stopped")
+		case status == StatusError && !retrying:
+			return nil, fmt.Errorf("proxy forward failed: %s", msg)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("proxy forward not ready within %s", pf.readyTimeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// closeRelayLocked releases the local port and drops relayed connections
+func (pf *ProxyForward) closeRelayLocked(reason string) {
+	if pf.relay != nil {
+		pf.relay.Close(reason)
+		pf.relay = nil
+	}
+}
+
+// GetMetrics returns the relayed connection and traffic counters
+func (pf *ProxyForward) GetMetrics() metricsSnapshot {
+	return pf.metrics.Snapshot()
+}
+
+// beginHooks runs pre_start before a new session; retries and reconnects continue the
+// current one. Hook failures are only logged.
+func (pf *ProxyForward) beginHooks() {
+	if pf.hooks == nil {
+		return
+	}
+	pf.mu.Lock()
+	begin := !pf.hooksStarted && !pf.retrying && pf.Status != StatusRunning && pf.Status != StatusStarting
+	if begin {
+		pf.hooksStarted = true
+		pf.postStarted = false
+	}
+	pf.mu.Unlock()
+	if begin {
+		_ = pf.hooks.run(HookPreStart)
+	}
+}
+
+// hooksEndedLocked runs post_stop in the background when a session that ran pre_start
+// failed for good
+func (pf *ProxyForward) hooksEndedLocked() {
+	if pf.hooksStarted {
+		pf.hooksStarted = false
+		go pf.hooks.run(HookPostStop)
+	}
+}
+
+// awaitReady marks the proxy forward as running once it accepts connections, runs
+// post_start once per session, then starts sql-tap. A forward that is not ready
+// within readyTimeout, or whose post_start hook fails with fail_on_post_start set,
+// is stopped.
+func (pf *ProxyForward) awaitReady(ctx context.Context, fwd forwarder, ready, exited <-chan struct{}) {
+	pf.mu.Lock()
+	upstreamPort := pf.upstreamPort
+	pf.mu.Unlock()
+	err := waitForForwardReady(ctx, ready, exited, "127.0.0.1", []int{upstreamPort}, pf.readyTimeout, nil)
+
+	pf.mu.Lock()
+	if pf.fwd != fwd || pf.Status != StatusStarting {
+		pf.mu.Unlock()
+		return
+	}
+	if err != nil {
+		select {
+		case <-exited:
+			// monitor reports the exit
+			pf.mu.Unlock()
+			return
+		default:
+		}
+		debugLog("proxy %s: not ready after %s: %v", pf.ProxyService.Name, pf.readyTimeout, err)
+		pf.setStatusLocked(StatusError, fmt.Sprintf("not ready after %s: %v", pf.readyTimeout, err))
+		pf.ErrorMessage = fmt.Sprintf("Not ready after %s: %v | Command: %s", pf.readyTimeout, err, pf.CommandString)
+		pf.readyFailed = true
+		pf.notifyFailedLocked()
+		if pf.cancel != nil {
+			pf.cancel()
+			pf.cancel = nil
+		}
+		pf.closeRelayLocked("proxy forward not ready")
+		pf.hooksEndedLocked()
+		pf.mu.Unlock()
+		return
+	}
+
+	pf.setStatusLocked(StatusRunning, "ready")
+	notifications.ready(historyKindProxyService, pf.ProxyService.Name)
+	pf.retryCount = 0 // Reset retry count once the forward is actually usable
+	postStart := pf.hooksStarted && !pf.postStarted
+	if postStart {
+		pf.postStarted = true
+	}
+	pf.mu.Unlock()
+	debugLog("proxy %s: ready on port %d", pf.ProxyService.Name, pf.ProxyService.LocalPort)
+
+	if postStart {
+		if err := pf.hooks.run(HookPostStart); err != nil && pf.hooks.failOnPostStart() {
+			pf.mu.Lock()
+			defer pf.mu.Unlock()
+			if pf.fwd != fwd || pf.Status != StatusRunning {
+				return
+			}
+			pf.setStatusLocked(StatusError, fmt.Sprintf("post_start hook failed: %v", err))
+			pf.ErrorMessage = fmt.Sprintf("Hook failed: %v", err)
+			pf.readyFailed = true
+			pf.retrying = false
+			if pf.cancel != nil {
+				pf.cancel()
+				pf.cancel = nil
+			}
+			pf.closeRelayLocked("post_start hook failed")
+			pf.hooksEndedLocked()
+			return
+		}
+	}
+
+	// Start sql-tap if enabled
+	if pf.sqlTapManager.IsEnabled() && !pf.sqlTapManager.IsRunning() {
 		if err := pf.sqlTapManager.Start(); err != nil {
 			// If sql-tap fails, stop the port-forward
 			pf.mu.Lock()
+			defer pf.mu.Unlock()
+			if pf.fwd != fwd || pf.Status != StatusRunning {
+				return
+			}
 			debugLog("Failed to start sql-tapd for proxy %s: %v", pf.ProxyService.Name, err)
-			pf.Status = StatusError
+			pf.setStatusLocked(StatusError, fmt.Sprintf("sql-tap failed: %v", err))
 			pf.ErrorMessage = fmt.Sprintf("sql-tap failed: %v", err)
 			if pf.cancel != nil {
 				pf.cancel()
 			}
-			return err
 		}
-		
-		// Re-lock for the deferred unlock
-		pf.mu.Lock()
 	}
-	
-	return nil
 }
 
-// monitor watches the proxy forward process and updates status
-func (pf *ProxyForward) monitor(stderr *strings.Builder) {
-	err := pf.cmd.Wait()
+// monitor watches the proxy forward session, retrying with backoff when it is lost.
+// exited is closed as soon as the session ends so a pending readiness check can give up.
+func (pf *ProxyForward) monitor(fwd forwarder, stderr *strings.Builder, exited chan struct{}) {
+	err := fwd.Wait()
+	close(exited)
+	if err != nil {
+		pf.logs.event("Exited: %v", err)
+	} else {
+		pf.logs.event("Exited")
+	}
 
 	pf.mu.Lock()
-	defer pf.mu.Unlock()
+
+	if pf.fwd != fwd || pf.readyFailed {
+		// Superseded by a newer attempt, or awaitReady already stopped it and reported why
+		pf.mu.Unlock()
+		return
+	}
 
 	if err != nil && pf.Status != StatusStopped {
-		pf.Status = StatusError
-		pf.ErrorMessage = fmt.Sprintf("Process exited: %v", err)
-		if stderr.Len() > 0 {
-			pf.ErrorMessage += fmt.Sprintf(" | stderr: %s", strings.TrimSpace(stderr.String()))
+		debugLog("proxy %s: EXIT: %v  cmd=%s", pf.ProxyService.Name, err, pf.CommandString)
+		if hint := classifyProxyForwardError(stderr.String()); hint != "" && !pf.manualStop {
+			// Retrying cannot fix this; fail right away and say what to change
+			debugLog("proxy %s: permanent failure, not retrying: %s", pf.ProxyService.Name, hint)
+			pf.setStatusLocked(StatusError, "permanent failure: "+hint)
+			pf.retrying = false
+			pf.ErrorMessage = hint
+			if stderr.Len() > 0 {
+				pf.ErrorMessage += fmt.Sprintf(" | stderr: %s", strings.TrimSpace(stderr.String()))
+			}
+			pf.ErrorMessage += fmt.Sprintf(" | Command: %s", pf.CommandString)
+			pf.notifyFailedLocked()
+			pf.closeRelayLocked("proxy forward failed")
+			pf.hooksEndedLocked()
+			pf.mu.Unlock()
+			return
+		}
+
+		shouldRetry := !pf.manualStop && (pf.maxRetries == -1 || pf.retryCount < pf.maxRetries)
+
+		if shouldRetry {
+			delay := pf.retryPolicy.nextDelay(pf.retryCount)
+			pf.retryCount++
+			pf.retrying = true
+			pf.setStatusLocked(StatusError, fmt.Sprintf("connection lost: %v", err)) // Temporarily set to error while waiting
+			pf.ErrorMessage = fmt.Sprintf("Connection lost, retrying in %s (attempt %d", formatRetryDelay(delay), pf.retryCount)
+			if pf.maxRetries == -1 {
+				pf.ErrorMessage += ")..."
+			} else {
+				pf.ErrorMessage += fmt.Sprintf("/%d)...", pf.maxRetries)
+			}
+
+			debugLog("proxy %s: Retrying after %s (attempt %d)", pf.ProxyService.Name, formatRetryDelay(delay), pf.retryCount)
+			pf.logs.event("Retrying after %s (attempt %d)", formatRetryDelay(delay), pf.retryCount)
+			// The local port stays bound; new clients queue until the forward is back
+			if pf.relay != nil {
+				pf.relay.DropConnections("connection to the proxy pod lost, reconnecting")
+			}
+
+			pf.mu.Unlock()
+
+			time.Sleep(delay)
+
+			pf.mu.Lock()
+			cancelled := pf.manualStop || !pf.retrying || pf.fwd != fwd
+			pf.mu.Unlock()
+			if cancelled {
+				// Stopped, or restarted by hand or by a pod recreation during the backoff
+				return
+			}
+
+			if err := pf.Start(); err != nil {
+				pf.mu.Lock()
+				pf.setStatusLocked(StatusError, fmt.Sprintf("retry failed: %v", err))
+				pf.retrying = false
+				pf.ErrorMessage = fmt.Sprintf("Retry failed: %v", err)
+				pf.notifyFailedLocked()
+				pf.mu.Unlock()
+			}
+		} else {
+			// Max retries exceeded or manual stop
+			pf.setStatusLocked(StatusError, fmt.Sprintf("exited: %v, not retrying", err))
+			pf.retrying = false
+			pf.ErrorMessage = fmt.Sprintf("Process exited: %v", err)
+			if stderr.Len() > 0 {
+				pf.ErrorMessage += fmt.Sprintf(" | stderr: %s", strings.TrimSpace(stderr.String()))
+			}
+			if pf.retryCount > 0 {
+				pf.ErrorMessage += fmt.Sprintf(" | Failed after %d retries", pf.retryCount)
+			}
+			pf.ErrorMessage += fmt.Sprintf(" | Command: %s", pf.CommandString)
+			if !pf.manualStop {
+				pf.notifyFailedLocked()
+			}
+			pf.closeRelayLocked("proxy forward failed")
+			pf.hooksEndedLocked()
+			pf.mu.Unlock()
 		}
 	} else {
-		if pf.Status == StatusRunning {
-			pf.Status = StatusStopped
+		if pf.Status == StatusRunning || pf.Status == StatusStarting {
+			pf.setStatusLocked(StatusStopped, "forward ended")
+			pf.closeRelayLocked("proxy forward ended")
 		}
+		pf.mu.Unlock()
 	}
 }
 
-// Stop terminates the proxy forward
+// Stop terminates the proxy forward, including one waiting to retry, and runs the
+// pre_stop and post_stop hooks of a started session around it
 func (pf *ProxyForward) Stop() error {
 	pf.mu.Lock()
-	defer pf.mu.Unlock()
 
-	if pf.Status != StatusRunning && pf.Status != StatusStarting {
+	if pf.Status != StatusRunning && pf.Status != StatusStarting && !pf.retrying {
+		pf.mu.Unlock()
 		return nil
 	}
+	if pf.hooksStarted && pf.hooks.has(HookPreStop) {
+		pf.mu.Unlock()
+		_ = pf.hooks.run(HookPreStop)
+		pf.mu.Lock()
+	}
+	pf.manualStop = true // Prevent auto-retry
+	pf.retrying = false
 
 	// Stop sql-tap first if enabled
 	if pf.sqlTapManager.IsEnabled() {
@@ -533,16 +1058,61 @@ func (pf *ProxyForward) Stop() error {
 		pf.cancel = nil
 	}
 
-	pf.Status = StatusStopped
+	pf.closeRelayLocked("stopped")
+	pf.setStatusLocked(StatusStopped, "stopped")
 	pf.ErrorMessage = ""
+	postStop := pf.hooksStarted
+	pf.hooksStarted = false
+	pf.mu.Unlock()
+
+	if postStop {
+		_ = pf.hooks.run(HookPostStop)
+	}
 	return nil
 }
 
-// IsRunning returns true if the proxy forward is currently running
+// Reconnect restarts the forward against a recreated proxy pod with a fresh retry
+// budget, also when its retries ran out while the pod was gone. A forward stopped
+// by hand stays stopped.
+func (pf *ProxyForward) Reconnect() error {
+	pf.mu.Lock()
+	if pf.manualStop {
+		pf.mu.Unlock()
+		return nil
+	}
+	if pf.cancel != nil {
+		pf.cancel()
+		pf.cancel = nil
+	}
+	pf.fwd = nil // The old session's monitor and any pending backoff see it was superseded
+	pf.setStatusLocked(StatusError, "proxy pod recreated")
+	pf.ErrorMessage = "Proxy pod recreated, reconnecting..."
+	pf.retrying = true // Clients queue meanwhile
+	pf.retryCount = 0
+	if pf.relay != nil {
+		pf.relay.DropConnections("proxy pod recreated, reconnecting")
+	}
+	// A forward whose retries ran out already ran post_stop; reviving it starts a new hook session
+	revived := pf.hooks != nil && !pf.hooksStarted
+	if revived {
+		pf.hooksStarted = true
+		pf.postStarted = false
+	}
+	pf.mu.Unlock()
+
+	debugLog("proxy %s: reconnecting to the recreated pod", pf.ProxyService.Name)
+	pf.logs.event("Proxy pod recreated, reconnecting")
+	if revived {
+		_ = pf.hooks.run(HookPreStart)
+	}
+	return pf.Start()
+}
+
+// IsRunning returns true if the proxy forward is currently running or waiting to retry
 func (pf *ProxyForward) IsRunning() bool {
 	pf.mu.Lock()
 	defer pf.mu.Unlock()
-	return pf.Status == StatusRunning || pf.Status == StatusStarting
+	return pf.Status == StatusRunning || pf.Status == StatusStarting || pf.retrying
 }
 
 // GetStatus returns the current status and error message
@@ -557,12 +1127,20 @@ func (pf *ProxyForward) GetSqlTapManager() *SqlTapManager {
 	return pf.sqlTapManager
 }
 
-// GetPID returns the process ID of the kubectl port-forward process
+// GetRetryInfo returns retry information for UI display
+func (pf *ProxyForward) GetRetryInfo() (retrying bool, attempt int, max int) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	return pf.retrying, pf.retryCount, pf.maxRetries
+}
+
+// GetPID returns the process ID listening on the internal upstream port (see
+// PortForward.GetPID)
 func (pf *ProxyForward) GetPID() int {
 	pf.mu.Lock()
 	defer pf.mu.Unlock()
-	if pf.cmd != nil && pf.cmd.Process != nil {
-		return pf.cmd.Process.Pid
+	if pf.fwd != nil {
+		return pf.fwd.PID()
 	}
 	return 0
 }
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestPodLost(t *testing.T) {
+	for phase, want := range map[string]bool{
+		"":            true,
+		"Failed":      true,
+		"Succeeded":   true,
+		"Terminating": true,
+		"Running":     false,
+		"Pending":     false,
+		"Unknown":     false,
+	} {
+		if got := podLost(phase); got != want {
+			t.Errorf("podLost(%q) = %v, want %v", phase, got, want)
+		}
+	}
+}
+
+func TestProxyForwardStopWhileRetrying(t *testing.T) {
+	pm := NewProxyPodManager("kubefwd-proxy", "alpine/socat", "default", "dev")
+	pf := NewProxyForward(ProxyService{Name: "db", LocalPort: 5432}, pm, &Config{MaxRetries: -1})
+	pf.Status = StatusError
+	pf.retrying = true
+	pf.retryCount = 3
+
+	if err := pf.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	retrying, _, _ := pf.GetRetryInfo()
+	if status, _ := pf.GetStatus(); status != StatusStopped || retrying || !pf.manualStop {
+		t.Fatalf("after stop: status=%s retrying=%v manualStop=%v", status, retrying, pf.manualStop)
+	}
+	// A recreated pod must not revive a forward stopped by hand
+	if err := pf.Reconnect(); err != nil || pf.Status != StatusStopped {
+		t.Fatalf("reconnect revived stopped forward: %v %s", err, pf.Status)
+	}
+}
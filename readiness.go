@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// forwardReadyMarker is printed by kubectl (and client-go) once the local listener is bound
+const forwardReadyMarker = "Forwarding from"
+
+// readinessWatcher is used as the forwarder's stdout. It closes Ready() the first
+// time a line containing forwardReadyMarker is written.
+type readinessWatcher struct {
+	mu      sync.Mutex
+	partial []byte
+	ready   chan struct{}
+	seen    bool
+}
+
+func newReadinessWatcher() *readinessWatcher {
+	return &readinessWatcher{ready: make(chan struct{})}
+}
+
+func (rw *readinessWatcher) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.seen {
+		return len(p), nil
+	}
+	rw.partial = append(rw.partial, p...)
+	for {
+		idx := bytes.IndexByte(rw.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		line := string(rw.partial[:idx])
+		rw.partial = rw.partial[idx+1:]
+		if strings.Contains(line, forwardReadyMarker) {
+			rw.seen = true
+			rw.partial = nil
+			close(rw.ready)
+			break
+		}
+	}
+	return len(p), nil
+}
+
+// Ready is closed once the forwarder reported that it is listening
+func (rw *readinessWatcher) Ready() <-chan struct{} {
+	return rw.ready
+}
+
+// waitForForwardReady blocks until the forwarder has printed its ready marker and
+// every local port accepts TCP connections on host. onReady (if set) is called for each port
+// as soon as it accepts. It fails when the forward exits first (exited is closed),
+// ctx is cancelled, or timeout elapses.
+func waitForForwardReady(ctx context.Context, marker <-chan struct{}, exited <-chan struct{}, host string, ports []int, timeout time.Duration, onReady func(port int)) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	select {
+	case <-marker:
+	case <-exited:
+		return fmt.Errorf("port-forward exited before it was ready")
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-deadline.C:
+		return fmt.Errorf("no %q output within %s", forwardReadyMarker, timeout)
+	}
+
+	pending := append([]int(nil), ports...)
+	var lastErr error
+	for {
+		remaining := pending[:0]
+		for _, port := range pending {
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), 500*time.Millisecond)
+			if err != nil {
+				lastErr = err
+				remaining = append(remaining, port)
+				continue
+			}
+			conn.Close()
+			if onReady != nil {
+				onReady(port)
+			}
+		}
+		pending = remaining
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-exited:
+			return fmt.Errorf("port-forward exited before it was ready")
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return fmt.Errorf("local port %s not accepting connections within %s: %v", joinPorts(pending), timeout, lastErr)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func joinPorts(ports []int) string {
+	parts := make([]string, len(ports))
+	for i, p := range ports {
+		parts[i] = strconv.Itoa(p)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReadinessWatcherSplitWrites(t *testing.T) {
+	rw := newReadinessWatcher()
+	rw.Write([]byte("Forwarding fr"))
+	select {
+	case <-rw.Ready():
+		t.Fatal("ready before the line was complete")
+	default:
+	}
+	rw.Write([]byte("om 127.0.0.1:8080 -> 80\nForwarding from [::1]:8080 -> 80\n"))
+	select {
+	case <-rw.Ready():
+	default:
+		t.Fatal("not ready after marker line")
+	}
+	// Further writes must not panic on the closed channel
+	rw.Write([]byte("Forwarding from 127.0.0.1:8080 -> 80\n"))
+}
+
+func TestWaitForForwardReady(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	marker := make(chan struct{})
+	exited := make(chan struct{})
+
+	// Listening alone is not enough without the marker
+	if err := waitForForwardReady(context.Background(), marker, exited, "localhost", []int{port}, 200*time.Millisecond, nil); err == nil {
+		t.Fatal("expected timeout without ready marker")
+	}
+
+	close(marker)
+	var readyPorts []int
+	if err := waitForForwardReady(context.Background(), marker, exited, "localhost", []int{port}, 2*time.Second, func(p int) { readyPorts = append(readyPorts, p) }); err != nil {
+		t.Fatalf("expected ready: %v", err)
+	}
+	if len(readyPorts) != 1 || readyPorts[0] != port {
+		t.Fatalf("onReady calls: %v", readyPorts)
+	}
+
+	close(exited)
+	if err := waitForForwardReady(context.Background(), make(chan struct{}), exited, "localhost", []int{port}, 2*time.Second, nil); err == nil {
+		t.Fatal("expected error when forward exited")
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// portRelay owns the local listener of one port mapping and pipes every accepted
+// connection to an upstream connection obtained from dial. dial may block, e.g.
+// while a lazy forward is started on the first connection or while the forward
+// reconnects; the client is queued meanwhile. The listener stays bound across
+// forward restarts, so the local port never changes hands.
+type portRelay struct {
+	name      string // "service :port" for logs
+	listeners []net.Listener
+	dial      func() (net.Conn, error)
+	metrics   *connMetrics // Shared by the relays of one forward
+
+	mu     sync.Mutex
+	conns  map[net.Conn]net.Conn // Client -> upstream connection (nil while queued)
+	closed bool
+}
+
+// relayListenAddresses returns the addresses a relay for bindAddress listens on.
+// Like kubectl, the default binds both loopback addresses.
+func relayListenAddresses(bindAddress string, port int) []string {
+	p := strconv.Itoa(port)
+	if bindAddress == "" || bindAddress == "localhost" {
+		return []string{net.JoinHostPort("127.0.0.1", p), net.JoinHostPort("::1", p)}
+	}
+	return []string{net.JoinHostPort(bindAddress, p)}
+}
+
+// listenRelay binds the local port and starts accepting connections. For the
+// default bind address the IPv6 loopback is optional, as it may be unavailable.
+func listenRelay(name, bindAddress string, port int, metrics *connMetrics, dial func() (net.Conn, error)) (*portRelay, error) {
+	r := &portRelay{name: name, dial: dial, metrics: metrics, conns: make(map[net.Conn]net.Conn)}
+	for i, addr := range relayListenAddresses(bindAddress, port) {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			if i > 0 {
+				debugLog("relay %s: skipping %s: %v", name, addr, err)
+				continue
+			}
+			return nil, fmt.Errorf("listen on %s: %w", addr, err)
+		}
+		r.listeners = append(r.listeners, l)
+	}
+	for _, l := range r.listeners {
+		go r.accept(l)
+	}
+	return r, nil
+}
+
+func (r *portRelay) accept(l net.Listener) {
+	for {
+		client, err := l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				debugLog("relay %s: accept: %v", r.name, err)
+			}
+			return
+		}
+		if !r.track(client, nil) {
+			client.Close()
+			return
+		}
+		go r.handle(client)
+	}
+}
+
+// track registers a client connection, or pairs it with its upstream connection;
+// false once the relay is closed
+func (r *portRelay) track(client, upstream net.Conn) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+	r.conns[client] = upstream
+	return true
+}
+
+func (r *portRelay) untrack(client net.Conn) {
+	r.mu.Lock()
+	delete(r.conns, client)
+	r.mu.Unlock()
+}
+
+func (r *portRelay) handle(client net.Conn) {
+	defer r.untrack(client)
+	defer client.Close()
+	r.metrics.connOpened()
+	defer r.metrics.connClosed()
+
+	upstream, err := r.dial()
+	if err != nil {
+		r.metrics.connFailed()
+		debugLog("relay %s: dropping connection from %s: %v", r.name, client.RemoteAddr(), err)
+		return
+	}
+	if !r.track(client, upstream) {
+		upstream.Close()
+		return
+	}
+	defer upstream.Close()
+
+	done := make(chan struct{}, 2)
+	pipe := func(dst, src net.Conn, count func(n int64)) {
+		_, _ = io.Copy(countingWriter{dst, count}, src)
+		// Propagate EOF so half-closed protocols finish cleanly
+		if tc, ok := dst.(*net.TCPConn); ok {
+			_ = tc.CloseWrite()
+		}
+		done <- struct{}{}
+	}
+	go pipe(upstream, client, func(n int64) { r.metrics.addBytes(0, n) })
+	go pipe(client, upstream, func(n int64) { r.metrics.addBytes(n, 0) })
+	<-done
+	<-done
+}
+
+// countingWriter reports every successful write to count
+type countingWriter struct {
+	w     io.Writer
+	count func(n int64)
+}
+
+func (cw countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	if n > 0 {
+		cw.count(int64(n))
+	}
+	return n, err
+}
+
+// Close stops accepting and closes every relayed connection
+func (r *portRelay) Close(reason string) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	r.mu.Unlock()
+
+	for _, l := range r.listeners {
+		l.Close()
+	}
+	r.closeConns(reason, true)
+}
+
+// DropConnections closes every relayed connection but keeps listening, e.g. when
+// the forward behind the relay is lost and reconnects. Queued clients stay queued.
+func (r *portRelay) DropConnections(reason string) {
+	r.closeConns(reason, false)
+}
+
+func (r *portRelay) closeConns(reason string, queued bool) {
+	r.mu.Lock()
+	var clients, upstreams []net.Conn
+	for client, upstream := range r.conns {
+		if upstream == nil && !queued {
+			continue
+		}
+		clients = append(clients, client)
+		if upstream != nil {
+			upstreams = append(upstreams, upstream)
+		}
+	}
+	r.mu.Unlock()
+
+	if len(clients) == 0 {
+		return
+	}
+	debugLog("relay %s: closing %d connections: %s", r.name, len(clients), reason)
+	for _, c := range upstreams {
+		c.Close()
+	}
+	for _, c := range clients {
+		c.Close()
+	}
+}
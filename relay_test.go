@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func echoServer(t *testing.T) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				_, _ = io.Copy(c, c)
+			}()
+		}
+	}()
+	return l
+}
+
+func TestPortRelay(t *testing.T) {
+	upstream := echoServer(t)
+	port, err := pickFreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := newConnMetrics()
+	r, err := listenRelay("test", "127.0.0.1", port, metrics, func() (net.Conn, error) {
+		return net.Dial("tcp", upstream.Addr().String())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := c.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(c).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("echo: %q %v", line, err)
+	}
+	// Bytes are counted right after the write, so allow the relay a moment
+	deadline := time.Now().Add(2 * time.Second)
+	for m := metrics.Snapshot(); m.ActiveConns != 1 || m.TotalConns != 1 || m.BytesIn != 5 || m.BytesOut != 5; m = metrics.Snapshot() {
+		if time.Now().After(deadline) {
+			t.Fatalf("metrics: %+v", m)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	r.DropConnections("reconnecting")
+	if _, err := c.Read(make([]byte, 1)); err == nil {
+		t.Fatal("relayed connection still open after DropConnections")
+	}
+	c, err = net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("relay stopped listening after DropConnections: %v", err)
+	}
+	defer c.Close()
+	_ = c.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := c.Write([]byte("pong\n")); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := bufio.NewReader(c).ReadString('\n'); err != nil || line != "pong\n" {
+		t.Fatalf("echo after drop: %q %v", line, err)
+	}
+
+	r.Close("test done")
+	if _, err := c.Read(make([]byte, 1)); err == nil {
+		t.Fatal("relayed connection still open after Close")
+	}
+	if _, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), time.Second); err == nil {
+		t.Fatal("relay still listening after Close")
+	}
+}
+
+func TestArmHoldsLocalPort(t *testing.T) {
+	port, err := pickFreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf := NewPortForward(Service{Name: "lazy", ServiceName: "svc", RemotePort: 80, LocalPort: port, BindAddress: "127.0.0.1", Lazy: true}, &Config{})
+	if err := pf.StartOrArm(); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := pf.GetStatus(); status != StatusArmed {
+		t.Fatalf("status %q", status)
+	}
+	if err := pf.Arm(); err == nil {
+		t.Fatal("arming twice succeeded")
+	}
+	if l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port))); err == nil {
+		l.Close()
+		t.Fatal("armed port is not held")
+	}
+
+	if err := pf.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := pf.GetStatus(); status != StatusStopped {
+		t.Fatalf("status after stop %q", status)
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("port not released: %v", err)
+	}
+	l.Close()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Delay returns how long to wait before retry number attempt (0 for the first).
+// rnd returns a value in [0, 1) and picks the jitter.
+func (p RetryPolicy) Delay(attempt int, rnd func() float64) time.Duration {
+	secs := math.Min(p.InitialDelay*math.Pow(p.Multiplier, float64(attempt)), p.MaxDelay)
+	if p.Jitter > 0 {
+		secs *= 1 + p.Jitter*(2*rnd()-1)
+	}
+	return time.Duration(secs * float64(time.Second))
+}
+
+// nextDelay is Delay with the process-wide random source
+func (p RetryPolicy) nextDelay(attempt int) time.Duration {
+	return p.Delay(attempt, rand.Float64)
+}
+
+// formatRetryDelay renders a backoff delay for status messages, e.g. "1.5s" or "1m0s"
+func formatRetryDelay(d time.Duration) string {
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// forwardFailure is a forward error that retrying cannot fix
+type forwardFailure struct {
+	pattern *regexp.Regexp
+	hint    string // What the user has to change, shown instead of the retry countdown
+}
+
+// permanentFailures are matched against the output of a failed forward. Everything
+// else (lost connections, timeouts, pods restarting) counts as transient.
+var permanentFailures = []forwardFailure{
+	{regexp.MustCompile(`context "[^"]*" does not exist|no context exists with the name`),
+		"Unknown kubeconfig context; check context/cluster_context or run 'kubectl config get-contexts'"},
+	{regexp.MustCompile(`(?i)\(Unauthorized\)|error: You must be logged in|\bUnauthorized\b`),
+		"Not authenticated; refresh your cluster credentials (e.g. 'gcloud auth login') and start again"},
+	{regexp.MustCompile(`(?i)\(Forbidden\)|\bis forbidden\b`),
+		"Access denied; your account lacks RBAC permission to port-forward in this namespace"},
+	{regexp.MustCompile(`namespaces "[^"]*" not found`),
+		"Namespace not found; check namespace"},
+	{regexp.MustCompile(`(services|pods|deployments(\.apps)?|statefulsets(\.apps)?) "[^"]*" not found`),
+		"Target not found; check service_name, kind and namespace"},
+	{regexp.MustCompile(`does not have (a service port|a port named)|Service .* does not have a service port`),
+		"Port not found on the target; check remote_port"},
+}
+
+// classifyForwardError returns a hint when output shows a failure retrying cannot
+// fix, or "" for transient failures that should be retried with backoff
+func classifyForwardError(output string) string {
+	output = strings.TrimSpace(output)
+	for _, f := range permanentFailures {
+		if f.pattern.MatchString(output) {
+			return f.hint
+		}
+	}
+	return ""
+}
+
+// proxyPodNotFound matches a proxy forward whose pod is gone
+var proxyPodNotFound = regexp.MustCompile(`pods "[^"]*" not found`)
+
+// classifyProxyForwardError is classifyForwardError for proxy forwards. The proxy pod
+// is kubefwd's own and is recreated when it disappears, so a missing pod is transient.
+func classifyProxyForwardError(output string) string {
+	if proxyPodNotFound.MatchString(output) {
+		return ""
+	}
+	return classifyForwardError(output)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{InitialDelay: 1, MaxDelay: 60, Multiplier: 2}
+	mid := func() float64 { return 0.5 }
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		if got := p.Delay(attempt, mid); got != want {
+			t.Errorf("attempt %d: %s, want %s", attempt, got, want)
+		}
+	}
+	if got := p.Delay(10, mid); got != time.Minute {
+		t.Errorf("capped delay: %s", got)
+	}
+
+	p.Jitter = 0.5
+	if got := p.Delay(2, func() float64 { return 0 }); got != 2*time.Second {
+		t.Errorf("lowest jitter: %s", got)
+	}
+	if got := p.Delay(2, func() float64 { return 0.75 }); got != 5*time.Second {
+		t.Errorf("jittered delay: %s", got)
+	}
+}
+
+func TestRetryPolicyMerge(t *testing.T) {
+	global := RetryPolicy{InitialDelay: 1, MaxDelay: 60, Multiplier: 2}
+	svc := Service{RetryPolicy: &RetryPolicy{MaxDelay: 5, Jitter: 0.1}}
+	if got := svc.GetRetryPolicy(global); got != (RetryPolicy{InitialDelay: 1, MaxDelay: 5, Multiplier: 2, Jitter: 0.1}) {
+		t.Fatalf("merged policy: %+v", got)
+	}
+	if got := (&Service{}).GetRetryPolicy(global); got != global {
+		t.Fatalf("inherited policy: %+v", got)
+	}
+}
+
+func TestClassifyForwardError(t *testing.T) {
+	permanent := []string{
+		`Error from server (NotFound): services "api" not found`,
+		`error: context "gke_old" does not exist`,
+		`Error from server (Forbidden): pods "api-0" is forbidden: User "me" cannot create resource "pods/portforward"`,
+		`error: You must be logged in to the server (Unauthorized)`,
+		`error: statefulsets.apps "db" not found`,
+		`Error from server (NotFound): namespaces "staging" not found`,
+	}
+	for _, out := range permanent {
+		if classifyForwardError(out) == "" {
+			t.Errorf("expected permanent: %s", out)
+		}
+	}
+	transient := []string{
+		"",
+		"error: lost connection to pod",
+		`E0101 portforward.go:413] an error occurred forwarding 8080 -> 80: error forwarding port 80 to pod: connection refused`,
+		"error: timed out waiting for the condition",
+	}
+	for _, out := range transient {
+		if hint := classifyForwardError(out); hint != "" {
+			t.Errorf("expected transient: %s (got %q)", out, hint)
+		}
+	}
+}
+
+func TestClassifyProxyForwardError(t *testing.T) {
+	// The proxy pod is recreated by kubefwd, so losing it is worth retrying
+	if hint := classifyProxyForwardError(`Error from server (NotFound): pods "kubefwd-proxy-dev-default" not found`); hint != "" {
+		t.Fatalf("missing proxy pod classified as permanent: %q", hint)
+	}
+	if classifyProxyForwardError(`error: context "gke_old" does not exist`) == "" {
+		t.Fatal("unknown context should stay permanent for proxy forwards")
+	}
+}
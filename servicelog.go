@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// serviceLogLines is how many output lines each forward and sql-tapd process keeps
+const serviceLogLines = 1000
+
+// Log line sources and streams
+const (
+	logSourceForward = "forward"
+	logSourceSqlTap  = "sql-tap"
+
+	logStreamStdout  = "stdout"
+	logStreamStderr  = "stderr"
+	logStreamKubefwd = "kubefwd" // Lifecycle events written by kubefwd itself
+)
+
+// logSeq orders lines across all buffers, so output of a forward and its sql-tapd
+// can be merged
+var logSeq atomic.Uint64
+
+// logLine is one captured output line
+type logLine struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"` // forward or sql-tap
+	Stream string    `json:"stream"` // stdout, stderr or kubefwd
+	Text   string    `json:"text"`
+}
+
+// logBuffer is a bounded ring buffer of output lines. Followers get new lines as
+// they are added.
+type logBuffer struct {
+	source string
+	size   int
+	mu     sync.Mutex
+	lines  []logLine // Oldest at next once the buffer is full
+	next   int
+	subs   map[chan logLine]struct{}
+}
+
+func newLogBuffer(source string, size int) *logBuffer {
+	return &logBuffer{source: source, size: size, subs: make(map[chan logLine]struct{})}
+}
+
+// add records a line. Followers that fall behind miss lines rather than block the
+// process writing them.
+func (b *logBuffer) add(stream, text string) {
+	line := logLine{Seq: logSeq.Add(1), Time: time.Now(), Source: b.source, Stream: stream, Text: text}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.lines) < b.size {
+		b.lines = append(b.lines, line)
+	} else {
+		b.lines[b.next] = line
+		b.next = (b.next + 1) % b.size
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+// event records a lifecycle event of kubefwd, e.g. the command being started
+func (b *logBuffer) event(format string, args ...interface{}) {
+	b.add(logStreamKubefwd, fmt.Sprintf(format, args...))
+}
+
+// linesLocked returns the buffered lines, oldest first
+func (b *logBuffer) linesLocked() []logLine {
+	out := make([]logLine, 0, len(b.lines))
+	out = append(out, b.lines[b.next:]...)
+	return append(out, b.lines[:b.next]...)
+}
+
+// Lines returns the buffered lines, oldest first
+func (b *logBuffer) Lines() []logLine {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.linesLocked()
+}
+
+// Follow returns the buffered lines and a channel receiving every line added after
+// them. stop unsubscribes.
+func (b *logBuffer) Follow() (lines []logLine, ch <-chan logLine, stop func()) {
+	c := make(chan logLine, 256)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[c] = struct{}{}
+	return b.linesLocked(), c, func() {
+		b.mu.Lock()
+		delete(b.subs, c)
+		b.mu.Unlock()
+	}
+}
+
+// writer returns an io.Writer that records each line written to it on stream
+func (b *logBuffer) writer(stream string) io.Writer {
+	return &lineWriter{emit: func(line string) { b.add(stream, line) }}
+}
+
+// mergeLogLines merges the lines of several buffers in the order they were added
+func mergeLogLines(sets ...[]logLine) []logLine {
+	var out []logLine
+	for _, s := range sets {
+		out = append(out, s...)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	return out
+}
+
+// serviceLogs are the output buffers of one service: its forward and its sql-tapd
+type serviceLogs struct {
+	forward *logBuffer
+	sqlTap  *logBuffer
+}
+
+// lineWriter splits written output into lines and passes each to emit. A single
+// lineWriter must not be written to concurrently.
+type lineWriter struct {
+	emit func(line string)
+	buf  []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(strings.TrimRight(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush emits output left after the last newline
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.emit(string(w.buf))
+		w.buf = nil
+	}
+}
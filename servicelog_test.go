@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+func lineTexts(lines []logLine) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = l.Text
+	}
+	return out
+}
+
+func TestLogBufferWrapsAround(t *testing.T) {
+	b := newLogBuffer(logSourceForward, 3)
+	for i := 1; i <= 5; i++ {
+		b.add(logStreamStdout, fmt.Sprintf("line %d", i))
+	}
+	got := lineTexts(b.Lines())
+	if fmt.Sprint(got) != "[line 3 line 4 line 5]" {
+		t.Fatalf("lines after wraparound: %q", got)
+	}
+	if l := b.Lines()[0]; l.Source != logSourceForward || l.Stream != logStreamStdout {
+		t.Fatalf("line fields: %+v", l)
+	}
+}
+
+func TestLogBufferWriterSplitsLines(t *testing.T) {
+	b := newLogBuffer(logSourceForward, 10)
+	w := b.writer(logStreamStderr)
+	io.WriteString(w, "Handling connection")
+	io.WriteString(w, " for 5432\r\nerror: lost connection\nunfinished")
+	got := lineTexts(b.Lines())
+	if fmt.Sprint(got) != "[Handling connection for 5432 error: lost connection]" {
+		t.Fatalf("split lines: %q", got)
+	}
+	w.(*lineWriter).flush()
+	if got := b.Lines(); len(got) != 3 || got[2].Text != "unfinished" || got[2].Stream != logStreamStderr {
+		t.Fatalf("after flush: %+v", got)
+	}
+}
+
+func TestLogBufferFollowAndMerge(t *testing.T) {
+	fwd := newLogBuffer(logSourceForward, 10)
+	tap := newLogBuffer(logSourceSqlTap, 10)
+	fwd.event("Executing: kubectl port-forward")
+	tap.add(logStreamStderr, "listening")
+
+	lines, ch, stop := fwd.Follow()
+	if len(lines) != 1 {
+		t.Fatalf("follow backlog: %+v", lines)
+	}
+	fwd.add(logStreamStdout, "Forwarding from 127.0.0.1:5432")
+	if l := <-ch; l.Text != "Forwarding from 127.0.0.1:5432" {
+		t.Fatalf("followed line: %+v", l)
+	}
+	stop()
+	fwd.add(logStreamStdout, "after stop") // Must not block without a follower
+	select {
+	case l := <-ch:
+		t.Fatalf("line after stop: %+v", l)
+	default:
+	}
+
+	merged := lineTexts(mergeLogLines(fwd.Lines(), tap.Lines()))
+	want := "[Executing: kubectl port-forward listening Forwarding from 127.0.0.1:5432 after stop]"
+	if fmt.Sprint(merged) != want {
+		t.Fatalf("merged: %q", merged)
+	}
+}
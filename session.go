@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// sessionSaveInterval is how often the running forwards are compared with the saved
+// session
+var sessionSaveInterval = 2 * time.Second
+
+// session is what was running, saved so it can be brought back after a restart
+type session struct {
+	ClusterContext string    `json:"cluster_context"`
+	ClusterName    string    `json:"cluster_name,omitempty"`
+	Services       []string  `json:"services,omitempty"`       // Started services (running, starting or retrying)
+	Armed          []string  `json:"armed,omitempty"`          // Armed lazy services
+	ProxyServices  []string  `json:"proxy_services,omitempty"` // Active proxy forwards
+	RestoreOnStart bool      `json:"restore_on_start"`         // Restore on startup even without --restore
+	SavedAt        time.Time `json:"saved_at"`
+}
+
+// sameForwards reports whether s and o have the same context and forwards
+func (s session) sameForwards(o session) bool {
+	return s.ClusterContext == o.ClusterContext && s.ClusterName == o.ClusterName &&
+		slices.Equal(s.Services, o.Services) && slices.Equal(s.Armed, o.Armed) &&
+		slices.Equal(s.ProxyServices, o.ProxyServices)
+}
+
+// sessionStore persists the session. Both config stores implement it: the YAML store
+// in a file next to the config, the SQLite store in its database.
+type sessionStore interface {
+	SaveSession(session) error
+	LoadSession() (*session, error) // nil without a saved session
+}
+
+// applySessionContext switches cfg to the cluster context of s. A context that is
+// neither cluster_context nor one of the alternative contexts is ignored.
+func applySessionContext(cfg *Config, s *session) {
+	if s.ClusterContext == "" || s.ClusterContext == cfg.ClusterContext {
+		return
+	}
+	for _, ac := range cfg.AlternativeContexts {
+		if ac.Context == s.ClusterContext {
+			cfg.ClusterContext = ac.Context
+			cfg.ClusterName = ac.Name
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Warning: context %s of the saved session is not configured, using %s\n", s.ClusterContext, cfg.ClusterContext)
+}
+
+// useSession makes s the saved session the next saves start from
+func (wa *WebApp) useSession(s *session) {
+	wa.sessionMu.Lock()
+	defer wa.sessionMu.Unlock()
+	if s != nil {
+		wa.session = *s
+	}
+}
+
+// RestoreSession starts the forwards of s in dependency order and returns once they
+// are ready or were given up on. Forwards that are no longer configured are skipped.
+func (wa *WebApp) RestoreSession(s session) {
+	known := make(map[string]bool)
+	wa.mu.RLock()
+	for _, svc := range wa.config.Services {
+		known[svc.Name] = true
+	}
+	for _, ps := range wa.config.ProxyServices {
+		known[ps.Name] = true
+	}
+	wa.mu.RUnlock()
+	filter := func(names []string) []string {
+		var out []string
+		for _, n := range names {
+			if known[n] {
+				out = append(out, n)
+			} else {
+				debugLog("session: %s is no longer configured, not restored", n)
+			}
+		}
+		return out
+	}
+
+	started := append(filter(s.Services), filter(s.ProxyServices)...)
+	armed := filter(s.Armed)
+	debugLog("session: restoring %d forwards and %d armed services", len(started), len(armed))
+	if len(started) > 0 {
+		wa.startWithDependencies(started, false)
+	}
+	if len(armed) > 0 {
+		wa.startWithDependencies(armed, true)
+	}
+}
+
+// sessionSnapshot returns the context and forwards that are up now
+func (wa *WebApp) sessionSnapshot() session {
+	wa.mu.RLock()
+	defer wa.mu.RUnlock()
+	s := session{ClusterContext: wa.config.ClusterContext, ClusterName: wa.config.ClusterName}
+	for _, pf := range wa.portForwards {
+		st, _ := pf.GetStatus()
+		retrying, _, _ := pf.GetRetryInfo()
+		switch {
+		case st == StatusArmed:
+			s.Armed = append(s.Armed, pf.Service.Name)
+		case st == StatusRunning || st == StatusStarting || retrying:
+			s.Services = append(s.Services, pf.Service.Name)
+		}
+	}
+	for _, ps := range wa.config.ProxyServices {
+		if _, active := wa.proxyForwards[ps.Name]; active {
+			s.ProxyServices = append(s.ProxyServices, ps.Name)
+		}
+	}
+	return s
+}
+
+// saveSessionLoop saves the session whenever the running forwards change, until ctx
+// is done. What was saved before stays until something is started or stopped, so a
+// start without --restore does not lose it.
+func (wa *WebApp) saveSessionLoop(ctx context.Context) {
+	if _, ok := wa.store.(sessionStore); !ok {
+		return
+	}
+	last := wa.sessionSnapshot()
+	ticker := time.NewTicker(sessionSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current := wa.sessionSnapshot()
+		if current.sameForwards(last) {
+			continue
+		}
+		last = current
+		wa.saveSession(func(s *session) {
+			s.ClusterContext, s.ClusterName = current.ClusterContext, current.ClusterName
+			s.Services, s.Armed, s.ProxyServices = current.Services, current.Armed, current.ProxyServices
+		})
+	}
+}
+
+// saveSession applies change to the saved session and writes it. Nothing is written
+// once Shutdown began stopping the forwards.
+func (wa *WebApp) saveSession(change func(*session)) error {
+	store, ok := wa.store.(sessionStore)
+	if !ok {
+		return fmt.Errorf("%s cannot save sessions", wa.store.Description())
+	}
+	wa.sessionMu.Lock()
+	defer wa.sessionMu.Unlock()
+	if wa.sessionClosed {
+		return nil
+	}
+	change(&wa.session)
+	wa.session.SavedAt = time.Now()
+	if err := store.SaveSession(wa.session); err != nil {
+		debugLog("session: cannot save: %v", err)
+		return err
+	}
+	return nil
+}
+
+// closeSession stops saving the session, so stopping everything on shutdown does not
+// overwrite it
+func (wa *WebApp) closeSession() {
+	wa.sessionMu.Lock()
+	defer wa.sessionMu.Unlock()
+	wa.sessionClosed = true
+}
+
+// savedSession returns the session as last saved
+func (wa *WebApp) savedSession() session {
+	wa.sessionMu.Lock()
+	defer wa.sessionMu.Unlock()
+	return wa.session
+}
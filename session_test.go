@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileSessionRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store := &FileConfigStore{Path: filepath.Join(dir, ".kubefwd.yaml")}
+	if got := store.sessionPath(); got != filepath.Join(dir, ".kubefwd.session.json") {
+		t.Fatalf("session path: %s", got)
+	}
+	if s, err := store.LoadSession(); s != nil || err != nil {
+		t.Fatalf("no session yet: %+v %v", s, err)
+	}
+
+	want := session{ClusterContext: "staging", ClusterName: "Staging", Services: []string{"API"},
+		Armed: []string{"Metrics"}, ProxyServices: []string{"CloudSQL"}, RestoreOnStart: true}
+	if err := store.SaveSession(want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.LoadSession()
+	if err != nil || got == nil {
+		t.Fatalf("load: %+v %v", got, err)
+	}
+	if !got.SavedAt.Equal(want.SavedAt) || !reflect.DeepEqual(*got, want) {
+		t.Fatalf("loaded session: %+v", *got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestApplySessionContext(t *testing.T) {
+	cfg := &Config{ClusterContext: "prod", ClusterName: "Production",
+		AlternativeContexts: []AlternativeContext{{Name: "Staging", Context: "staging"}}}
+	applySessionContext(cfg, &session{ClusterContext: "gone"})
+	if cfg.ClusterContext != "prod" {
+		t.Fatalf("unknown context applied: %s", cfg.ClusterContext)
+	}
+	applySessionContext(cfg, &session{ClusterContext: "staging"})
+	if cfg.ClusterContext != "staging" || cfg.ClusterName != "Staging" {
+		t.Fatalf("alternative context not applied: %s %s", cfg.ClusterContext, cfg.ClusterName)
+	}
+}
+
+func TestSessionSnapshotAndSave(t *testing.T) {
+	store := &FileConfigStore{Path: filepath.Join(t.TempDir(), "kubefwd.yaml")}
+	cfg := &Config{ClusterContext: "prod", Namespace: "default",
+		Services: []Service{
+			{Name: "API", ServiceName: "api", RemotePort: 80, LocalPort: 8080},
+			{Name: "Metrics", ServiceName: "prometheus", RemotePort: 9090, LocalPort: 9090, Lazy: true},
+			{Name: "Idle", ServiceName: "idle", RemotePort: 80, LocalPort: 8081},
+		}}
+	ApplyConfigDefaults(cfg)
+	wa := NewWebApp(cfg, store)
+	wa.useSession(&session{RestoreOnStart: true, Services: []string{"Old"}})
+
+	wa.portForwards[0].Status = StatusRunning
+	wa.portForwards[1].Status = StatusArmed
+	snap := wa.sessionSnapshot()
+	if snap.ClusterContext != "prod" || !reflect.DeepEqual(snap.Services, []string{"API"}) || !reflect.DeepEqual(snap.Armed, []string{"Metrics"}) {
+		t.Fatalf("snapshot: %+v", snap)
+	}
+
+	if err := wa.saveSession(func(s *session) {
+		s.ClusterContext, s.Services, s.Armed = snap.ClusterContext, snap.Services, snap.Armed
+	}); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := store.LoadSession()
+	if err != nil || saved == nil || !saved.RestoreOnStart || !saved.sameForwards(snap) {
+		t.Fatalf("saved session: %+v %v", saved, err)
+	}
+
+	// Stopping everything on shutdown must not overwrite the session
+	wa.closeSession()
+	if err := wa.saveSession(func(s *session) { s.Services, s.Armed = nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := store.LoadSession(); len(saved.Services) != 1 {
+		t.Fatalf("session saved after shutdown: %+v", saved)
+	}
+}
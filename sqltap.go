@@ -3,8 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -12,8 +15,10 @@ import (
 
 // SqlTapManager manages a single sql-tapd process
 type SqlTapManager struct {
+	name         string // Service name, used in the status history
 	enabled      bool
 	driver       string
+	bindAddress  string // bind_address of the forward ("" = previous defaults: listen on all, upstream via localhost)
 	listenPort   int
 	upstreamPort int
 	grpcPort     int // gRPC port for TUI client connection
@@ -22,22 +27,40 @@ type SqlTapManager struct {
 	cancel       context.CancelFunc
 	status       PortForwardStatus
 	errorMessage string
+	logs         *logBuffer // sql-tapd output
 	mu           sync.Mutex
 }
 
 // NewSqlTapManager creates a new sql-tap manager instance
-func NewSqlTapManager(enabled bool, driver string, listenPort, upstreamPort, grpcPort, httpPort int) *SqlTapManager {
+func NewSqlTapManager(name string, enabled bool, driver, bindAddress string, listenPort, upstreamPort, grpcPort, httpPort int) *SqlTapManager {
 	return &SqlTapManager{
+		name:         name,
 		enabled:      enabled,
 		driver:       driver,
+		bindAddress:  bindAddress,
 		listenPort:   listenPort,
 		upstreamPort: upstreamPort,
 		grpcPort:     grpcPort,
 		httpPort:     httpPort,
 		status:       StatusStopped,
+		logs:         newLogBuffer(logSourceSqlTap, serviceLogLines),
 	}
 }
 
+// setStatusLocked changes the status and records the transition in the status history
+func (stm *SqlTapManager) setStatusLocked(status PortForwardStatus, reason string) {
+	if status != stm.status {
+		history.record(historyKindSqlTap, stm.name, string(stm.status), string(status), reason)
+	}
+	stm.status = status
+}
+
+// notifyCrashedLocked tells the notifiers that sql-tapd exited unexpectedly
+func (stm *SqlTapManager) notifyCrashedLocked() {
+	notifications.failure(EventSqlTapCrashed, historyKindSqlTap, stm.name,
+		fmt.Sprintf("sql-tapd of %s crashed: %s", stm.name, stm.errorMessage))
+}
+
 // composeDatabaseURL creates the DATABASE_URL from driver and upstream port
 func (stm *SqlTapManager) composeDatabaseURL() string {
 	// Map driver names to protocol names
@@ -45,7 +68,11 @@ func (stm *SqlTapManager) composeDatabaseURL() string {
 	if stm.driver == "postgres" {
 		protocol = "postgresql"
 	}
-	return fmt.Sprintf("%s://127.0.0.1:%d", protocol, stm.upstreamPort)
+	host := "127.0.0.1"
+	if stm.bindAddress != "" {
+		host = dialHost(stm.bindAddress)
+	}
+	return fmt.Sprintf("%s://%s", protocol, net.JoinHostPort(host, strconv.Itoa(stm.upstreamPort)))
 }
 
 // Start initiates the sql-tapd process
@@ -61,7 +88,7 @@ func (stm *SqlTapManager) Start() error {
 		return fmt.Errorf("sql-tapd already running")
 	}
 
-	stm.status = StatusStarting
+	stm.setStatusLocked(StatusStarting, "started")
 	stm.errorMessage = ""
 
 	// Create context for the command
@@ -72,6 +99,13 @@ func (stm *SqlTapManager) Start() error {
 	// Example: DATABASE_URL="postgresql://127.0.0.1:5432" sql-tapd --driver=postgres --listen=:5433 --upstream=localhost:5432 --grpc=:9091
 	listenAddr := fmt.Sprintf(":%d", stm.listenPort)
 	upstreamAddr := fmt.Sprintf("localhost:%d", stm.upstreamPort)
+	httpAddr := fmt.Sprintf(":%d", stm.httpPort)
+	if stm.bindAddress != "" {
+		// Stay on the forward's address so services on other loopback IPs can reuse the ports
+		listenAddr = net.JoinHostPort(stm.bindAddress, strconv.Itoa(stm.listenPort))
+		upstreamAddr = net.JoinHostPort(dialHost(stm.bindAddress), strconv.Itoa(stm.upstreamPort))
+		httpAddr = net.JoinHostPort(stm.bindAddress, strconv.Itoa(stm.httpPort))
+	}
 	grpcAddr := fmt.Sprintf(":%d", stm.grpcPort)
 	databaseUrl := stm.composeDatabaseURL()
 
@@ -82,7 +116,7 @@ func (stm *SqlTapManager) Start() error {
 		fmt.Sprintf("--grpc=%s", grpcAddr),
 	}
 	if stm.httpPort > 0 {
-		args = append(args, fmt.Sprintf("--http=:%d", stm.httpPort))
+		args = append(args, fmt.Sprintf("--http=%s", httpAddr))
 	}
 
 	debugLog("Starting sql-tapd: DATABASE_URL=%s sql-tapd %s", databaseUrl, strings.Join(args, " "))
@@ -90,13 +124,15 @@ func (stm *SqlTapManager) Start() error {
 	stm.cmd = exec.CommandContext(ctx, "sql-tapd", args...)
 	stm.cmd.Env = append(os.Environ(), fmt.Sprintf("DATABASE_URL=%s", databaseUrl))
 
-	// Capture stderr for error messages
+	// Capture stderr for error messages; all output goes to the service log
 	var stderr strings.Builder
-	stm.cmd.Stderr = &stderr
+	stm.cmd.Stdout = stm.logs.writer(logStreamStdout)
+	stm.cmd.Stderr = io.MultiWriter(&stderr, stm.logs.writer(logStreamStderr))
+	stm.logs.event("Executing: DATABASE_URL=%s sql-tapd %s", databaseUrl, strings.Join(args, " "))
 
 	// Start the command
 	if err := stm.cmd.Start(); err != nil {
-		stm.status = StatusError
+		stm.setStatusLocked(StatusError, fmt.Sprintf("failed to start: %v", err))
 		stm.errorMessage = fmt.Sprintf("Failed to start sql-tapd: %v", err)
 		if stderr.Len() > 0 {
 			stm.errorMessage += fmt.Sprintf(" | stderr: %s", stderr.String())
@@ -106,28 +142,32 @@ func (stm *SqlTapManager) Start() error {
 	}
 
 	// Monitor the process in a goroutine
-	go stm.monitor(&stderr)
-
-	// Wait a moment to ensure it starts successfully
-	time.Sleep(500 * time.Millisecond)
-
-	// Check if it's still running
-	if stm.cmd.ProcessState != nil && stm.cmd.ProcessState.Exited() {
-		stm.status = StatusError
+	exited := make(chan struct{})
+	go stm.monitor(&stderr, exited)
+
+	// Wait a moment to ensure it starts successfully. monitor tells the notifiers
+	// once it gets the lock.
+	select {
+	case <-exited:
+		stm.setStatusLocked(StatusError, "exited immediately")
 		stm.errorMessage = "sql-tapd exited immediately"
 		if stderr.Len() > 0 {
 			stm.errorMessage += fmt.Sprintf(" | stderr: %s", stderr.String())
 		}
 		return fmt.Errorf("%s", stm.errorMessage)
+	case <-time.After(500 * time.Millisecond):
 	}
 
-	stm.status = StatusRunning
+	stm.setStatusLocked(StatusRunning, "running")
+	notifications.ready(historyKindSqlTap, stm.name)
 	return nil
 }
 
-// monitor watches the sql-tapd process and updates status
-func (stm *SqlTapManager) monitor(stderr *strings.Builder) {
+// monitor watches the sql-tapd process and updates status. It closes exited as soon
+// as the process has exited, before taking the lock Start may be holding.
+func (stm *SqlTapManager) monitor(stderr *strings.Builder, exited chan struct{}) {
 	err := stm.cmd.Wait()
+	close(exited)
 
 	stm.mu.Lock()
 	defer stm.mu.Unlock()
@@ -138,10 +178,12 @@ func (stm *SqlTapManager) monitor(stderr *strings.Builder) {
 			stm.errorMessage += fmt.Sprintf(" | stderr: %s", strings.TrimSpace(stderr.String()))
 		}
 		debugLog("EXIT: %v  cmd=sql-tapd  stderr=%s", err, strings.TrimSpace(stderr.String()))
-		stm.status = StatusError
+		stm.logs.event("sql-tapd exited: %v", err)
+		stm.setStatusLocked(StatusError, fmt.Sprintf("exited: %v", err))
+		stm.notifyCrashedLocked()
 	} else {
 		if stm.status == StatusRunning || stm.status == StatusStarting {
-			stm.status = StatusStopped
+			stm.setStatusLocked(StatusStopped, "exited")
 		}
 	}
 }
@@ -170,7 +212,7 @@ func (stm *SqlTapManager) Stop() error {
 		stm.cmd.Process.Kill()
 	}
 
-	stm.status = StatusStopped
+	stm.setStatusLocked(StatusStopped, "stopped")
 	stm.errorMessage = ""
 	return nil
 }
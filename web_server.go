@@ -22,55 +22,144 @@ type WebApp struct {
 	store            ConfigStore
 	portForwards     []*PortForward
 	proxyForwards    map[string]*ProxyForward
+	proxyMetrics     map[string]*connMetrics // by proxy service name, kept across proxy forward restarts
+	proxyLogs        map[string]serviceLogs  // by proxy service name, kept across proxy forward restarts
 	proxyPodManagers map[string]*ProxyPodManager // keyed by "context/namespace"
 	explorer         *Explorer
+	hosts            *HostsManager // nil unless manage_hosts is on
+	waiting          map[string]string // Dependency notes by service or proxy service name, e.g. "Waiting for Database"
+	orphans          []orphan          // Leftovers of a kubefwd that was killed, from the last scan (see orphans.go)
+	closing          bool              // Shutdown began; start plans start nothing more
 	mu               sync.RWMutex
+	podMu            sync.Mutex // Serializes proxy pod creation by start plans
+
+	// Saved session (see session.go)
+	session       session
+	sessionClosed bool // Shutdown began; the session is not saved any more
+	sessionMu     sync.Mutex
 
 	// SSE clients
 	sseClients map[chan string]struct{}
 	sseMu      sync.Mutex
 }
 
+// newProxyForwardLocked creates a proxy forward that continues the connection
+// counters and output logs of earlier forwards of the same proxy service. wa.mu
+// must be held.
+func (wa *WebApp) newProxyForwardLocked(ps ProxyService, mgr *ProxyPodManager) *ProxyForward {
+	pxf := NewProxyForward(ps, mgr, wa.config)
+	if m, ok := wa.proxyMetrics[ps.Name]; ok {
+		pxf.metrics = m
+	} else {
+		wa.proxyMetrics[ps.Name] = pxf.metrics
+	}
+	if l, ok := wa.proxyLogs[ps.Name]; ok {
+		pxf.logs = l.forward
+		pxf.sqlTapManager.logs = l.sqlTap
+	} else {
+		wa.proxyLogs[ps.Name] = serviceLogs{forward: pxf.logs, sqlTap: pxf.sqlTapManager.logs}
+	}
+	return pxf
+}
+
 // proxyGroupKey returns the map key for a context+namespace pair.
 func proxyGroupKey(ctx, ns string) string { return ctx + "/" + ns }
 
 // buildProxyPodManagers creates one ProxyPodManager per unique (context, namespace)
-// group found in the proxy services list.
-func buildProxyPodManagers(config *Config) map[string]*ProxyPodManager {
+// group found in the proxy services list. onRecreated is called with the group key
+// after a lost pod was recreated.
+func buildProxyPodManagers(config *Config, onRecreated func(key string)) map[string]*ProxyPodManager {
 	managers := make(map[string]*ProxyPodManager)
 	for _, ps := range config.ProxyServices {
 		key := ps.ProxyGroupKey()
 		if _, exists := managers[key]; !exists {
 			podName := BuildPodName(config.ProxyPodName, ps.ProxyPodContext, ps.ProxyPodNamespace)
-			managers[key] = NewProxyPodManager(
+			mgr := NewProxyPodManager(
 				podName,
 				config.ProxyPodImage,
 				ps.ProxyPodNamespace,
 				ps.ProxyPodContext,
 			)
+			mgr.retryPolicy = config.RetryPolicy
+			mgr.maxRetries = config.MaxRetries
+			if onRecreated != nil {
+				mgr.onRecreated = func() { onRecreated(key) }
+			}
+			managers[key] = mgr
 		}
 	}
 	return managers
 }
 
+// reconnectProxyGroup re-establishes the active proxy forwards of group key once its
+// pod was recreated after an eviction or deletion
+func (wa *WebApp) reconnectProxyGroup(key string) {
+	wa.mu.RLock()
+	mgr := wa.proxyPodManagers[key]
+	var pxfs []*ProxyForward
+	for _, pxf := range wa.proxyForwards {
+		if pxf.PodManager == mgr {
+			pxfs = append(pxfs, pxf)
+		}
+	}
+	wa.mu.RUnlock()
+
+	for _, pxf := range pxfs {
+		if err := pxf.Reconnect(); err != nil {
+			debugLog("proxy %s: reconnect after pod recreation: %v", pxf.ProxyService.Name, err)
+		}
+	}
+}
+
 // NewWebApp creates and initialises a WebApp from the given config.
 func NewWebApp(config *Config, store ConfigStore) *WebApp {
 	pfs := make([]*PortForward, len(config.Services))
 	for i, svc := range config.Services {
-		pfs[i] = NewPortForward(svc, config.ClusterContext, config.Namespace, config.MaxRetries)
-	}
+		pfs[i] = NewPortForward(svc, config)
+	}
+
+	wa := &WebApp{
+		config:        config,
+		store:         store,
+		portForwards:  pfs,
+		proxyForwards: make(map[string]*ProxyForward),
+		proxyMetrics:  make(map[string]*connMetrics),
+		proxyLogs:     make(map[string]serviceLogs),
+		explorer:      NewExplorer(),
+		hosts:         buildHostsManager(config),
+		waiting:       make(map[string]string),
+		sseClients:    make(map[chan string]struct{}),
+	}
+	wa.proxyPodManagers = buildProxyPodManagers(config, wa.reconnectProxyGroup)
+	configureHistory(store, config)
+	notifications.configure(config.Notifiers)
+	return wa
+}
 
-	managers := buildProxyPodManagers(config)
+// configureHistory persists the status history to store when it supports that and
+// history_days is set, and keeps it in memory only otherwise
+func configureHistory(store ConfigStore, cfg *Config) {
+	sink, ok := store.(historySink)
+	if !ok || cfg.HistoryDays <= 0 {
+		_ = history.persist(nil, 0)
+		return
+	}
+	if err := history.persist(sink, time.Duration(cfg.HistoryDays)*24*time.Hour); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot load the status history from %s: %v\n", store.Description(), err)
+	}
+}
 
-	return &WebApp{
-		config:           config,
-		store:            store,
-		portForwards:     pfs,
-		proxyPodManagers: managers,
-		proxyForwards:    make(map[string]*ProxyForward),
-		explorer:         NewExplorer(),
-		sseClients:       make(map[chan string]struct{}),
+// buildHostsManager returns the hosts file manager for cfg (nil when manage_hosts is
+// off) after removing any block a previous run left behind.
+func buildHostsManager(cfg *Config) *HostsManager {
+	if !cfg.ManageHosts {
+		return nil
+	}
+	hm := NewHostsManager(cfg.HostsFile)
+	if err := hm.Clear(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot update %s: %v\n", cfg.HostsFile, err)
 	}
+	return hm
 }
 
 func (wa *WebApp) currentConfigClone() *Config {
@@ -85,52 +174,117 @@ func (wa *WebApp) reapplyConfig(cfg *Config) {
 	wa.mu.Lock()
 	defer wa.mu.Unlock()
 	wa.config = cfg
+	// Connection counters and output logs survive config changes for services that keep their name
+	metrics := make(map[string]*connMetrics, len(wa.portForwards))
+	logs := make(map[string]serviceLogs, len(wa.portForwards))
+	for _, pf := range wa.portForwards {
+		metrics[pf.Service.Name] = pf.metrics
+		logs[pf.Service.Name] = serviceLogs{forward: pf.logs, sqlTap: pf.sqlTapManager.logs}
+	}
 	wa.portForwards = make([]*PortForward, len(cfg.Services))
 	for i := range cfg.Services {
-		wa.portForwards[i] = NewPortForward(cfg.Services[i], cfg.ClusterContext, cfg.Namespace, cfg.MaxRetries)
+		wa.portForwards[i] = NewPortForward(cfg.Services[i], cfg)
+		if m, ok := metrics[cfg.Services[i].Name]; ok {
+			wa.portForwards[i].metrics = m
+		}
+		if l, ok := logs[cfg.Services[i].Name]; ok {
+			wa.portForwards[i].logs = l.forward
+			wa.portForwards[i].sqlTapManager.logs = l.sqlTap
+		}
 	}
-	wa.proxyPodManagers = buildProxyPodManagers(cfg)
+	wa.proxyPodManagers = buildProxyPodManagers(cfg, wa.reconnectProxyGroup)
 	wa.proxyForwards = make(map[string]*ProxyForward)
+	wa.waiting = make(map[string]string)
+	if wa.hosts != nil && (!cfg.ManageHosts || cfg.HostsFile != wa.hosts.path) {
+		_ = wa.hosts.Close()
+		wa.hosts = nil
+	}
+	if wa.hosts == nil {
+		wa.hosts = buildHostsManager(cfg)
+	}
+	configureHistory(wa.store, cfg)
+	notifications.configure(cfg.Notifiers)
 }
 
-// StartDefaults starts all services marked selected_by_default.
+// StartDefaults starts all services marked selected_by_default (lazy ones are armed)
+// in the background, together with what they depend on, in dependency order.
 func (wa *WebApp) StartDefaults() {
+	var names []string
 	for _, pf := range wa.portForwards {
 		if pf.Service.SelectedByDefault {
-			_ = pf.Start()
+			names = append(names, pf.Service.Name)
 		}
 	}
+	go wa.startWithDependencies(names, false)
 }
 
-// StartDefaultProxies starts proxy services marked selected_by_default.
+// StartDefaultProxies creates the proxy pods for proxy services marked
+// selected_by_default and starts their forwards in dependency order in the background.
 func (wa *WebApp) StartDefaultProxies() {
 	// Group default services by context+namespace
 	groups := make(map[string][]ProxyService)
+	var names []string
 	for _, ps := range wa.config.ProxyServices {
 		if ps.SelectedByDefault {
 			key := ps.ProxyGroupKey()
 			groups[key] = append(groups[key], ps)
+			names = append(names, ps.Name)
 		}
 	}
 	if len(groups) == 0 {
 		return
 	}
-	wa.mu.Lock()
-	defer wa.mu.Unlock()
+	wa.mu.RLock()
 	for key, svcs := range groups {
-		mgr, ok := wa.proxyPodManagers[key]
-		if !ok {
-			continue
+		if mgr, ok := wa.proxyPodManagers[key]; ok {
+			if podRelaysAll(mgr, svcs) {
+				continue // e.g. an adopted pod; recreating it would cut its forwards
+			}
+			_ = mgr.CreatePodWithServices(svcs)
 		}
-		if err := mgr.CreatePodWithServices(svcs); err != nil {
-			continue
+	}
+	wa.mu.RUnlock()
+	go wa.startWithDependencies(names, false)
+}
+
+// podRelaysAll reports whether the pod of mgr is ready and relays every one of svcs
+func podRelaysAll(mgr *ProxyPodManager, svcs []ProxyService) bool {
+	if st, _, _ := mgr.GetStatus(); st != ProxyPodStatusReady {
+		return false
+	}
+	for _, ps := range svcs {
+		if !mgr.IsServiceActive(ps.Name) {
+			return false
 		}
-		for _, ps := range svcs {
-			pxf := NewProxyForward(ps, mgr)
-			_ = pxf.Start()
-			wa.proxyForwards[ps.Name] = pxf
+	}
+	return true
+}
+
+// ApplyPreset stops the running services and starts those of the named preset (armed
+// if it is lazy) and what they depend on, in dependency order in the background. It
+// reports false for an unknown preset.
+func (wa *WebApp) ApplyPreset(name string) bool {
+	var preset *Preset
+	for i := range wa.config.Presets {
+		if wa.config.Presets[i].Name == name {
+			preset = &wa.config.Presets[i]
+			break
 		}
 	}
+	if preset == nil {
+		return false
+	}
+
+	// Stop all first
+	for _, pf := range wa.portForwards {
+		if pf.IsRunning() {
+			_ = pf.Stop()
+		}
+	}
+
+	// Start preset services and what they depend on, in dependency order
+	go wa.startWithDependencies(preset.Services, preset.Lazy)
+	return true
 }
 
 // StopAll stops every running forward and deletes all proxy pods.
@@ -150,6 +304,75 @@ func (wa *WebApp) StopAll() {
 	}
 }
 
+// Shutdown stops everything, writes pending status history and removes the hosts file
+// block before the process exits.
+func (wa *WebApp) Shutdown() {
+	wa.closeSession()
+	wa.mu.Lock()
+	wa.closing = true
+	wa.mu.Unlock()
+	wa.StopAll()
+	history.flush()
+	wa.mu.RLock()
+	hm := wa.hosts
+	wa.mu.RUnlock()
+	if hm != nil {
+		_ = hm.Close()
+	}
+}
+
+// forwardedService is a running forward that has a cluster DNS name
+type forwardedService struct {
+	ServiceName string
+	Namespace   string
+	Address     string // IP the forward is reachable on
+	Ports       []PortMapping
+}
+
+// forwardedServices returns the forwards of kind service whose relay holds the local
+// port: running, armed or waiting to retry. Pods, workloads and selector targets have
+// no service name to resolve and are left out.
+func (wa *WebApp) forwardedServices() []forwardedService {
+	wa.mu.RLock()
+	defer wa.mu.RUnlock()
+	var out []forwardedService
+	for _, pf := range wa.portForwards {
+		if pf.Service.Selector != "" || pf.Service.GetKind() != TargetKindService {
+			continue
+		}
+		status, _ := pf.GetStatus()
+		retrying, _, _ := pf.GetRetryInfo()
+		if status != StatusRunning && status != StatusArmed && !retrying {
+			continue
+		}
+		out = append(out, forwardedService{
+			ServiceName: pf.Service.ServiceName,
+			Namespace:   pf.namespace,
+			Address:     hostsAddress(pf.Service.BindAddress),
+			Ports:       pf.Service.PortMappings(),
+		})
+	}
+	return out
+}
+
+// syncHosts points the cluster DNS names of every forwarded service at its bind address.
+func (wa *WebApp) syncHosts() {
+	wa.mu.RLock()
+	hm := wa.hosts
+	wa.mu.RUnlock()
+	if hm == nil {
+		return
+	}
+	var entries []hostsEntry
+	for _, fs := range wa.forwardedServices() {
+		entries = append(entries, hostsEntry{
+			Address: fs.Address,
+			Names:   serviceHostNames(fs.ServiceName, fs.Namespace),
+		})
+	}
+	_ = hm.Sync(entries)
+}
+
 // --- SSE helpers ---
 
 func (wa *WebApp) addSSEClient(ch chan string) {
@@ -177,7 +400,8 @@ func (wa *WebApp) broadcastState() {
 	wa.sseMu.Unlock()
 }
 
-// startSSEBroadcaster pushes state to all SSE clients every 500 ms.
+// startSSEBroadcaster pushes state to all SSE clients every 500 ms and keeps the
+// hosts file block in step with the running forwards.
 func (wa *WebApp) startSSEBroadcaster(ctx context.Context) {
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
@@ -186,6 +410,7 @@ func (wa *WebApp) startSSEBroadcaster(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			wa.syncHosts()
 			wa.broadcastState()
 		}
 	}
@@ -193,10 +418,23 @@ func (wa *WebApp) startSSEBroadcaster(ctx context.Context) {
 
 // --- JSON state helpers ---
 
+type portStateJSON struct {
+	Name       string `json:"name,omitempty"`
+	LocalPort  int    `json:"local_port"`
+	RemotePort int    `json:"remote_port"`
+	Status     string `json:"status"`
+}
+
 type serviceStateJSON struct {
 	Name              string `json:"name"`
+	Kind              string `json:"kind"`
+	Selector          string `json:"selector,omitempty"`
+	Pod               string `json:"pod,omitempty"` // Pod a selector target currently forwards to
+	BindAddress       string `json:"bind_address,omitempty"`
+	Lazy              bool   `json:"lazy,omitempty"`
 	LocalPort         int    `json:"local_port"`
 	RemotePort        int    `json:"remote_port"`
+	Ports             []portStateJSON `json:"ports"`
 	Status            string `json:"status"`
 	Error             string `json:"error,omitempty"`
 	Retrying          bool   `json:"retrying"`
@@ -207,13 +445,20 @@ type serviceStateJSON struct {
 	SqlTapPort        int    `json:"sql_tap_port,omitempty"`
 	SqlTapGrpcPort    int    `json:"sql_tap_grpc_port,omitempty"`
 	SqlTapHttpPort    int    `json:"sql_tap_http_port,omitempty"`
+	DependsOn         []string `json:"depends_on,omitempty"`
+	Waiting           string `json:"waiting,omitempty"` // Why a start plan has not started it (yet)
+	Metrics           metricsSnapshot `json:"metrics"`
 }
 
 type proxyServiceStateJSON struct {
 	Name              string `json:"name"`
+	BindAddress       string `json:"bind_address,omitempty"`
 	LocalPort         int    `json:"local_port"`
 	Status            string `json:"status"`
 	Error             string `json:"error,omitempty"`
+	Retrying          bool   `json:"retrying"`
+	RetryAttempt      int    `json:"retry_attempt"`
+	MaxRetries        int    `json:"max_retries"`
 	IsDefault         bool   `json:"is_default"`
 	Active            bool   `json:"active"`
 	ProxyPodContext   string `json:"proxy_pod_context"`
@@ -222,6 +467,9 @@ type proxyServiceStateJSON struct {
 	SqlTapPort        int    `json:"sql_tap_port,omitempty"`
 	SqlTapGrpcPort    int    `json:"sql_tap_grpc_port,omitempty"`
 	SqlTapHttpPort    int    `json:"sql_tap_http_port,omitempty"`
+	DependsOn         []string `json:"depends_on,omitempty"`
+	Waiting           string `json:"waiting,omitempty"`
+	Metrics           *metricsSnapshot `json:"metrics,omitempty"` // nil until the proxy service was first started
 }
 
 type proxyGroupStateJSON struct {
@@ -244,6 +492,8 @@ type stateJSON struct {
 	Presets          []Preset              `json:"presets"`
 	Contexts         []AlternativeContext  `json:"contexts"`
 	HasProxyServices bool                  `json:"has_proxy_services"`
+	RestoreSession   bool                  `json:"restore_session"` // Restore the saved session on startup
+	Orphans          []orphan              `json:"orphans,omitempty"`
 	DebugMode        bool                  `json:"debug_mode"`
 	DebugLines       []string              `json:"debug_lines"`
 }
@@ -258,6 +508,11 @@ func (wa *WebApp) buildStateJSON() string {
 		retrying, attempt, maxR := pf.GetRetryInfo()
 		s := serviceStateJSON{
 			Name:         pf.Service.Name,
+			Kind:         pf.Service.GetKind(),
+			Selector:     pf.Service.Selector,
+			Pod:          pf.GetPodName(),
+			BindAddress:  pf.Service.BindAddress,
+			Lazy:         pf.Service.Lazy,
 			LocalPort:    pf.Service.LocalPort,
 			RemotePort:   pf.Service.RemotePort,
 			Status:       string(status),
@@ -267,6 +522,17 @@ func (wa *WebApp) buildStateJSON() string {
 			MaxRetries:   maxR,
 			IsDefault:    pf.Service.SelectedByDefault,
 			HasSqlTap:    pf.Service.SqlTapPort != nil,
+			DependsOn:    pf.Service.DependsOn,
+			Waiting:      wa.waiting[pf.Service.Name],
+			Metrics:      pf.GetMetrics(),
+		}
+		for _, ps := range pf.GetPortStatuses() {
+			s.Ports = append(s.Ports, portStateJSON{
+				Name:       ps.Name,
+				LocalPort:  ps.LocalPort,
+				RemotePort: ps.RemotePort,
+				Status:     string(ps.Status),
+			})
 		}
 		if pf.Service.SqlTapPort != nil {
 			s.SqlTapPort = *pf.Service.SqlTapPort
@@ -309,22 +575,34 @@ func (wa *WebApp) buildStateJSON() string {
 			}
 			status := string(StatusStopped)
 			errMsg := ""
+			retrying, attempt, maxR := false, 0, ps.GetMaxRetries(wa.config.MaxRetries)
 			if pxf, ok := wa.proxyForwards[ps.Name]; ok {
 				st, e := pxf.GetStatus()
 				status = string(st)
 				errMsg = e
+				retrying, attempt, maxR = pxf.GetRetryInfo()
 			}
 			_, active := wa.proxyForwards[ps.Name]
 			entry := proxyServiceStateJSON{
 				Name:              ps.Name,
+				BindAddress:       ps.BindAddress,
 				LocalPort:         ps.LocalPort,
 				Status:            status,
 				Error:             errMsg,
+				Retrying:          retrying,
+				RetryAttempt:      attempt,
+				MaxRetries:        maxR,
 				IsDefault:         ps.SelectedByDefault,
 				Active:            active,
 				ProxyPodContext:   ps.ProxyPodContext,
 				ProxyPodNamespace: ps.ProxyPodNamespace,
 				HasSqlTap:         ps.SqlTapPort != nil,
+				DependsOn:         ps.DependsOn,
+				Waiting:           wa.waiting[ps.Name],
+			}
+			if m, ok := wa.proxyMetrics[ps.Name]; ok {
+				snap := m.Snapshot()
+				entry.Metrics = &snap
 			}
 			if ps.SqlTapPort != nil {
 				entry.SqlTapPort = *ps.SqlTapPort
@@ -362,6 +640,8 @@ func (wa *WebApp) buildStateJSON() string {
 		Presets:          wa.config.Presets,
 		Contexts:         wa.config.AlternativeContexts,
 		HasProxyServices: len(wa.config.ProxyServices) > 0,
+		RestoreSession:   wa.savedSession().RestoreOnStart,
+		Orphans:          wa.orphans,
 		DebugMode:        debugMode,
 		DebugLines:       getDebugLines(),
 	}
@@ -383,7 +663,15 @@ func splitGroupKey(key string) (ctx, ns string) {
 
 // --- HTTP server ---
 
+// ListenAndServe serves the web UI and API on port
 func (wa *WebApp) ListenAndServe(port int) error {
+	addr := fmt.Sprintf(":%d", port)
+	return http.ListenAndServe(addr, wa.routes())
+}
+
+// routes returns the handler for the web UI and API. The control socket serves the
+// same (see instance.go).
+func (wa *WebApp) routes() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Static UI
@@ -392,13 +680,28 @@ func (wa *WebApp) ListenAndServe(port int) error {
 	// SSE stream
 	mux.HandleFunc("GET /api/state", wa.handleSSE)
 
+	// Prometheus metrics
+	mux.HandleFunc("GET /metrics", wa.handleMetrics)
+
 	// Services
 	mux.HandleFunc("GET /api/services", wa.handleGetServices)
 	mux.HandleFunc("POST /api/services/start-all", wa.handleStartAll)
 	mux.HandleFunc("POST /api/services/stop-all", wa.handleStopAll)
 	mux.HandleFunc("POST /api/services/start-defaults", wa.handleStartDefaults)
 	mux.HandleFunc("POST /api/services/{name}/start", wa.handleServiceStart)
+	mux.HandleFunc("POST /api/services/{name}/arm", wa.handleServiceArm)
 	mux.HandleFunc("POST /api/services/{name}/stop", wa.handleServiceStop)
+	mux.HandleFunc("GET /api/services/{name}/logs", wa.handleServiceLogs)
+	mux.HandleFunc("GET /api/history", wa.handleHistory)
+	mux.HandleFunc("POST /api/notifiers/{name}/test", wa.handleTestNotifier)
+	mux.HandleFunc("GET /api/session", wa.handleGetSession)
+	mux.HandleFunc("POST /api/session/restore-on-start", wa.handleRestoreOnStart)
+	mux.HandleFunc("POST /api/session/restore", wa.handleRestoreSession)
+	mux.HandleFunc("GET /api/instance", wa.handleInstance)
+	mux.HandleFunc("GET /api/orphans", wa.handleGetOrphans)
+	mux.HandleFunc("POST /api/orphans/scan", wa.handleScanOrphans)
+	mux.HandleFunc("POST /api/orphans/adopt", wa.handleResolveOrphans)
+	mux.HandleFunc("POST /api/orphans/cleanup", wa.handleResolveOrphans)
 
 	// Proxy services
 	mux.HandleFunc("GET /api/proxy-services", wa.handleGetProxyServices)
@@ -443,8 +746,7 @@ func (wa *WebApp) ListenAndServe(port int) error {
 	mux.HandleFunc("PUT /api/config/proxy-services/{name}", wa.handlePutConfigProxyService)
 	mux.HandleFunc("DELETE /api/config/proxy-services/{name}", wa.handleDeleteConfigProxyService)
 
-	addr := fmt.Sprintf(":%d", port)
-	return http.ListenAndServe(addr, mux)
+	return mux
 }
 
 func jsonOK(w http.ResponseWriter, v any) {
@@ -520,6 +822,7 @@ func (wa *WebApp) handleGetServices(w http.ResponseWriter, r *http.Request) {
 // handleServiceStart starts a single service by name.
 func (wa *WebApp) handleServiceStart(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
+	wa.setWaiting(name, "")
 	for _, pf := range wa.portForwards {
 		if pf.Service.Name == name {
 			if err := pf.Start(); err != nil {
@@ -533,27 +836,320 @@ func (wa *WebApp) handleServiceStart(w http.ResponseWriter, r *http.Request) {
 	jsonError(w, "service not found", http.StatusNotFound)
 }
 
-// handleServiceStop stops a single service by name.
+// handleServiceArm arms a single service: kubefwd listens on its local ports and
+// starts the forward on the first connection.
+func (wa *WebApp) handleServiceArm(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	for _, pf := range wa.portForwards {
+		if pf.Service.Name == name {
+			if err := pf.Arm(); err != nil {
+				jsonError(w, err.Error(), http.StatusConflict)
+				return
+			}
+			jsonOK(w, map[string]string{"status": "armed"})
+			return
+		}
+	}
+	jsonError(w, "service not found", http.StatusNotFound)
+}
+
+// handleServiceStop stops a single service by name. With ?dependents=1 the services
+// and proxy services depending on it are stopped first; otherwise the running ones
+// are listed in the answer.
 func (wa *WebApp) handleServiceStop(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
+	wa.setWaiting(name, "")
 	for _, pf := range wa.portForwards {
 		if pf.Service.Name == name {
+			result := wa.dependentsResult(r, name)
 			if err := pf.Stop(); err != nil {
 				jsonError(w, err.Error(), http.StatusConflict)
 				return
 			}
-			jsonOK(w, map[string]string{"status": "stopped"})
+			jsonOK(w, result)
 			return
 		}
 	}
 	jsonError(w, "service not found", http.StatusNotFound)
 }
 
-// handleStartAll starts all port forwards.
-func (wa *WebApp) handleStartAll(w http.ResponseWriter, r *http.Request) {
+// dependentsResult stops the dependents of name if the request asks for it and
+// returns the answer of a stop request
+func (wa *WebApp) dependentsResult(r *http.Request, name string) map[string]any {
+	if r.URL.Query().Get("dependents") == "1" {
+		return map[string]any{"status": "stopped", "stopped_dependents": wa.stopDependents(name)}
+	}
+	return map[string]any{"status": "stopped", "running_dependents": wa.runningDependents(name)}
+}
+
+// serviceLogsFor returns the output buffers of the service or proxy service name
+func (wa *WebApp) serviceLogsFor(name string) (serviceLogs, bool) {
+	wa.mu.Lock()
+	defer wa.mu.Unlock()
 	for _, pf := range wa.portForwards {
-		_ = pf.Start()
+		if pf.Service.Name == name {
+			return serviceLogs{forward: pf.logs, sqlTap: pf.sqlTapManager.logs}, true
+		}
+	}
+	if l, ok := wa.proxyLogs[name]; ok {
+		return l, true
 	}
+	for _, ps := range wa.config.ProxyServices {
+		if ps.Name == name {
+			// Not started yet; its first forward will write to these buffers
+			l := serviceLogs{
+				forward: newLogBuffer(logSourceForward, serviceLogLines),
+				sqlTap:  newLogBuffer(logSourceSqlTap, serviceLogLines),
+			}
+			wa.proxyLogs[name] = l
+			return l, true
+		}
+	}
+	return serviceLogs{}, false
+}
+
+// handleServiceLogs returns the captured output of a service's forward and sql-tapd,
+// oldest first. ?tail=N limits it to the last N lines. With ?follow=1 the lines are
+// streamed as Server-Sent Events, one JSON line per event, followed by new output.
+func (wa *WebApp) handleServiceLogs(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	logs, ok := wa.serviceLogsFor(name)
+	if !ok {
+		jsonError(w, "service not found", http.StatusNotFound)
+		return
+	}
+	tail := 0
+	if v := r.URL.Query().Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			jsonError(w, "tail must be a non-negative number", http.StatusBadRequest)
+			return
+		}
+		tail = n
+	}
+	tailLines := func(lines []logLine) []logLine {
+		if tail > 0 && len(lines) > tail {
+			return lines[len(lines)-tail:]
+		}
+		return lines
+	}
+
+	follow := r.URL.Query().Get("follow")
+	if follow == "" || follow == "0" || follow == "false" {
+		jsonOK(w, map[string]interface{}{
+			"name":  name,
+			"lines": tailLines(mergeLogLines(logs.forward.Lines(), logs.sqlTap.Lines())),
+		})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	fwdLines, fwdCh, stopFwd := logs.forward.Follow()
+	defer stopFwd()
+	tapLines, tapCh, stopTap := logs.sqlTap.Follow()
+	defer stopTap()
+
+	send := func(line logLine) {
+		data, _ := json.Marshal(line)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	for _, line := range tailLines(mergeLogLines(fwdLines, tapLines)) {
+		send(line)
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case line := <-fwdCh:
+			send(line)
+			flusher.Flush()
+		case line := <-tapCh:
+			send(line)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleHistory returns the status transitions and uptime of services, proxy services,
+// proxy pods and sql-tapd. ?service= and ?kind= filter by name and kind; the time range
+// is ?from= and ?to= (RFC 3339) or ?since= (a duration such as 24h, the default).
+func (wa *WebApp) handleHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	now := time.Now()
+	f := historyFilter{Kind: q.Get("kind"), Name: q.Get("service"), From: now.Add(-24 * time.Hour), To: now}
+	if v := q.Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			jsonError(w, "since must be a positive duration such as 90m or 24h", http.StatusBadRequest)
+			return
+		}
+		f.From = now.Add(-d)
+	}
+	for param, target := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		if v := q.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("%s must be an RFC 3339 time: %v", param, err), http.StatusBadRequest)
+				return
+			}
+			*target = t
+		}
+	}
+	if f.To.After(now) {
+		f.To = now // Uptime counts up to now, not into the future
+	}
+	if !f.To.After(f.From) {
+		jsonError(w, "the time range is empty", http.StatusBadRequest)
+		return
+	}
+	jsonOK(w, map[string]interface{}{
+		"from":        f.From,
+		"to":          f.To,
+		"transitions": history.Transitions(f),
+		"uptime":      history.Uptime(f),
+	})
+}
+
+// handleTestNotifier sends a test notification through a configured notifier and
+// reports whether it was delivered
+func (wa *WebApp) handleTestNotifier(w http.ResponseWriter, r *http.Request) {
+	found, err := notifications.test(r.PathValue("name"))
+	if !found {
+		jsonError(w, "notifier not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	jsonOK(w, map[string]string{"status": "sent"})
+}
+
+// handleGetSession returns the saved session
+func (wa *WebApp) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	if _, ok := wa.store.(sessionStore); !ok {
+		jsonError(w, "the config store cannot save sessions", http.StatusNotImplemented)
+		return
+	}
+	jsonOK(w, wa.savedSession())
+}
+
+// handleRestoreOnStart turns restoring the saved session on startup on or off
+func (wa *WebApp) handleRestoreOnStart(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		jsonError(w, "invalid body: enabled required", http.StatusBadRequest)
+		return
+	}
+	if err := wa.saveSession(func(s *session) { s.RestoreOnStart = body.Enabled }); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, map[string]bool{"restore_on_start": body.Enabled})
+}
+
+// handleGetOrphans returns the leftovers of a killed kubefwd found by the last scan
+func (wa *WebApp) handleGetOrphans(w http.ResponseWriter, r *http.Request) {
+	wa.mu.RLock()
+	orphans := wa.orphans
+	wa.mu.RUnlock()
+	if orphans == nil {
+		orphans = []orphan{}
+	}
+	jsonOK(w, orphans)
+}
+
+// handleScanOrphans looks for leftovers again and returns them
+func (wa *WebApp) handleScanOrphans(w http.ResponseWriter, r *http.Request) {
+	orphans := wa.ScanOrphans()
+	if orphans == nil {
+		orphans = []orphan{}
+	}
+	jsonOK(w, orphans)
+}
+
+// handleResolveOrphans adopts (/api/orphans/adopt) or cleans up (/api/orphans/cleanup)
+// the orphans listed in an optional {"ids": [...]} body, all of them without one
+func (wa *WebApp) handleResolveOrphans(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		IDs []string `json:"ids"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			jsonError(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+	}
+	adopt := strings.HasSuffix(r.URL.Path, "/adopt")
+	if errs := wa.resolveOrphans(body.IDs, adopt); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		jsonError(w, strings.Join(msgs, "; "), http.StatusInternalServerError)
+		return
+	}
+	status := "cleaned_up"
+	if adopt {
+		status = "adopted"
+	}
+	jsonOK(w, map[string]string{"status": status})
+}
+
+// handleRestoreSession restores the saved session in the background. A session saved
+// on another context is refused; switch to that context first.
+func (wa *WebApp) handleRestoreSession(w http.ResponseWriter, r *http.Request) {
+	s := wa.savedSession()
+	if s.SavedAt.IsZero() {
+		jsonError(w, "no saved session", http.StatusNotFound)
+		return
+	}
+	wa.mu.RLock()
+	current := wa.config.ClusterContext
+	wa.mu.RUnlock()
+	if s.ClusterContext != "" && s.ClusterContext != current {
+		jsonError(w, fmt.Sprintf("the saved session is for context %s; switch to it first", s.ClusterContext), http.StatusConflict)
+		return
+	}
+	go wa.RestoreSession(s)
+	jsonOK(w, map[string]string{"status": "restoring"})
+}
+
+// handleInstance describes this kubefwd, for a second invocation talking to it over
+// the control socket
+func (wa *WebApp) handleInstance(w http.ResponseWriter, r *http.Request) {
+	wa.mu.RLock()
+	port := wa.config.WebPort
+	wa.mu.RUnlock()
+	jsonOK(w, instanceInfo{
+		PID:          os.Getpid(),
+		ConfigSource: wa.store.Description(),
+		WebPort:      port,
+		StartedAt:    processStart,
+	})
+}
+
+// handleStartAll starts all port forwards (lazy ones are armed) in dependency order.
+func (wa *WebApp) handleStartAll(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, len(wa.portForwards))
+	for i, pf := range wa.portForwards {
+		names[i] = pf.Service.Name
+	}
+	go wa.startWithDependencies(names, false)
 	jsonOK(w, map[string]string{"status": "ok"})
 }
 
@@ -569,11 +1165,7 @@ func (wa *WebApp) handleStopAll(w http.ResponseWriter, r *http.Request) {
 
 // handleStartDefaults starts services marked selected_by_default.
 func (wa *WebApp) handleStartDefaults(w http.ResponseWriter, r *http.Request) {
-	for _, pf := range wa.portForwards {
-		if pf.Service.SelectedByDefault {
-			_ = pf.Start()
-		}
-	}
+	wa.StartDefaults()
 	jsonOK(w, map[string]string{"status": "ok"})
 }
 
@@ -680,18 +1272,16 @@ func (wa *WebApp) handleStartDefaultProxies(w http.ResponseWriter, r *http.Reque
 	wa.mu.Unlock()
 
 	go func() {
+		var names []string
 		for _, w := range works {
 			if err := w.mgr.CreatePodWithServices(w.allSvcs); err != nil {
 				continue
 			}
-			wa.mu.Lock()
 			for _, ps := range w.defSvcs {
-				pxf := NewProxyForward(ps, w.mgr)
-				_ = pxf.Start()
-				wa.proxyForwards[ps.Name] = pxf
+				names = append(names, ps.Name)
 			}
-			wa.mu.Unlock()
 		}
+		wa.startWithDependencies(names, false)
 	}()
 
 	jsonOK(w, map[string]string{"status": "starting"})
@@ -713,6 +1303,7 @@ func (wa *WebApp) handleStartProxyService(w http.ResponseWriter, r *http.Request
 		jsonError(w, "proxy service not found", http.StatusNotFound)
 		return
 	}
+	wa.setWaiting(name, "")
 
 	wa.mu.RLock()
 	mgr, ok := wa.proxyPodManagers[ps.ProxyGroupKey()]
@@ -728,21 +1319,23 @@ func (wa *WebApp) handleStartProxyService(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	pxf := NewProxyForward(*ps, mgr)
-	go func() {
-		_ = pxf.Start()
-	}()
-
 	wa.mu.Lock()
+	pxf := wa.newProxyForwardLocked(*ps, mgr)
 	wa.proxyForwards[name] = pxf
 	wa.mu.Unlock()
+	go func() {
+		_ = pxf.Start()
+	}()
 
 	jsonOK(w, map[string]string{"status": "starting"})
 }
 
-// handleStopProxyService stops the port-forward for a single proxy service.
+// handleStopProxyService stops the port-forward for a single proxy service. Like
+// for services, ?dependents=1 stops what depends on it first.
 func (wa *WebApp) handleStopProxyService(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
+	wa.setWaiting(name, "")
+	result := wa.dependentsResult(r, name)
 
 	wa.mu.Lock()
 	pxf, ok := wa.proxyForwards[name]
@@ -757,7 +1350,7 @@ func (wa *WebApp) handleStopProxyService(w http.ResponseWriter, r *http.Request)
 	}
 
 	pxf.Stop()
-	jsonOK(w, map[string]string{"status": "stopped"})
+	jsonOK(w, result)
 }
 
 // handleResetProxyPod stops all proxy forwards, deletes all pods, then recreates
@@ -846,7 +1439,7 @@ func (wa *WebApp) handleResetProxyPod(w http.ResponseWriter, r *http.Request) {
 			}
 			wa.mu.Lock()
 			for _, ps := range rec.fwdSvcs {
-				pxf := NewProxyForward(ps, rec.mgr)
+				pxf := wa.newProxyForwardLocked(ps, rec.mgr)
 				_ = pxf.Start()
 				wa.proxyForwards[ps.Name] = pxf
 			}
@@ -895,42 +1488,13 @@ func (wa *WebApp) handleGetPresets(w http.ResponseWriter, r *http.Request) {
 	jsonOK(w, wa.config.Presets)
 }
 
-// handleApplyPreset stops all services and starts only those in the preset.
+// handleApplyPreset stops all services and starts only those in the preset. A lazy
+// preset arms its services; otherwise lazy services are armed and the rest started.
 func (wa *WebApp) handleApplyPreset(w http.ResponseWriter, r *http.Request) {
-	name := r.PathValue("name")
-
-	var preset *Preset
-	for i := range wa.config.Presets {
-		if wa.config.Presets[i].Name == name {
-			preset = &wa.config.Presets[i]
-			break
-		}
-	}
-	if preset == nil {
+	if !wa.ApplyPreset(r.PathValue("name")) {
 		jsonError(w, "preset not found", http.StatusNotFound)
 		return
 	}
-
-	// Stop all first
-	for _, pf := range wa.portForwards {
-		if pf.IsRunning() {
-			_ = pf.Stop()
-		}
-	}
-
-	// Build a set of names in preset
-	nameSet := make(map[string]struct{}, len(preset.Services))
-	for _, n := range preset.Services {
-		nameSet[n] = struct{}{}
-	}
-
-	// Start preset services
-	for _, pf := range wa.portForwards {
-		if _, ok := nameSet[pf.Service.Name]; ok {
-			_ = pf.Start()
-		}
-	}
-
 	jsonOK(w, map[string]string{"status": "ok"})
 }
 
@@ -964,7 +1528,7 @@ func (wa *WebApp) handleSwitchContext(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate context exists
-	if err := ValidateContext(found.Context); err != nil {
+	if err := ValidateContextForEngine(wa.config.ForwardEngine, found.Context); err != nil {
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -985,6 +1549,7 @@ func (wa *WebApp) handleSwitchContext(w http.ResponseWriter, r *http.Request) {
 // portInfo is the response for the port checker.
 type portInfo struct {
 	Port        int    `json:"port"`
+	Address     string `json:"address,omitempty"`
 	ServiceName string `json:"service_name"`
 	Type        string `json:"type"`
 	InUse       bool   `json:"in_use"`
@@ -993,14 +1558,24 @@ type portInfo struct {
 	Status      string `json:"status"`
 }
 
-// handleGetPorts returns port usage for all configured ports.
+// handleGetPorts returns port usage for all configured ports. Processes of this
+// kubefwd show as kubefwd, leftovers of a kubefwd that was killed as orphan.
 func (wa *WebApp) handleGetPorts(w http.ResponseWriter, r *http.Request) {
 	cfgPorts := GetAllPortsFromConfig(wa.config)
+	wa.mu.RLock()
+	pfs := wa.portForwards
+	pxfs := make(map[string]*ProxyForward, len(wa.proxyForwards))
+	for name, pxf := range wa.proxyForwards {
+		pxfs[name] = pxf
+	}
+	wa.mu.RUnlock()
+	var orphanPIDs map[int]bool
 	result := make([]portInfo, 0, len(cfgPorts))
 	for _, cp := range cfgPorts {
-		usage, err := GetPortUsage(cp.Port)
+		usage, err := GetPortUsageOn(cp.Address, cp.Port)
 		info := portInfo{
 			Port:        cp.Port,
+			Address:     cp.Address,
 			ServiceName: cp.ServiceName,
 			Type:        cp.Type,
 			Status:      string(PortStatusFree),
@@ -1010,13 +1585,24 @@ func (wa *WebApp) handleGetPorts(w http.ResponseWriter, r *http.Request) {
 			info.PID = usage.PID
 			info.Process = usage.ProcessInfo
 			info.Status = string(usage.Status)
+			if usage.InUse && IsKubefwdProcess(usage.PID, pfs, pxfs) {
+				info.Status = string(PortStatusKubefwd)
+			} else if usage.InUse {
+				if orphanPIDs == nil {
+					orphanPIDs = wa.currentOrphanPIDs()
+				}
+				if orphanPIDs[usage.PID] {
+					info.Status = string(PortStatusOrphan)
+				}
+			}
 		}
 		result = append(result, info)
 	}
 	jsonOK(w, result)
 }
 
-// handleKillPort kills the process listening on the given port.
+// handleKillPort kills the process listening on the given port. An optional
+// ?address= limits the lookup to listeners overlapping that bind address.
 func (wa *WebApp) handleKillPort(w http.ResponseWriter, r *http.Request) {
 	portStr := r.PathValue("port")
 	port, err := strconv.Atoi(portStr)
@@ -1025,7 +1611,11 @@ func (wa *WebApp) handleKillPort(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	usage, err := GetPortUsage(port)
+	address := anyAddress
+	if r.URL.Query().Has("address") {
+		address = r.URL.Query().Get("address")
+	}
+	usage, err := GetPortUsageOn(address, port)
 	if err != nil {
 		jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -1034,6 +1624,11 @@ func (wa *WebApp) handleKillPort(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "no process found on that port", http.StatusNotFound)
 		return
 	}
+	if usage.PID == os.Getpid() {
+		// The local ports of forwards are held by kubefwd's relays; stop the forward instead
+		jsonError(w, "the port is held by kubefwd itself; stop its forward instead", http.StatusConflict)
+		return
+	}
 
 	if err := KillProcess(usage.PID); err != nil {
 		jsonError(w, err.Error(), http.StatusInternalServerError)
@@ -1428,7 +2023,7 @@ func (wa *WebApp) handleExplorerServices(w http.ResponseWriter, r *http.Request)
 	cfg := wa.config
 	wa.mu.RUnlock()
 
-	services, err := wa.explorer.DiscoverServices(kubeCtx, ns, cfg)
+	services, err := wa.explorer.DiscoverServices(kubeCtx, ns, r.URL.Query().Get("kind"), cfg)
 	if err != nil {
 		jsonError(w, err.Error(), http.StatusInternalServerError)
 		return